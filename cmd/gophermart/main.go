@@ -2,171 +2,528 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/accrual"
 	"gophermart/internal/config"
+	"gophermart/internal/events"
 	"gophermart/internal/handlers"
+	"gophermart/internal/health"
+	"gophermart/internal/lifecycle"
+	"gophermart/internal/logger"
+	"gophermart/internal/metrics"
 	"gophermart/internal/middleware"
+	"gophermart/internal/outbox"
 	"gophermart/internal/repository"
+	"gophermart/internal/scheduler"
 	"gophermart/internal/service"
+	"gophermart/internal/tiers"
+	"gophermart/internal/tracing"
+	"gophermart/internal/webhooks"
 	"gophermart/storage"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime/pprof"
+	"syscall"
 	"time"
 )
 
 func saveHeapProfile(filename string) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		slog.Error("could not create profiles directory", "error", err)
+		return
+	}
+
 	f, err := os.Create(filename)
 	if err != nil {
-		log.Fatalf("could not create memory profile: %v", err)
+		slog.Error("could not create memory profile", "error", err)
+		return
 	}
 	defer f.Close()
 
 	if err := pprof.WriteHeapProfile(f); err != nil {
-		log.Fatalf("could not write memory profile: %v", err)
+		slog.Error("could not write memory profile", "error", err)
 	}
 }
 
-func main() {
-	if err := os.MkdirAll("profiles", 0755); err != nil {
-		log.Fatalf("could not create profiles directory: %v", err)
-	}
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-	defer saveHeapProfile("profiles/base.pprof")
-	pgsStorage := &storage.PgStorage{}
+func main() {
 	cfg, err := config.InitConfig()
 
-	if err != nil {
-		log.Fatalf("Error while initializing config: %v", err)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		return
 	}
 
-	if cfg == nil {
-		log.Fatalf("Error while initializing config: %v", err)
+	if err != nil {
+		fatal("Error while initializing config", err)
 	}
 
-	dsn := cfg.DatabaseDsn
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("Error while printing config", err)
+		}
 
-	if err != nil {
-		log.Fatalf("Failed to connect database: %v", err)
+		return
 	}
 
-	err = db.AutoMigrate()
-
-	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	if err := logger.Init(cfg.LogLevel); err != nil {
+		fatal("Error while initializing logger", err)
 	}
 
-	err = db.AutoMigrate(&storage.Order{})
+	generated, err := cfg.EnsureJWTSecret()
 
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		fatal("Error while generating JWT secret", err)
+	}
+
+	if generated {
+		slog.Warn("jwt_secret is not configured, using an ephemeral key: tokens will not survive a restart")
 	}
 
-	err = db.AutoMigrate(&storage.User{})
+	middleware.SecretKey = cfg.JWTSecret
+	middleware.PreviousSecretKeys = cfg.JWTPreviousSecrets
+	middleware.TokenCookieName = cfg.CookieName
+	middleware.AdminToken = cfg.AdminToken
+
+	serviceTokens, err := cfg.ServiceTokenMap()
 
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		fatal("Error while parsing service tokens", err)
 	}
 
-	err = db.AutoMigrate(&storage.UserBalance{})
+	middleware.ServiceTokens = serviceTokens
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.TracesExporter, cfg.TracesFile)
 
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		fatal("Error while initializing tracing", err)
 	}
 
-	err = db.AutoMigrate(&storage.Withdrawal{})
+	dsn := cfg.DatabaseDsn
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		fatal("Failed to connect database", err)
 	}
 
+	if err := storage.AutoMigrate(db); err != nil {
+		fatal("Failed to migrate database", err)
+	}
+
+	pgsStorage := &storage.PgStorage{
+		Pool: storage.PoolSettings{
+			MaxConns:          int32(cfg.DBMaxConns),
+			MinConns:          int32(cfg.DBMinConns),
+			MaxConnLifetime:   cfg.DBMaxConnLifetime,
+			MaxConnIdleTime:   cfg.DBMaxConnIdleTime,
+			HealthCheckPeriod: cfg.DBHealthCheckEvery,
+		},
+	}
 	err = pgsStorage.Init(cfg.DatabaseDsn)
 
 	if err != nil {
-		log.Fatalf("Error while initializing db connection: %v", err)
+		fatal("Error while initializing db connection", err)
 	}
 
-	defer pgsStorage.Close()
+	shutdown := &lifecycle.Manager{}
 
 	userRepository := repository.UserRepository{
 		DBStorage: pgsStorage,
 	}
-	userService := service.UserService{
-		UserRepository: &userRepository,
+	webhookRepository := repository.WebhookRepository{
+		DBStorage: pgsStorage,
+	}
+	outboxRepository := repository.OutboxRepository{
+		DBStorage: pgsStorage,
 	}
 	orderRepository := repository.OrderRepository{
 		DBStorage: pgsStorage,
 	}
+	userBalanceRepository := repository.UserBalanceRepository{
+		DBStorage: pgsStorage,
+	}
+	ledgerRepository := repository.LedgerRepository{
+		DBStorage: pgsStorage,
+	}
+	tierPolicy, err := tiers.Parse(cfg.Tiers)
+
+	if err != nil {
+		fatal("Error while parsing tiers", err)
+	}
+
+	tierRepository := repository.TierRepository{
+		DBStorage: pgsStorage,
+	}
+	tierService := service.TierService{
+		TierRepository:   &tierRepository,
+		OutboxRepository: &outboxRepository,
+		Policy:           tierPolicy,
+		Window:           cfg.TierWindow,
+	}
+	campaignRepository := repository.CampaignRepository{
+		DBStorage: pgsStorage,
+	}
+	campaignService := service.CampaignService{
+		CampaignRepository: &campaignRepository,
+		TierNames:          tierPolicy.Names(),
+	}
+	ledger := service.Ledger{
+		UserBalanceRepository: &userBalanceRepository,
+		LedgerRepository:      &ledgerRepository,
+		OutboxRepository:      &outboxRepository,
+		PointsTTL:             cfg.PointsTTL,
+		ExpiringSoonWindow:    cfg.PointsExpiringSoon,
+		DebtPolicy:            cfg.DebtPolicy,
+	}
+	referralRepository := repository.ReferralRepository{
+		DBStorage: pgsStorage,
+	}
+	referralService := service.ReferralService{
+		ReferralRepository: &referralRepository,
+		Ledger:             &ledger,
+		ReferrerBonus:      decimal.NewFromFloat(cfg.ReferralBonus),
+		RefereeBonus:       decimal.NewFromFloat(cfg.ReferralWelcomeBonus),
+		MinAccrual:         decimal.NewFromFloat(cfg.ReferralMinAccrual),
+		MaxInvites:         cfg.ReferralMaxInvites,
+	}
+	userService := service.UserService{
+		UserRepository: &userRepository,
+		Referrals:      &referralService,
+	}
 	orderService := service.OrderService{
-		OrderRepository: &orderRepository,
+		OrderRepository:       &orderRepository,
+		UserBalanceRepository: &userBalanceRepository,
+		OutboxRepository:      &outboxRepository,
+		Ledger:                &ledger,
+		Tiers:                 &tierService,
+		Campaigns:             &campaignService,
+		Referrals:             &referralService,
+		RevisionWindow:        cfg.AccrualRevisionWindow,
+		RevisionInterval:      cfg.AccrualRevisionEvery,
 	}
 	withdrawRepository := repository.WithdrawRepository{
 		DBStorage: pgsStorage,
 	}
+	reservationRepository := repository.ReservationRepository{
+		DBStorage: pgsStorage,
+	}
 	withdrawService := service.WithdrawService{
-		WithdrawRepository: &withdrawRepository,
+		WithdrawRepository:    &withdrawRepository,
+		UserBalanceRepository: &userBalanceRepository,
+		OutboxRepository:      &outboxRepository,
+		ReservationRepository: &reservationRepository,
+		Ledger:                &ledger,
 	}
-	userBalanceRepository := repository.UserBalanceRepository{
+	reservationService := service.ReservationService{
+		ReservationRepository: &reservationRepository,
+		UserBalanceRepository: &userBalanceRepository,
+		OutboxRepository:      &outboxRepository,
+		Withdrawals:           &withdrawService,
+		Ledger:                &ledger,
+		DefaultTTL:            cfg.ReservationTTL,
+		MaxTTL:                cfg.ReservationMaxTTL,
+	}
+	transferRepository := repository.TransferRepository{
 		DBStorage: pgsStorage,
 	}
+	transferService := service.TransferService{
+		TransferRepository:    &transferRepository,
+		UserRepository:        &userRepository,
+		UserBalanceRepository: &userBalanceRepository,
+		OutboxRepository:      &outboxRepository,
+		ReservationRepository: &reservationRepository,
+		Ledger:                &ledger,
+		DailyLimit:            decimal.NewFromFloat(cfg.TransferDailyLimit),
+		DailyCount:            cfg.TransferDailyCount,
+	}
+	statementService := service.StatementService{
+		LedgerRepository: &ledgerRepository,
+		UserRepository:   &userRepository,
+	}
+	privacyService := service.PrivacyService{
+		UserRepository:        &userRepository,
+		OrderRepository:       &orderRepository,
+		WithdrawRepository:    &withdrawRepository,
+		LedgerRepository:      &ledgerRepository,
+		UserBalanceRepository: &userBalanceRepository,
+		Reservations:          &reservationService,
+	}
+	middleware.TokenRevoked = userRepository.TokensRevoked
+	webhookService := service.WebhookService{
+		WebhookRepository: &webhookRepository,
+	}
 	userBalanceService := service.UserBalanceService{
 		UserBalanceRepository: &userBalanceRepository,
+		Ledger:                &ledger,
+	}
+	metrics.RegisterStorageCollectors(pgsStorage.Conn, &orderRepository)
+
+	accrualClient := accrual.NewClient(cfg.AccrualSystemAddress, cfg.AccrualTimeout, cfg.AccrualRateLimit)
+	accrualClient.SetProcessTimeout(cfg.AccrualProcessTimeout)
+	accrualClient.SetCircuitBreaker(cfg.AccrualBreakerLimit, cfg.AccrualBreakerCooling)
+	accrualWorkers := accrual.NewWorkerLimit(cfg.AccrualWorkers)
+	accrualProcessor := accrual.NewProcessor(accrualClient, accrualWorkers, &orderService, cfg.AccrualPollInterval, cfg.AccrualQueueSize)
+	go accrualProcessor.Run()
+
+	webhookDispatcher := webhooks.NewDispatcher(&webhookRepository, cfg.WebhookPollInterval, cfg.WebhookTimeout, cfg.WebhookMaxAttempts)
+	go webhookDispatcher.Run()
+
+	outboxSink, closeOutboxSink, err := newOutboxSink(cfg, &webhooks.Sink{Store: &webhookRepository})
+
+	if err != nil {
+		fatal("Error while initializing outbox sinks", err)
+	}
+
+	outboxRelay := outbox.NewRelay(&outboxRepository, outboxSink, cfg.OutboxPollInterval, cfg.OutboxBatchSize)
+	go outboxRelay.Run()
+
+	jobs := scheduler.New()
+
+	if cfg.PointsTTL > 0 {
+		jobs.Add("points expiry", cfg.PointsExpiryInterval, ledger.ExpirePoints)
+	}
+
+	jobs.Add("tier recalculation", cfg.TierRecalcInterval, tierService.Recalculate)
+	jobs.Add("reservation expiry", cfg.ReservationSweepInterval, reservationService.ExpireReservations)
+
+	if cfg.OutboxRetention > 0 {
+		outboxPruner := service.OutboxPruner{OutboxRepository: &outboxRepository, Retention: cfg.OutboxRetention}
+		jobs.Add("outbox pruning", cfg.OutboxPruneInterval, outboxPruner.Prune)
+	}
+
+	jobs.Start()
+
+	eventBroker := events.NewBroker(pgsStorage.Conn.Config().ConnConfig.Copy())
+	go eventBroker.Run()
+
+	checker := health.NewChecker(cfg.ReadinessTimeout)
+	checker.AddCheck("database", pgsStorage.Ping)
+	checker.AddCheck("migrations", pgsStorage.CheckMigrations)
+
+	if cfg.CheckAccrualReady {
+		checker.AddCheck("accrual", accrualClient.Ping)
+		checker.AddCheck("accrual_circuit", accrualClient.CheckCircuit)
+	}
+
+	healthHandler := handlers.HealthHandler{
+		Checker: checker,
+	}
+
+	TokenGenerator := handlers.TokenGenerator{
+		SecretKey: cfg.JWTSecret,
+		TTL:       cfg.TokenTTL,
 	}
-	TokenGenerator := handlers.TokenGenerator{}
 	userHandler := handlers.UserHandler{
-		UserService:          &userService,
-		OrderService:         &orderService,
-		WithdrawService:      &withdrawService,
-		UserBalanceService:   &userBalanceService,
-		AccrualSystemAddress: cfg.AccrualSystemAddress,
-		DBConnectionString:   cfg.DatabaseDsn,
-		TokenGenerator:       &TokenGenerator,
+		UserService:        &userService,
+		OrderService:       &orderService,
+		WithdrawService:    &withdrawService,
+		UserBalanceService: &userBalanceService,
+		TierService:        &tierService,
+		ReferralService:    &referralService,
+		TransferService:    &transferService,
+		ReservationService: &reservationService,
+		StatementService:   &statementService,
+		PrivacyService:     &privacyService,
+		AccrualProcessor:   accrualProcessor,
+		EventBroker:        eventBroker,
+		DBConnectionString: cfg.DatabaseDsn,
+		TokenGenerator:     &TokenGenerator,
+		Cookie: handlers.CookieSettings{
+			Name:     cfg.CookieName,
+			MaxAge:   cfg.CookieMaxAge,
+			Secure:   cfg.CookieSecure,
+			SameSite: cfg.SameSite(),
+			Domain:   cfg.CookieDomain,
+		},
+	}
+
+	webhookHandler := handlers.WebhookHandler{
+		WebhookService: &webhookService,
+	}
+	campaignHandler := handlers.CampaignHandler{
+		CampaignService: &campaignService,
 	}
+	withdrawalHandler := handlers.WithdrawalHandler{
+		WithdrawalService: &withdrawService,
+	}
+	reservationHandler := handlers.ReservationHandler{
+		ReservationService: &reservationService,
+	}
+
+	cors := middleware.NewCORS(cfg.CORSOrigins)
+
+	rl := &reloader{
+		cfg:            cfg,
+		accrualClient:  accrualClient,
+		accrualWorkers: accrualWorkers,
+		cors:           cors,
+	}
+	reloadDone := make(chan struct{})
+	defer close(reloadDone)
+	go rl.watch(reloadDone)
 
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Tracing)
+	r.Use(middleware.RequestMetrics)
+	r.Use(cors.Handler)
 	r.Use(middleware.RequestDecompressor)
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/healthz", healthHandler.Liveness)
+	r.Get("/readyz", healthHandler.Readiness)
 	r.Route("/api/user", func(r chi.Router) {
 		r.Post("/register", userHandler.Register)
 		r.Post("/login", userHandler.Login)
 
 		r.With(middleware.TokenAuthMiddleware).Route("/", func(r chi.Router) {
 			r.Post("/orders", userHandler.SaveOrder)
+			r.Post("/orders/batch", userHandler.SaveOrders)
 			r.Get("/orders", userHandler.GetOrders)
+			r.Get("/orders/{number}", userHandler.GetOrder)
 			r.Get("/balance", userHandler.GetBalance)
+			r.Get("/tier", userHandler.GetTier)
+			r.Get("/referrals", userHandler.GetReferrals)
 			r.Post("/balance/withdraw", userHandler.Withdraw)
+			r.Post("/balance/transfer", userHandler.Transfer)
+			r.Post("/balance/reservations", userHandler.Reserve)
+			r.Get("/balance/reservations", userHandler.Reservations)
+			r.Delete("/balance/reservations/{order}", userHandler.ReleaseReservation)
+			r.Get("/transfers", userHandler.Transfers)
 			r.Get("/withdrawals", userHandler.Withdrawals)
+			r.Get("/statement", userHandler.Statement)
+			r.Get("/export", userHandler.Export)
+			r.Delete("/", userHandler.DeleteAccount)
+			r.Get("/events", userHandler.Events)
 		})
 	})
+	r.With(middleware.AdminAuthMiddleware).Route("/api/admin", func(r chi.Router) {
+		r.Post("/webhooks", webhookHandler.Create)
+		r.Get("/webhooks", webhookHandler.List)
+		r.Delete("/webhooks/{id}", webhookHandler.Delete)
+		r.Get("/webhooks/dead-letters", webhookHandler.DeadLetters)
+		r.Post("/webhooks/deliveries/{id}/retry", webhookHandler.Retry)
+		r.Post("/campaigns", campaignHandler.Create)
+		r.Get("/campaigns", campaignHandler.List)
+		r.Get("/campaigns/{id}", campaignHandler.Get)
+		r.Put("/campaigns/{id}", campaignHandler.Update)
+		r.Delete("/campaigns/{id}", campaignHandler.Delete)
+	})
+	r.With(middleware.ServiceAuthMiddleware).Route("/api/withdrawals/{id}", func(r chi.Router) {
+		r.Get("/", withdrawalHandler.History)
+		r.Post("/confirm", withdrawalHandler.Confirm)
+		r.Post("/refund", withdrawalHandler.Refund)
+	})
+	r.With(middleware.ServiceAuthMiddleware).Route("/api/reservations/{id}", func(r chi.Router) {
+		r.Post("/capture", reservationHandler.Capture)
+		r.Post("/release", reservationHandler.Release)
+	})
 
 	srv := &http.Server{
 		Addr:    cfg.ServerAddress,
 		Handler: r,
 	}
 
+	srv.RegisterOnShutdown(eventBroker.Close)
+
+	serverErr := make(chan error, 2)
+
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Error starting server: %s", err)
+			serverErr <- err
 		}
 	}()
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+	var adminSrv *http.Server
+
+	if cfg.AdminAddress != "" {
+		adminSrv = &http.Server{
+			Addr:    cfg.AdminAddress,
+			Handler: handlers.DebugRouter(),
+		}
+
+		go func() {
+			slog.Info("Starting admin server", "address", cfg.AdminAddress)
+
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Error starting admin server", "error", err)
+			}
+		}()
+	}
+
+	// Steps run in this order on shutdown, each under its own deadline: stop
+	// taking traffic, let accrual polling finish, then flush telemetry while
+	// the pool is still open and close the pool last.
+	shutdown.Add("readiness", cfg.ShutdownDrainDelay+time.Second, func(ctx context.Context) error {
+		checker.SetShuttingDown()
+
+		if cfg.ShutdownDrainDelay > 0 {
+			slog.Info("Waiting for load balancers to drain traffic", "delay", cfg.ShutdownDrainDelay)
+			time.Sleep(cfg.ShutdownDrainDelay)
+		}
+
+		return nil
+	})
+	shutdown.Add("http server", cfg.ShutdownTimeout, srv.Shutdown)
 
-	<-stop
+	if adminSrv != nil {
+		shutdown.Add("admin server", cfg.ShutdownTimeout, adminSrv.Shutdown)
+	}
 
-	log.Println("Shutting down server...")
+	shutdown.Add("accrual processor", cfg.AccrualDrainTimeout, accrualProcessor.Shutdown)
+	shutdown.Add("webhooks", cfg.WebhookTimeout, webhookDispatcher.Shutdown)
+	shutdown.Add("scheduled jobs", cfg.ShutdownTimeout, jobs.Shutdown)
+	shutdown.Add("outbox relay", cfg.OutboxHTTPTimeout, func(ctx context.Context) error {
+		if err := outboxRelay.Shutdown(ctx); err != nil {
+			return err
+		}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+		return closeOutboxSink()
+	})
+	shutdown.Add("tracing", cfg.TracesFlushTimeout, shutdownTracing)
+	shutdown.Add("database", cfg.DBCloseTimeout, func(ctx context.Context) error {
+		pgsStorage.Close()
+		return nil
+	})
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Error shutting down server: %s", err)
+	if cfg.HeapProfilePath != "" {
+		shutdown.Add("heap profile", cfg.ShutdownTimeout, func(ctx context.Context) error {
+			saveHeapProfile(cfg.HeapProfilePath)
+			return nil
+		})
 	}
 
-	log.Println("Server has exited.")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	exitCode := 0
+
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down server")
+	case err := <-serverErr:
+		slog.Error("Error starting server, shutting down", "error", err)
+		exitCode = 1
+	}
+
+	if err := shutdown.Shutdown(context.Background()); err != nil {
+		exitCode = 1
+	}
+
+	slog.Info("Server has exited", "code", exitCode)
+
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
 }
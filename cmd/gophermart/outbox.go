@@ -0,0 +1,39 @@
+package main
+
+import (
+	"gophermart/internal/config"
+	"gophermart/internal/outbox"
+	"log/slog"
+	"net/http"
+)
+
+// newOutboxSink builds the sinks listed in outbox_sinks after the webhook sink,
+// which is always there. The returned function closes the ones holding
+// resources.
+func newOutboxSink(cfg *config.Config, webhookSink outbox.Sink) (outbox.Sink, func() error, error) {
+	sinks := outbox.MultiSink{webhookSink}
+	closeSinks := func() error { return nil }
+
+	for _, name := range cfg.OutboxSinks {
+		switch name {
+		case outbox.SinkLog:
+			sinks = append(sinks, outbox.LogSink{})
+		case outbox.SinkFile:
+			fileSink, err := outbox.NewFileSink(cfg.OutboxFile)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			sinks = append(sinks, fileSink)
+			closeSinks = fileSink.Close
+		case outbox.SinkHTTP:
+			sinks = append(sinks, &outbox.HTTPSink{URL: cfg.OutboxHTTPURL, Client: &http.Client{Timeout: cfg.OutboxHTTPTimeout}})
+		case outbox.SinkMemory:
+			slog.Warn("Outbox events go to the in-memory broker and are not kept")
+			sinks = append(sinks, &outbox.BrokerSink{Producer: outbox.NewMemoryBroker(), Topic: cfg.OutboxTopic})
+		}
+	}
+
+	return sinks, closeSinks, nil
+}
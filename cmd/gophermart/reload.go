@@ -0,0 +1,76 @@
+package main
+
+import (
+	"gophermart/internal/accrual"
+	"gophermart/internal/config"
+	"gophermart/internal/logger"
+	"gophermart/internal/middleware"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type reloader struct {
+	cfg            *config.Config
+	accrualClient  *accrual.Client
+	accrualWorkers *accrual.WorkerLimit
+	cors           *middleware.CORS
+}
+
+func (rl *reloader) watch(done <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+			rl.reload()
+		}
+	}
+}
+
+func (rl *reloader) reload() {
+	slog.Info("Reloading configuration")
+
+	next, err := config.InitConfig()
+
+	if err != nil {
+		slog.Error("Configuration reload failed, keeping current settings", "error", err)
+		return
+	}
+
+	// An ephemeral JWT key generated at startup must not be reported as a
+	// change on every reload.
+	if next.JWTSecret == "" {
+		next.JWTSecret = rl.cfg.JWTSecret
+	}
+
+	reloadable, rejected := config.Diff(rl.cfg, next)
+
+	for _, key := range rejected {
+		slog.Warn("Configuration change requires a restart and was not applied", "option", key)
+	}
+
+	if len(reloadable) == 0 {
+		slog.Info("No reloadable configuration changes")
+		return
+	}
+
+	rl.cfg.ApplyReloadable(next)
+
+	if err := logger.SetLevel(rl.cfg.LogLevel); err != nil {
+		slog.Error("Failed to apply log level", "error", err)
+	}
+
+	rl.accrualClient.SetTimeout(rl.cfg.AccrualTimeout)
+	rl.accrualClient.SetRateLimit(rl.cfg.AccrualRateLimit)
+	rl.accrualClient.SetProcessTimeout(rl.cfg.AccrualProcessTimeout)
+	rl.accrualWorkers.Resize(rl.cfg.AccrualWorkers)
+	rl.cors.SetOrigins(rl.cfg.CORSOrigins)
+
+	slog.Info("Configuration reloaded", "changed", reloadable)
+}
@@ -1,38 +1,222 @@
 package accrual
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	"gophermart/internal/metrics"
+	"gophermart/internal/tracing"
+	"log/slog"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
+const defaultProcessTimeout = time.Minute
+
+// Client settings other than the address can be changed at runtime, so
+// timeouts are applied per request instead of on the resty client.
+type Client struct {
+	client         *resty.Client
+	limiter        *rate.Limiter
+	timeout        atomic.Int64
+	processTimeout atomic.Int64
+	breaker        breaker
+}
+
+func NewClient(address string, timeout time.Duration, rateLimit float64) *Client {
+	c := &Client{
+		client:  resty.New().SetBaseURL(address),
+		limiter: rate.NewLimiter(rateLimitToLimit(rateLimit), 1),
+	}
+	c.SetTimeout(timeout)
+	c.SetProcessTimeout(defaultProcessTimeout)
+
+	return c
+}
+
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout.Store(int64(timeout))
+}
+
+func (c *Client) SetRateLimit(rateLimit float64) {
+	c.limiter.SetLimit(rateLimitToLimit(rateLimit))
+}
+
+// SetCircuitBreaker opens the circuit after threshold consecutive failed
+// calls for cooldown; zero threshold disables it.
+func (c *Client) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	c.breaker.configure(threshold, cooldown)
+}
+
+// CircuitState returns the state of the circuit and, while it is open, when
+// the next probe call is let through.
+func (c *Client) CircuitState() (string, time.Time) {
+	return c.breaker.state(time.Now())
+}
+
+// CheckCircuit is a readiness check failing while the circuit is open.
+func (c *Client) CheckCircuit(ctx context.Context) error {
+	state, until := c.CircuitState()
+
+	if state == CircuitOpen {
+		return fmt.Errorf("%w until %s", ErrCircuitOpen, until.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (c *Client) SetProcessTimeout(timeout time.Duration) {
+	c.processTimeout.Store(int64(timeout))
+}
+
+// ProcessTimeout bounds the whole processing of one order, including waiting
+// for a worker slot, the rate limiter and the database updates.
+func (c *Client) ProcessTimeout() time.Duration {
+	return time.Duration(c.processTimeout.Load())
+}
+
+func (c *Client) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := time.Duration(c.timeout.Load()); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+func rateLimitToLimit(rateLimit float64) rate.Limit {
+	if rateLimit <= 0 {
+		return rate.Inf
+	}
+
+	return rate.Limit(rateLimit)
+}
+
 type RegisterResponse struct {
 	Order   string          `json:"order"`
 	Status  string          `json:"status"`
 	Accrual decimal.Decimal `json:"accrual"`
 }
 
-func GetOrderInfo(accrualServerAddress string, orderNumber string) (RegisterResponse, error) {
+func (c *Client) GetOrderInfo(ctx context.Context, orderNumber string) (RegisterResponse, error) {
 	var registerResponse RegisterResponse
-	req := resty.New().
-		SetBaseURL(accrualServerAddress).
-		R().
+	start := time.Now()
+	outcome := metrics.AccrualOutcomeError
+	// Failures count towards opening the circuit unless the caller gave up.
+	result := callFailed
+	allowed := c.breaker.allow(start)
+
+	ctx, span := tracing.Tracer().Start(ctx, "accrual GET /api/orders/{number}",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.OrderNumberKey.String(orderNumber)),
+	)
+
+	defer func() {
+		if allowed {
+			if result == callFailed && ctx.Err() != nil {
+				result = callAbandoned
+			}
+
+			c.breaker.record(result, time.Now())
+		}
+
+		span.SetAttributes(attribute.String("accrual.outcome", outcome))
+
+		if outcome != metrics.AccrualOutcomeOK && outcome != metrics.AccrualOutcomeNotRegistered {
+			span.SetStatus(codes.Error, outcome)
+		}
+
+		span.End()
+		slog.DebugContext(ctx, "Accrual system call finished",
+			"order", orderNumber, "outcome", outcome, "duration", time.Since(start))
+		metrics.AccrualRequestsTotal.WithLabelValues(outcome).Inc()
+		metrics.AccrualRequestDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	if !allowed {
+		outcome = metrics.AccrualOutcomeCircuitOpen
+		return registerResponse, ErrCircuitOpen
+	}
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		span.RecordError(err)
+		result = callAbandoned
+		return registerResponse, err
+	}
+
+	reqCtx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req := c.client.R().
+		SetContext(reqCtx).
 		SetHeader("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := req.Get("/api/orders/" + orderNumber)
 
 	if err != nil {
+		span.RecordError(err)
 		return registerResponse, err
 	}
 
-	if resp.StatusCode() != http.StatusOK {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode()))
+
+	if resp.StatusCode() < http.StatusInternalServerError {
+		result = callSucceeded
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		outcome = metrics.AccrualOutcomeNotRegistered
+		return registerResponse, err
+	case http.StatusTooManyRequests:
+		outcome = metrics.AccrualOutcomeRateLimited
+		slog.WarnContext(ctx, "Accrual system rate limit exceeded",
+			"order", orderNumber, "retry_after", resp.Header().Get("Retry-After"))
+		return registerResponse, err
+	default:
+		outcome = metrics.AccrualOutcomeUnexpectedStatus
+		slog.WarnContext(ctx, "Unexpected accrual system response", "order", orderNumber, "status", resp.StatusCode())
 		return registerResponse, err
 	}
 
 	if err := json.Unmarshal(resp.Body(), &registerResponse); err != nil {
+		span.RecordError(err)
 		return registerResponse, err
 	}
 
+	span.SetAttributes(attribute.String("accrual.status", registerResponse.Status))
+
+	outcome = metrics.AccrualOutcomeOK
+
 	return registerResponse, nil
 }
+
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	resp, err := c.client.R().
+		SetContext(ctx).
+		Get("/")
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		return fmt.Errorf("accrual system responded with status %d", resp.StatusCode())
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestGetOrderInfo_PropagatesTraceContext(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"order":"12345678903","status":"PROCESSED","accrual":500}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewClient(server.URL, time.Second, 0).GetOrderInfo(context.Background(), "12345678903")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Status != "PROCESSED" || resp.Accrual.IntPart() != 500 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	if traceparent == "" {
+		t.Error("Expected traceparent header to be sent to accrual system")
+	}
+}
+
+func TestGetOrderInfo_CircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	var calls atomic.Int32
+
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, time.Second, 0)
+	client.SetCircuitBreaker(2, 50*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, _ = client.GetOrderInfo(ctx, "12345678903")
+	}
+
+	if state, _ := client.CircuitState(); state != CircuitOpen {
+		t.Fatalf("Expected the circuit to open after two failures, got %s", state)
+	}
+
+	if _, err := client.GetOrderInfo(ctx, "12345678903"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	if calls.Load() != 2 {
+		t.Fatalf("Expected no call while the circuit is open, got %d calls", calls.Load())
+	}
+
+	if err := client.CheckCircuit(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the readiness check to fail, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	failing.Store(false)
+
+	if state, _ := client.CircuitState(); state != CircuitHalfOpen {
+		t.Fatalf("Expected the circuit to be half-open after the cooldown, got %s", state)
+	}
+
+	if _, err := client.GetOrderInfo(ctx, "12345678903"); err != nil {
+		t.Fatalf("Expected the probe to pass, got %v", err)
+	}
+
+	if state, _ := client.CircuitState(); state != CircuitClosed {
+		t.Fatalf("Expected a successful probe to close the circuit, got %s", state)
+	}
+}
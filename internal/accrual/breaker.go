@@ -0,0 +1,103 @@
+package accrual
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit states as reported by Client.CircuitState.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("accrual system circuit is open")
+
+type callResult int
+
+const (
+	callSucceeded callResult = iota
+	callFailed
+	// callAbandoned is a call cancelled by its caller, which says nothing
+	// about the accrual system.
+	callAbandoned
+)
+
+// breaker stops calls to the accrual system after threshold consecutive
+// failures. Once cooldown has passed a single probe call is let through; its
+// success closes the circuit and its failure opens it again. A zero threshold
+// disables the breaker.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *breaker) configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	b.cooldown = cooldown
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.stateLocked(now) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+
+		b.probing = true
+
+		return true
+	}
+
+	return false
+}
+
+func (b *breaker) record(result callResult, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	switch result {
+	case callSucceeded:
+		b.failures = 0
+	case callFailed:
+		b.failures++
+
+		if b.threshold > 0 && b.failures >= b.threshold {
+			b.openUntil = now.Add(b.cooldown)
+		}
+	}
+}
+
+func (b *breaker) state(now time.Time) (string, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.stateLocked(now), b.openUntil
+}
+
+func (b *breaker) stateLocked(now time.Time) string {
+	switch {
+	case b.threshold == 0 || b.failures < b.threshold:
+		return CircuitClosed
+	case now.Before(b.openUntil):
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
@@ -0,0 +1,256 @@
+package accrual
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/trace"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/metrics"
+	"gophermart/internal/tracing"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses reported by the accrual system.
+const (
+	StatusRegistered = "REGISTERED"
+	StatusProcessing = "PROCESSING"
+	StatusInvalid    = "INVALID"
+	StatusProcessed  = "PROCESSED"
+)
+
+// OrderStore is the part of the order service the processor needs.
+type OrderStore interface {
+	GetPendingOrders(ctx context.Context, limit int) ([]interfaces.PendingOrder, error)
+	// ApplyAccrual returns the change of the order's accrual, which is
+	// negative when a revision lowers it.
+	ApplyAccrual(ctx context.Context, orderNumber string, status string, accrual decimal.Decimal) (decimal.Decimal, error)
+	MarkPolled(ctx context.Context, orderNumber string) error
+}
+
+type job struct {
+	ctx    context.Context
+	number string
+	userID int
+}
+
+// Processor polls the accrual system for orders that have not reached a final
+// status. The orders table is the checkpoint: an order whose processing is
+// interrupted stays NEW or PROCESSING and is picked up by the next scan, here
+// or on another replica.
+type Processor struct {
+	client       *Client
+	workers      *WorkerLimit
+	store        OrderStore
+	pollInterval time.Duration
+	batchSize    int
+	queue        chan job
+
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+	wg       sync.WaitGroup
+	lastScan atomic.Int64
+
+	// dispatchCtx is cancelled when shutdown starts: no new orders are taken.
+	// workCtx is cancelled when the drain deadline expires: in-flight orders
+	// are abandoned and their transactions rolled back.
+	dispatchCtx  context.Context
+	stopDispatch context.CancelFunc
+	workCtx      context.Context
+	cancelWork   context.CancelFunc
+	done         chan struct{}
+}
+
+func NewProcessor(client *Client, workers *WorkerLimit, store OrderStore, pollInterval time.Duration, queueSize int) *Processor {
+	p := &Processor{
+		client:       client,
+		workers:      workers,
+		store:        store,
+		pollInterval: pollInterval,
+		batchSize:    queueSize,
+		queue:        make(chan job, queueSize),
+		inFlight:     map[string]struct{}{},
+		done:         make(chan struct{}),
+	}
+	p.dispatchCtx, p.stopDispatch = context.WithCancel(context.Background())
+	p.workCtx, p.cancelWork = context.WithCancel(context.Background())
+
+	return p
+}
+
+// Enqueue schedules an order for immediate processing. It never blocks: when
+// the queue is full the order waits for the next scan.
+func (p *Processor) Enqueue(ctx context.Context, orderNumber string, userID int) {
+	if p.dispatchCtx.Err() != nil {
+		return
+	}
+
+	select {
+	case p.queue <- job{ctx: context.WithoutCancel(ctx), number: orderNumber, userID: userID}:
+	default:
+		slog.DebugContext(ctx, "Accrual queue is full, order left for the next scan", "order", orderNumber)
+	}
+}
+
+// Run dispatches queued and pending orders until Shutdown is called.
+func (p *Processor) Run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	p.scan()
+
+	for {
+		select {
+		case <-p.dispatchCtx.Done():
+			return
+		case j := <-p.queue:
+			p.dispatch(j)
+		case <-ticker.C:
+			p.scan()
+		}
+	}
+}
+
+// Shutdown stops taking new orders and waits for in-flight ones. When ctx
+// expires first, in-flight orders are cancelled and left for the next start.
+func (p *Processor) Shutdown(ctx context.Context) error {
+	p.stopDispatch()
+	<-p.done
+
+	idle := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		p.cancelWork()
+		return nil
+	case <-ctx.Done():
+		p.cancelWork()
+		<-idle
+
+		return ctx.Err()
+	}
+}
+
+// NextScan returns when pending orders are polled next.
+func (p *Processor) NextScan() time.Time {
+	return time.Unix(0, p.lastScan.Load()).Add(p.pollInterval)
+}
+
+func (p *Processor) scan() {
+	p.lastScan.Store(time.Now().UnixNano())
+
+	orders, err := p.store.GetPendingOrders(p.dispatchCtx, p.batchSize)
+
+	if err != nil {
+		if p.dispatchCtx.Err() == nil {
+			slog.Error("Failed to load pending orders", "error", err)
+		}
+
+		return
+	}
+
+	for _, order := range orders {
+		p.dispatch(job{ctx: context.Background(), number: order.Number, userID: order.UserID})
+	}
+}
+
+func (p *Processor) dispatch(j job) {
+	p.mu.Lock()
+
+	if _, ok := p.inFlight[j.number]; ok {
+		p.mu.Unlock()
+		return
+	}
+
+	p.inFlight[j.number] = struct{}{}
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go p.process(j)
+}
+
+func (p *Processor) process(j job) {
+	defer p.wg.Done()
+	defer p.forget(j.number)
+
+	ctx, cancel := context.WithTimeout(j.ctx, p.client.ProcessTimeout())
+	defer cancel()
+
+	stop := context.AfterFunc(p.workCtx, cancel)
+	defer stop()
+
+	if err := p.acquire(ctx); err != nil {
+		slog.DebugContext(ctx, "No accrual worker available", "order", j.number, "error", err)
+		return
+	}
+	defer p.workers.Release()
+
+	ctx, span := tracing.Tracer().Start(ctx, "accrual.process_order", trace.WithAttributes(
+		tracing.OrderNumberKey.String(j.number),
+		tracing.UserIDKey.Int(j.userID),
+	))
+	defer span.End()
+
+	registerResponse, err := p.client.GetOrderInfo(ctx, j.number)
+
+	if ctx.Err() == nil {
+		if err := p.store.MarkPolled(ctx, j.number); err != nil {
+			slog.ErrorContext(ctx, "Failed to record poll time", "order", j.number, "error", err)
+		}
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get order info", "order", j.number, "error", err)
+		return
+	}
+
+	if registerResponse.Order == "" {
+		slog.InfoContext(ctx, "Order not found in accrual service", "order", j.number)
+		return
+	}
+
+	status := registerResponse.Status
+
+	if status == StatusRegistered {
+		status = StatusProcessing
+	}
+
+	delta, err := p.store.ApplyAccrual(ctx, j.number, status, registerResponse.Accrual)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to apply accrual", "order", j.number, "error", err)
+		return
+	}
+
+	if delta.IsPositive() {
+		accrued, _ := delta.Float64()
+		metrics.PointsAccruedTotal.Add(accrued)
+	}
+}
+
+// acquire waits for a worker slot, giving up as soon as shutdown starts:
+// orders that have not started yet are cheaper to leave for the next run.
+func (p *Processor) acquire(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(p.dispatchCtx, cancel)
+	defer stop()
+
+	return p.workers.Acquire(ctx)
+}
+
+func (p *Processor) forget(orderNumber string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.inFlight, orderNumber)
+}
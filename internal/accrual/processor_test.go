@@ -0,0 +1,103 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type applied struct {
+	number string
+	status string
+}
+
+type fakeOrderStore struct {
+	mu      sync.Mutex
+	applied []applied
+}
+
+func (s *fakeOrderStore) GetPendingOrders(ctx context.Context, limit int) ([]interfaces.PendingOrder, error) {
+	return nil, nil
+}
+
+func (s *fakeOrderStore) ApplyAccrual(ctx context.Context, orderNumber string, status string, accrual decimal.Decimal) (decimal.Decimal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.applied = append(s.applied, applied{number: orderNumber, status: status})
+
+	return accrual, nil
+}
+
+func (s *fakeOrderStore) MarkPolled(ctx context.Context, orderNumber string) error {
+	return nil
+}
+
+func (s *fakeOrderStore) snapshot() []applied {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]applied(nil), s.applied...)
+}
+
+func TestProcessor_DrainsInFlightOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"order":"12345678903","status":"REGISTERED"}`))
+	}))
+	defer server.Close()
+
+	store := &fakeOrderStore{}
+	p := NewProcessor(NewClient(server.URL, time.Second, 0), NewWorkerLimit(1), store, time.Hour, 10)
+	go p.Run()
+
+	p.Enqueue(context.Background(), "12345678903", 1)
+	time.Sleep(10 * time.Millisecond)
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := store.snapshot()
+
+	if len(got) != 1 || got[0].status != StatusProcessing {
+		t.Errorf("Expected in-flight order to be stored as PROCESSING, got %+v", got)
+	}
+}
+
+func TestProcessor_ShutdownDeadlineCancelsInFlightOrders(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	store := &fakeOrderStore{}
+	p := NewProcessor(NewClient(server.URL, 0, 0), NewWorkerLimit(1), store, time.Hour, 10)
+	go p.Run()
+
+	p.Enqueue(context.Background(), "12345678903", 1)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline error, got %v", err)
+	}
+
+	if got := store.snapshot(); len(got) != 0 {
+		t.Errorf("Expected cancelled order to be left pending, got %+v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package accrual
+
+import (
+	"context"
+	"sync"
+)
+
+// WorkerLimit bounds the number of orders processed against the accrual
+// system at the same time. Unlike a buffered channel it can be resized while
+// workers hold slots.
+type WorkerLimit struct {
+	mu      sync.Mutex
+	size    int
+	active  int
+	changed chan struct{}
+}
+
+func NewWorkerLimit(size int) *WorkerLimit {
+	return &WorkerLimit{size: size, changed: make(chan struct{})}
+}
+
+func (wl *WorkerLimit) Acquire(ctx context.Context) error {
+	for {
+		wl.mu.Lock()
+
+		if wl.active < wl.size {
+			wl.active++
+			wl.mu.Unlock()
+
+			return nil
+		}
+
+		changed := wl.changed
+		wl.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (wl *WorkerLimit) Release() {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	wl.active--
+	wl.notify()
+}
+
+func (wl *WorkerLimit) Resize(size int) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	wl.size = size
+	wl.notify()
+}
+
+func (wl *WorkerLimit) Size() int {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	return wl.size
+}
+
+func (wl *WorkerLimit) notify() {
+	close(wl.changed)
+	wl.changed = make(chan struct{})
+}
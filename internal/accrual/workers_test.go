@@ -0,0 +1,38 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWorkerLimit_Resize(t *testing.T) {
+	wl := NewWorkerLimit(1)
+
+	if err := wl.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := wl.Acquire(ctx); err == nil {
+		t.Fatal("Expected second acquire to block while the limit is 1")
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- wl.Acquire(context.Background())
+	}()
+
+	wl.Resize(2)
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected waiting worker to acquire after resize")
+	}
+}
@@ -0,0 +1,98 @@
+package campaigns
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"time"
+)
+
+var ErrInvalidCampaign = errors.New("invalid campaign")
+
+// Order is what campaign conditions are evaluated against.
+type Order struct {
+	Accrual     decimal.Decimal
+	FirstOrder  bool
+	Tier        string
+	ProcessedAt time.Time
+}
+
+// Validate checks a campaign against the configured tier names.
+func Validate(campaign interfaces.Campaign, tierNames []string) error {
+	if campaign.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCampaign)
+	}
+
+	if campaign.StartsAt.IsZero() || !campaign.EndsAt.After(campaign.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidCampaign)
+	}
+
+	if campaign.Conditions.MinAccrual < 0 {
+		return fmt.Errorf("%w: min_accrual must not be negative", ErrInvalidCampaign)
+	}
+
+	for _, tier := range campaign.Conditions.Tiers {
+		if !contains(tierNames, tier) {
+			return fmt.Errorf("%w: unknown tier %q", ErrInvalidCampaign, tier)
+		}
+	}
+
+	effects := campaign.Effects
+
+	if effects.Multiplier != 0 && effects.Multiplier <= 1 {
+		return fmt.Errorf("%w: multiplier must be greater than 1", ErrInvalidCampaign)
+	}
+
+	if effects.FlatBonus < 0 {
+		return fmt.Errorf("%w: flat_bonus must not be negative", ErrInvalidCampaign)
+	}
+
+	if effects.Multiplier == 0 && effects.FlatBonus == 0 {
+		return fmt.Errorf("%w: a multiplier or a flat bonus is required", ErrInvalidCampaign)
+	}
+
+	return nil
+}
+
+// Bonus returns the points a campaign grants for an order, zero when a
+// condition is not met. Multipliers apply to the accrual alone, so campaigns
+// and tier bonuses never compound.
+func Bonus(campaign interfaces.Campaign, order Order) decimal.Decimal {
+	conditions := campaign.Conditions
+
+	if order.ProcessedAt.Before(campaign.StartsAt) || !order.ProcessedAt.Before(campaign.EndsAt) {
+		return decimal.Zero
+	}
+
+	if conditions.FirstOrder && !order.FirstOrder {
+		return decimal.Zero
+	}
+
+	if order.Accrual.LessThan(decimal.NewFromFloat(conditions.MinAccrual)) {
+		return decimal.Zero
+	}
+
+	if len(conditions.Tiers) > 0 && !contains(conditions.Tiers, order.Tier) {
+		return decimal.Zero
+	}
+
+	bonus := decimal.NewFromFloat(campaign.Effects.FlatBonus)
+
+	if campaign.Effects.Multiplier > 1 {
+		extra := decimal.NewFromFloat(campaign.Effects.Multiplier).Sub(decimal.NewFromInt(1))
+		bonus = bonus.Add(order.Accrual.Mul(extra))
+	}
+
+	return bonus.Round(2)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package campaigns
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"testing"
+	"time"
+)
+
+var (
+	weekendStart = time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	weekendEnd   = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+)
+
+func TestBonus(t *testing.T) {
+	doublePoints := interfaces.Campaign{
+		Name: "double weekend", StartsAt: weekendStart, EndsAt: weekendEnd,
+		Effects: interfaces.CampaignEffects{Multiplier: 2},
+	}
+	firstOrder := interfaces.Campaign{
+		Name: "first order", StartsAt: weekendStart, EndsAt: weekendEnd,
+		Conditions: interfaces.CampaignConditions{FirstOrder: true, MinAccrual: 50, Tiers: []string{"bronze"}},
+		Effects:    interfaces.CampaignEffects{FlatBonus: 100},
+	}
+	saturday := weekendStart.Add(12 * time.Hour)
+
+	tests := []struct {
+		name     string
+		campaign interfaces.Campaign
+		order    Order
+		want     string
+	}{
+		{name: "multiplier", campaign: doublePoints, order: Order{Accrual: decimal.RequireFromString("120.5"), ProcessedAt: saturday}, want: "120.5"},
+		{name: "before window", campaign: doublePoints, order: Order{Accrual: decimal.NewFromInt(100), ProcessedAt: weekendStart.Add(-time.Second)}, want: "0"},
+		{name: "window end is exclusive", campaign: doublePoints, order: Order{Accrual: decimal.NewFromInt(100), ProcessedAt: weekendEnd}, want: "0"},
+		{name: "flat bonus", campaign: firstOrder, order: Order{Accrual: decimal.NewFromInt(50), FirstOrder: true, Tier: "bronze", ProcessedAt: saturday}, want: "100"},
+		{name: "not first order", campaign: firstOrder, order: Order{Accrual: decimal.NewFromInt(50), Tier: "bronze", ProcessedAt: saturday}, want: "0"},
+		{name: "accrual too low", campaign: firstOrder, order: Order{Accrual: decimal.NewFromInt(49), FirstOrder: true, Tier: "bronze", ProcessedAt: saturday}, want: "0"},
+		{name: "other tier", campaign: firstOrder, order: Order{Accrual: decimal.NewFromInt(50), FirstOrder: true, Tier: "gold", ProcessedAt: saturday}, want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Bonus(tt.campaign, tt.order); !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("Bonus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := interfaces.Campaign{
+		Name: "double weekend", StartsAt: weekendStart, EndsAt: weekendEnd,
+		Conditions: interfaces.CampaignConditions{Tiers: []string{"gold"}},
+		Effects:    interfaces.CampaignEffects{Multiplier: 2},
+	}
+
+	if err := Validate(valid, []string{"bronze", "gold"}); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	invalid := map[string]func(c *interfaces.Campaign){
+		"no name":        func(c *interfaces.Campaign) { c.Name = "" },
+		"empty window":   func(c *interfaces.Campaign) { c.EndsAt = c.StartsAt },
+		"unknown tier":   func(c *interfaces.Campaign) { c.Conditions.Tiers = []string{"platinum"} },
+		"low multiplier": func(c *interfaces.Campaign) { c.Effects.Multiplier = 0.5 },
+		"no effect":      func(c *interfaces.Campaign) { c.Effects = interfaces.CampaignEffects{} },
+	}
+
+	for name, modify := range invalid {
+		campaign := valid
+		modify(&campaign)
+
+		if err := Validate(campaign, []string{"bronze", "gold"}); !errors.Is(err, ErrInvalidCampaign) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidCampaign", name, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type option struct {
+	key        string
+	env        string
+	flags      []string
+	usage      string
+	secret     bool
+	reloadable bool
+	value      flag.Value
+}
+
+func (cfg *Config) options() []option {
+	return []option{
+		{key: "run_address", env: "RUN_ADDRESS", flags: []string{"a"}, usage: "Адрес HTTP-сервера", value: (*stringValue)(&cfg.ServerAddress)},
+		{key: "admin_address", env: "ADMIN_ADDRESS", flags: []string{"admin-address"}, usage: "Адрес служебного HTTP-сервера с pprof (пусто — отключен)", value: (*stringValue)(&cfg.AdminAddress)},
+		{key: "readiness_timeout", env: "READINESS_TIMEOUT", flags: []string{"readiness-timeout"}, usage: "Таймаут проверок готовности", value: (*durationValue)(&cfg.ReadinessTimeout)},
+		{key: "readiness_check_accrual", env: "READINESS_CHECK_ACCRUAL", flags: []string{"readiness-check-accrual"}, usage: "Проверять доступность системы расчета и состояние предохранителя в /readyz", value: (*boolValue)(&cfg.CheckAccrualReady)},
+		{key: "shutdown_drain_delay", env: "SHUTDOWN_DRAIN_DELAY", flags: []string{"shutdown-drain-delay"}, usage: "Пауза между снятием готовности и остановкой сервера", value: (*durationValue)(&cfg.ShutdownDrainDelay)},
+		{key: "shutdown_timeout", env: "SHUTDOWN_TIMEOUT", flags: []string{"shutdown-timeout"}, usage: "Таймаут остановки HTTP-сервера", value: (*durationValue)(&cfg.ShutdownTimeout)},
+		{key: "db_close_timeout", env: "DB_CLOSE_TIMEOUT", flags: []string{"db-close-timeout"}, usage: "Таймаут закрытия пула соединений при остановке", value: (*durationValue)(&cfg.DBCloseTimeout)},
+
+		{key: "database_uri", env: "DATABASE_URI", flags: []string{"b", "d"}, usage: "Строка подключения к базе данных", secret: true, value: (*stringValue)(&cfg.DatabaseDsn)},
+		{key: "db_max_conns", env: "DB_MAX_CONNS", flags: []string{"db-max-conns"}, usage: "Максимальный размер пула соединений", value: (*intValue)(&cfg.DBMaxConns)},
+		{key: "db_min_conns", env: "DB_MIN_CONNS", flags: []string{"db-min-conns"}, usage: "Минимальный размер пула соединений", value: (*intValue)(&cfg.DBMinConns)},
+		{key: "db_max_conn_lifetime", env: "DB_MAX_CONN_LIFETIME", flags: []string{"db-max-conn-lifetime"}, usage: "Максимальное время жизни соединения", value: (*durationValue)(&cfg.DBMaxConnLifetime)},
+		{key: "db_max_conn_idle_time", env: "DB_MAX_CONN_IDLE_TIME", flags: []string{"db-max-conn-idle-time"}, usage: "Максимальное время простоя соединения", value: (*durationValue)(&cfg.DBMaxConnIdleTime)},
+		{key: "db_health_check_period", env: "DB_HEALTH_CHECK_PERIOD", flags: []string{"db-health-check-period"}, usage: "Период проверки соединений пула", value: (*durationValue)(&cfg.DBHealthCheckEvery)},
+
+		{key: "jwt_secret", env: "JWT_SECRET", flags: []string{"jwt-secret"}, usage: "Ключ подписи JWT", secret: true, value: (*stringValue)(&cfg.JWTSecret)},
+		{key: "jwt_previous_secrets", env: "JWT_PREVIOUS_SECRETS", flags: []string{"jwt-previous-secrets"}, usage: "Предыдущие ключи JWT, принимаемые при проверке (через запятую)", secret: true, value: (*stringSliceValue)(&cfg.JWTPreviousSecrets)},
+		{key: "token_ttl", env: "TOKEN_TTL", flags: []string{"token-ttl"}, usage: "Время жизни JWT", value: (*durationValue)(&cfg.TokenTTL)},
+		{key: "cookie_name", env: "COOKIE_NAME", flags: []string{"cookie-name"}, usage: "Имя cookie с токеном", value: (*stringValue)(&cfg.CookieName)},
+		{key: "cookie_max_age", env: "COOKIE_MAX_AGE", flags: []string{"cookie-max-age"}, usage: "Время жизни cookie с токеном", value: (*durationValue)(&cfg.CookieMaxAge)},
+		{key: "cookie_secure", env: "COOKIE_SECURE", flags: []string{"cookie-secure"}, usage: "Выставлять флаг Secure у cookie", value: (*boolValue)(&cfg.CookieSecure)},
+		{key: "cookie_same_site", env: "COOKIE_SAME_SITE", flags: []string{"cookie-same-site"}, usage: "Атрибут SameSite у cookie (lax, strict, none)", value: (*stringValue)(&cfg.CookieSameSite)},
+		{key: "cookie_domain", env: "COOKIE_DOMAIN", flags: []string{"cookie-domain"}, usage: "Домен cookie", value: (*stringValue)(&cfg.CookieDomain)},
+		{key: "admin_token", env: "ADMIN_TOKEN", flags: []string{"admin-token"}, usage: "Токен административного API (пусто — API отключен)", secret: true, value: (*stringValue)(&cfg.AdminToken)},
+		{key: "service_tokens", env: "SERVICE_TOKENS", flags: []string{"service-tokens"}, usage: "Токены сервисов для API списаний и удержаний name:token (через запятую)", secret: true, value: (*stringSliceValue)(&cfg.ServiceTokens)},
+
+		{key: "accrual_system_address", env: "ACCRUAL_SYSTEM_ADDRESS", flags: []string{"r"}, usage: "Адрес системы расчета", value: (*stringValue)(&cfg.AccrualSystemAddress)},
+		{key: "accrual_timeout", env: "ACCRUAL_TIMEOUT", flags: []string{"accrual-timeout"}, usage: "Таймаут запроса к системе расчета", reloadable: true, value: (*durationValue)(&cfg.AccrualTimeout)},
+		{key: "accrual_rate_limit", env: "ACCRUAL_RATE_LIMIT", flags: []string{"accrual-rate-limit"}, usage: "Лимит запросов к системе расчета в секунду (0 — без ограничения)", reloadable: true, value: (*floatValue)(&cfg.AccrualRateLimit)},
+		{key: "accrual_workers", env: "ACCRUAL_WORKERS", flags: []string{"accrual-workers"}, usage: "Число одновременных обработчиков начислений", reloadable: true, value: (*intValue)(&cfg.AccrualWorkers)},
+		{key: "accrual_process_timeout", env: "ACCRUAL_PROCESS_TIMEOUT", flags: []string{"accrual-process-timeout"}, usage: "Таймаут обработки одного заказа", reloadable: true, value: (*durationValue)(&cfg.AccrualProcessTimeout)},
+		{key: "accrual_poll_interval", env: "ACCRUAL_POLL_INTERVAL", flags: []string{"accrual-poll-interval"}, usage: "Период опроса системы расчета по необработанным заказам", value: (*durationValue)(&cfg.AccrualPollInterval)},
+		{key: "accrual_queue_size", env: "ACCRUAL_QUEUE_SIZE", flags: []string{"accrual-queue-size"}, usage: "Размер очереди заказов на обработку", value: (*intValue)(&cfg.AccrualQueueSize)},
+		{key: "accrual_drain_timeout", env: "ACCRUAL_DRAIN_TIMEOUT", flags: []string{"accrual-drain-timeout"}, usage: "Время на завершение обработки заказов при остановке", value: (*durationValue)(&cfg.AccrualDrainTimeout)},
+		{key: "accrual_revision_window", env: "ACCRUAL_REVISION_WINDOW", flags: []string{"accrual-revision-window"}, usage: "Сколько опрашивать обработанные заказы на пересмотр начисления (0 — не опрашивать)", value: (*durationValue)(&cfg.AccrualRevisionWindow)},
+		{key: "accrual_revision_interval", env: "ACCRUAL_REVISION_INTERVAL", flags: []string{"accrual-revision-interval"}, usage: "Интервал повторного опроса обработанных заказов", value: (*durationValue)(&cfg.AccrualRevisionEvery)},
+		{key: "accrual_breaker_threshold", env: "ACCRUAL_BREAKER_THRESHOLD", flags: []string{"accrual-breaker-threshold"}, usage: "Число ошибок подряд, после которого запросы к системе расчета приостанавливаются (0 — не приостанавливать)", value: (*intValue)(&cfg.AccrualBreakerLimit)},
+		{key: "accrual_breaker_cooldown", env: "ACCRUAL_BREAKER_COOLDOWN", flags: []string{"accrual-breaker-cooldown"}, usage: "Пауза перед пробным запросом к системе расчета после серии ошибок", value: (*durationValue)(&cfg.AccrualBreakerCooling)},
+		{key: "debt_policy", env: "DEBT_POLICY", flags: []string{"debt-policy"}, usage: "Списание при уменьшении начисления сверх баланса: negative — в минус, debt — в долг", value: (*stringValue)(&cfg.DebtPolicy)},
+
+		{key: "webhook_poll_interval", env: "WEBHOOK_POLL_INTERVAL", flags: []string{"webhook-poll-interval"}, usage: "Интервал проверки очереди вебхуков", value: (*durationValue)(&cfg.WebhookPollInterval)},
+		{key: "webhook_timeout", env: "WEBHOOK_TIMEOUT", flags: []string{"webhook-timeout"}, usage: "Таймаут запроса вебхука", value: (*durationValue)(&cfg.WebhookTimeout)},
+		{key: "webhook_max_attempts", env: "WEBHOOK_MAX_ATTEMPTS", flags: []string{"webhook-max-attempts"}, usage: "Число попыток доставки вебхука до переноса в dead letter", value: (*intValue)(&cfg.WebhookMaxAttempts)},
+
+		{key: "outbox_sinks", env: "OUTBOX_SINKS", flags: []string{"outbox-sinks"}, usage: "Получатели доменных событий (log, file, http, memory; через запятую)", value: (*stringSliceValue)(&cfg.OutboxSinks)},
+		{key: "outbox_file", env: "OUTBOX_FILE", flags: []string{"outbox-file"}, usage: "Файл для получателя file", value: (*stringValue)(&cfg.OutboxFile)},
+		{key: "outbox_http_url", env: "OUTBOX_HTTP_URL", flags: []string{"outbox-http-url"}, usage: "Адрес для получателя http", value: (*stringValue)(&cfg.OutboxHTTPURL)},
+		{key: "outbox_http_timeout", env: "OUTBOX_HTTP_TIMEOUT", flags: []string{"outbox-http-timeout"}, usage: "Таймаут запроса получателя http", value: (*durationValue)(&cfg.OutboxHTTPTimeout)},
+		{key: "outbox_topic", env: "OUTBOX_TOPIC", flags: []string{"outbox-topic"}, usage: "Топик брокера сообщений", value: (*stringValue)(&cfg.OutboxTopic)},
+		{key: "outbox_poll_interval", env: "OUTBOX_POLL_INTERVAL", flags: []string{"outbox-poll-interval"}, usage: "Интервал проверки outbox", value: (*durationValue)(&cfg.OutboxPollInterval)},
+		{key: "outbox_batch_size", env: "OUTBOX_BATCH_SIZE", flags: []string{"outbox-batch-size"}, usage: "Число событий, публикуемых за один проход", value: (*intValue)(&cfg.OutboxBatchSize)},
+		{key: "outbox_retention", env: "OUTBOX_RETENTION", flags: []string{"outbox-retention"}, usage: "Срок хранения опубликованных событий (0 — хранить всегда)", value: (*durationValue)(&cfg.OutboxRetention)},
+		{key: "outbox_prune_interval", env: "OUTBOX_PRUNE_INTERVAL", flags: []string{"outbox-prune-interval"}, usage: "Интервал удаления опубликованных событий", value: (*durationValue)(&cfg.OutboxPruneInterval)},
+
+		{key: "points_ttl", env: "POINTS_TTL", flags: []string{"points-ttl"}, usage: "Срок жизни начисленных баллов (0 — не сгорают)", value: (*durationValue)(&cfg.PointsTTL)},
+		{key: "points_expiry_interval", env: "POINTS_EXPIRY_INTERVAL", flags: []string{"points-expiry-interval"}, usage: "Интервал запуска списания сгоревших баллов", value: (*durationValue)(&cfg.PointsExpiryInterval)},
+		{key: "points_expiring_soon", env: "POINTS_EXPIRING_SOON", flags: []string{"points-expiring-soon"}, usage: "Горизонт раздела expiring_soon в балансе", value: (*durationValue)(&cfg.PointsExpiringSoon)},
+
+		{key: "tiers", env: "TIERS", flags: []string{"tiers"}, usage: "Уровни лояльности name:min_points:min_orders:multiplier по возрастанию (через запятую)", value: (*stringSliceValue)(&cfg.Tiers)},
+		{key: "tier_window", env: "TIER_WINDOW", flags: []string{"tier-window"}, usage: "Скользящий период расчета уровня", value: (*durationValue)(&cfg.TierWindow)},
+		{key: "tier_recalc_interval", env: "TIER_RECALC_INTERVAL", flags: []string{"tier-recalc-interval"}, usage: "Интервал пересчета уровней", value: (*durationValue)(&cfg.TierRecalcInterval)},
+
+		{key: "referral_bonus", env: "REFERRAL_BONUS", flags: []string{"referral-bonus"}, usage: "Бонус пригласившему за первый обработанный заказ приглашенного", value: (*floatValue)(&cfg.ReferralBonus)},
+		{key: "referral_welcome_bonus", env: "REFERRAL_WELCOME_BONUS", flags: []string{"referral-welcome-bonus"}, usage: "Бонус приглашенному за первый обработанный заказ", value: (*floatValue)(&cfg.ReferralWelcomeBonus)},
+		{key: "referral_min_accrual", env: "REFERRAL_MIN_ACCRUAL", flags: []string{"referral-min-accrual"}, usage: "Минимальное начисление за первый заказ для выплаты реферальных бонусов", value: (*floatValue)(&cfg.ReferralMinAccrual)},
+		{key: "referral_max_invites", env: "REFERRAL_MAX_INVITES", flags: []string{"referral-max-invites"}, usage: "Максимум оплачиваемых приглашений на пользователя (0 — без ограничения)", value: (*intValue)(&cfg.ReferralMaxInvites)},
+
+		{key: "transfer_daily_limit", env: "TRANSFER_DAILY_LIMIT", flags: []string{"transfer-daily-limit"}, usage: "Максимум баллов, переводимых пользователем за сутки (0 — без ограничения)", value: (*floatValue)(&cfg.TransferDailyLimit)},
+		{key: "transfer_daily_count", env: "TRANSFER_DAILY_COUNT", flags: []string{"transfer-daily-count"}, usage: "Максимум переводов пользователя за сутки (0 — без ограничения)", value: (*intValue)(&cfg.TransferDailyCount)},
+
+		{key: "reservation_ttl", env: "RESERVATION_TTL", flags: []string{"reservation-ttl"}, usage: "Время удержания баллов по умолчанию", value: (*durationValue)(&cfg.ReservationTTL)},
+		{key: "reservation_max_ttl", env: "RESERVATION_MAX_TTL", flags: []string{"reservation-max-ttl"}, usage: "Максимальное время удержания баллов", value: (*durationValue)(&cfg.ReservationMaxTTL)},
+		{key: "reservation_sweep_interval", env: "RESERVATION_SWEEP_INTERVAL", flags: []string{"reservation-sweep-interval"}, usage: "Интервал снятия просроченных удержаний", value: (*durationValue)(&cfg.ReservationSweepInterval)},
+
+		{key: "cors_origins", env: "CORS_ORIGINS", flags: []string{"cors-origins"}, usage: "Разрешенные CORS origin (через запятую, * — любой)", reloadable: true, value: (*stringSliceValue)(&cfg.CORSOrigins)},
+
+		{key: "log_level", env: "LOG_LEVEL", flags: []string{"l"}, usage: "Уровень логирования (debug, info, warn, error)", reloadable: true, value: (*stringValue)(&cfg.LogLevel)},
+		{key: "traces_exporter", env: "OTEL_TRACES_EXPORTER", flags: []string{"traces-exporter"}, usage: "Экспортер трассировок (none, otlp, stdout, file)", value: (*stringValue)(&cfg.TracesExporter)},
+		{key: "traces_file", env: "TRACES_FILE", flags: []string{"traces-file"}, usage: "Файл для экспортера трассировок file", value: (*stringValue)(&cfg.TracesFile)},
+		{key: "traces_flush_timeout", env: "TRACES_FLUSH_TIMEOUT", flags: []string{"traces-flush-timeout"}, usage: "Таймаут отправки трассировок при остановке", value: (*durationValue)(&cfg.TracesFlushTimeout)},
+		{key: "heap_profile", env: "HEAP_PROFILE", flags: []string{"heap-profile"}, usage: "Файл для снимка кучи при завершении (пусто — отключен)", value: (*stringValue)(&cfg.HeapProfilePath)},
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	parsed, err := strconv.Atoi(strings.TrimSpace(s))
+
+	if err != nil {
+		return err
+	}
+
+	*v = intValue(parsed)
+
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type floatValue float64
+
+func (v *floatValue) Set(s string) error {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+
+	if err != nil {
+		return err
+	}
+
+	*v = floatValue(parsed)
+
+	return nil
+}
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'f', -1, 64) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	parsed, err := strconv.ParseBool(strings.TrimSpace(s))
+
+	if err != nil {
+		return err
+	}
+
+	*v = boolValue(parsed)
+
+	return nil
+}
+
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	parsed, err := time.ParseDuration(strings.TrimSpace(s))
+
+	if err != nil {
+		return err
+	}
+
+	*v = durationValue(parsed)
+
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+type stringSliceValue []string
+
+func (v *stringSliceValue) Set(s string) error {
+	var values []string
+
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+
+	*v = values
+
+	return nil
+}
+
+func (v *stringSliceValue) String() string { return strings.Join(*v, ",") }
+
+func setOption(opt option, source string, raw string) error {
+	if err := opt.value.Set(raw); err != nil {
+		return fmt.Errorf("invalid value for %s from %s: %w", opt.key, source, err)
+	}
+
+	return nil
+}
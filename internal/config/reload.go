@@ -0,0 +1,35 @@
+package config
+
+// Diff compares two configurations and returns the keys of changed options,
+// split into those that can be applied at runtime and those that need a
+// restart.
+func Diff(current, next *Config) (reloadable []string, rejected []string) {
+	currentOpts := current.options()
+	nextOpts := next.options()
+
+	for i, opt := range currentOpts {
+		if opt.value.String() == nextOpts[i].value.String() {
+			continue
+		}
+
+		if opt.reloadable {
+			reloadable = append(reloadable, opt.key)
+		} else {
+			rejected = append(rejected, opt.key)
+		}
+	}
+
+	return reloadable, rejected
+}
+
+// ApplyReloadable copies the runtime-changeable settings from next.
+func (cfg *Config) ApplyReloadable(next *Config) {
+	currentOpts := cfg.options()
+	nextOpts := next.options()
+
+	for i, opt := range currentOpts {
+		if opt.reloadable {
+			_ = opt.value.Set(nextOpts[i].value.String())
+		}
+	}
+}
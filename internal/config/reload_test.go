@@ -0,0 +1,43 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	current := Default()
+	next := Default()
+	next.LogLevel = "debug"
+	next.AccrualWorkers = 8
+	next.ServerAddress = "0.0.0.0:9000"
+
+	reloadable, rejected := Diff(current, next)
+
+	if !reflect.DeepEqual(reloadable, []string{"accrual_workers", "log_level"}) {
+		t.Errorf("Unexpected reloadable changes %v", reloadable)
+	}
+
+	if !reflect.DeepEqual(rejected, []string{"run_address"}) {
+		t.Errorf("Unexpected rejected changes %v", rejected)
+	}
+}
+
+func TestApplyReloadable(t *testing.T) {
+	current := Default()
+	next := Default()
+	next.AccrualTimeout = 3 * time.Second
+	next.CORSOrigins = []string{"https://shop.example"}
+	next.DatabaseDsn = "postgres://other@localhost/db"
+
+	current.ApplyReloadable(next)
+
+	if current.AccrualTimeout != 3*time.Second || len(current.CORSOrigins) != 1 {
+		t.Errorf("Expected reloadable settings to be applied, got %+v", current)
+	}
+
+	if current.DatabaseDsn != "" {
+		t.Errorf("Expected non-reloadable settings to be kept, got %q", current.DatabaseDsn)
+	}
+}
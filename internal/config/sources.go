@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+func applyFile(opts []option, path string) error {
+	values, err := readFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]option, len(opts))
+
+	for _, opt := range opts {
+		byKey[opt.key] = opt
+	}
+
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		opt, ok := byKey[key]
+
+		if !ok {
+			return fmt.Errorf("unknown option %q in config file %s", key, path)
+		}
+
+		if err := setOption(opt, path, values[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readFile decodes a config file by extension and flattens nested sections,
+// so that "accrual: {timeout: 5s}" and "accrual_timeout: 5s" are equivalent.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	flatten("", raw, values)
+
+	return values, nil
+}
+
+func flatten(prefix string, raw map[string]interface{}, values map[string]string) {
+	for key, value := range raw {
+		key = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(key, v, values)
+		case []interface{}:
+			parts := make([]string, 0, len(v))
+
+			for _, item := range v {
+				parts = append(parts, fmt.Sprint(item))
+			}
+
+			values[key] = strings.Join(parts, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// applyEnv reads NAME, or the contents of the file named by NAME_FILE so that
+// secrets can be mounted as files instead of being passed in the environment.
+func applyEnv(opts []option, getenv func(string) string) error {
+	for _, opt := range opts {
+		if path := getenv(opt.env + "_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+
+			if err != nil {
+				return fmt.Errorf("could not read %s_FILE: %w", opt.env, err)
+			}
+
+			if err := setOption(opt, opt.env+"_FILE", strings.TrimRight(string(data), "\r\n")); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if value := getenv(opt.env); value != "" {
+			if err := setOption(opt, opt.env, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,367 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gophermart/internal/logger"
+	"gophermart/internal/outbox"
+	"gophermart/internal/tiers"
+	"gophermart/internal/tracing"
+)
+
+const redacted = "REDACTED"
+
+const (
+	minJWTSecretLength  = 16
+	minAdminTokenLength = 16
+)
+
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.ServerAddress == "" {
+		errs = append(errs, errors.New("run_address is required"))
+	}
+
+	if cfg.DatabaseDsn == "" {
+		errs = append(errs, errors.New("database_uri is required"))
+	}
+
+	if cfg.JWTSecret != "" && len(cfg.JWTSecret) < minJWTSecretLength {
+		errs = append(errs, fmt.Errorf("jwt_secret must be at least %d characters", minJWTSecretLength))
+	}
+
+	if cfg.AdminToken != "" && len(cfg.AdminToken) < minAdminTokenLength {
+		errs = append(errs, fmt.Errorf("admin_token must be at least %d characters", minAdminTokenLength))
+	}
+
+	if _, err := cfg.ServiceTokenMap(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if u, err := url.Parse(cfg.AccrualSystemAddress); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("accrual_system_address %q is not an absolute URL", cfg.AccrualSystemAddress))
+	}
+
+	if cfg.DBMaxConns < 1 {
+		errs = append(errs, errors.New("db_max_conns must be positive"))
+	}
+
+	if cfg.DBMinConns < 0 || cfg.DBMinConns > cfg.DBMaxConns {
+		errs = append(errs, errors.New("db_min_conns must be between 0 and db_max_conns"))
+	}
+
+	if cfg.TokenTTL <= 0 {
+		errs = append(errs, errors.New("token_ttl must be positive"))
+	}
+
+	if cfg.CookieName == "" {
+		errs = append(errs, errors.New("cookie_name is required"))
+	}
+
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "lax", "strict", "none":
+	default:
+		errs = append(errs, fmt.Errorf("cookie_same_site %q must be lax, strict or none", cfg.CookieSameSite))
+	}
+
+	if strings.EqualFold(cfg.CookieSameSite, "none") && !cfg.CookieSecure {
+		errs = append(errs, errors.New("cookie_same_site=none requires cookie_secure"))
+	}
+
+	errs = append(errs, cfg.validateReloadable()...)
+
+	switch cfg.TracesExporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterFile:
+	default:
+		errs = append(errs, fmt.Errorf("traces_exporter %q is not supported", cfg.TracesExporter))
+	}
+
+	if cfg.TracesExporter == tracing.ExporterFile && cfg.TracesFile == "" {
+		errs = append(errs, errors.New("traces_file is required for the file exporter"))
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+
+	if cfg.DBCloseTimeout <= 0 {
+		errs = append(errs, errors.New("db_close_timeout must be positive"))
+	}
+
+	if cfg.AccrualDrainTimeout <= 0 {
+		errs = append(errs, errors.New("accrual_drain_timeout must be positive"))
+	}
+
+	if cfg.TracesFlushTimeout <= 0 {
+		errs = append(errs, errors.New("traces_flush_timeout must be positive"))
+	}
+
+	if cfg.AccrualPollInterval <= 0 {
+		errs = append(errs, errors.New("accrual_poll_interval must be positive"))
+	}
+
+	if cfg.AccrualQueueSize < 1 {
+		errs = append(errs, errors.New("accrual_queue_size must be positive"))
+	}
+
+	if cfg.AccrualRevisionWindow < 0 {
+		errs = append(errs, errors.New("accrual_revision_window must not be negative"))
+	}
+
+	if cfg.AccrualRevisionWindow > 0 && cfg.AccrualRevisionEvery <= 0 {
+		errs = append(errs, errors.New("accrual_revision_interval must be positive"))
+	}
+
+	if cfg.AccrualBreakerLimit < 0 {
+		errs = append(errs, errors.New("accrual_breaker_threshold must not be negative"))
+	}
+
+	if cfg.AccrualBreakerLimit > 0 && cfg.AccrualBreakerCooling <= 0 {
+		errs = append(errs, errors.New("accrual_breaker_cooldown must be positive"))
+	}
+
+	switch cfg.DebtPolicy {
+	case "negative", "debt":
+	default:
+		errs = append(errs, fmt.Errorf("debt_policy %q must be negative or debt", cfg.DebtPolicy))
+	}
+
+	if cfg.WebhookPollInterval <= 0 {
+		errs = append(errs, errors.New("webhook_poll_interval must be positive"))
+	}
+
+	if cfg.WebhookTimeout <= 0 {
+		errs = append(errs, errors.New("webhook_timeout must be positive"))
+	}
+
+	if cfg.WebhookMaxAttempts < 1 {
+		errs = append(errs, errors.New("webhook_max_attempts must be positive"))
+	}
+
+	errs = append(errs, cfg.validateOutbox()...)
+
+	if cfg.PointsTTL < 0 {
+		errs = append(errs, errors.New("points_ttl must not be negative"))
+	}
+
+	if cfg.PointsExpiryInterval <= 0 {
+		errs = append(errs, errors.New("points_expiry_interval must be positive"))
+	}
+
+	if cfg.PointsExpiringSoon < 0 {
+		errs = append(errs, errors.New("points_expiring_soon must not be negative"))
+	}
+
+	if _, err := tiers.Parse(cfg.Tiers); err != nil {
+		errs = append(errs, fmt.Errorf("tiers: %w", err))
+	}
+
+	if cfg.TierWindow <= 0 {
+		errs = append(errs, errors.New("tier_window must be positive"))
+	}
+
+	if cfg.TierRecalcInterval <= 0 {
+		errs = append(errs, errors.New("tier_recalc_interval must be positive"))
+	}
+
+	if cfg.ReferralBonus < 0 || cfg.ReferralWelcomeBonus < 0 {
+		errs = append(errs, errors.New("referral bonuses must not be negative"))
+	}
+
+	if cfg.ReferralMinAccrual < 0 {
+		errs = append(errs, errors.New("referral_min_accrual must not be negative"))
+	}
+
+	if cfg.ReferralMaxInvites < 0 {
+		errs = append(errs, errors.New("referral_max_invites must not be negative"))
+	}
+
+	if cfg.TransferDailyLimit < 0 || cfg.TransferDailyCount < 0 {
+		errs = append(errs, errors.New("transfer daily limits must not be negative"))
+	}
+
+	if cfg.ReservationTTL <= 0 || cfg.ReservationTTL > cfg.ReservationMaxTTL {
+		errs = append(errs, errors.New("reservation_ttl must be positive and not above reservation_max_ttl"))
+	}
+
+	if cfg.ReservationSweepInterval <= 0 {
+		errs = append(errs, errors.New("reservation_sweep_interval must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (cfg *Config) validateOutbox() []error {
+	var errs []error
+
+	if len(cfg.OutboxSinks) == 0 {
+		errs = append(errs, errors.New("outbox_sinks must not be empty"))
+	}
+
+	for _, sink := range cfg.OutboxSinks {
+		switch sink {
+		case outbox.SinkLog, outbox.SinkMemory:
+		case outbox.SinkFile:
+			if cfg.OutboxFile == "" {
+				errs = append(errs, errors.New("outbox_file is required for the file sink"))
+			}
+		case outbox.SinkHTTP:
+			if u, err := url.Parse(cfg.OutboxHTTPURL); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("outbox_http_url %q is not an absolute URL", cfg.OutboxHTTPURL))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("outbox sink %q is not supported", sink))
+		}
+	}
+
+	if cfg.OutboxHTTPTimeout <= 0 {
+		errs = append(errs, errors.New("outbox_http_timeout must be positive"))
+	}
+
+	if cfg.OutboxPollInterval <= 0 {
+		errs = append(errs, errors.New("outbox_poll_interval must be positive"))
+	}
+
+	if cfg.OutboxBatchSize < 1 {
+		errs = append(errs, errors.New("outbox_batch_size must be positive"))
+	}
+
+	if cfg.OutboxRetention < 0 {
+		errs = append(errs, errors.New("outbox_retention must not be negative"))
+	}
+
+	if cfg.OutboxRetention > 0 && cfg.OutboxPruneInterval <= 0 {
+		errs = append(errs, errors.New("outbox_prune_interval must be positive"))
+	}
+
+	return errs
+}
+
+func (cfg *Config) validateReloadable() []error {
+	var errs []error
+
+	if cfg.AccrualTimeout <= 0 {
+		errs = append(errs, errors.New("accrual_timeout must be positive"))
+	}
+
+	if cfg.AccrualProcessTimeout <= 0 {
+		errs = append(errs, errors.New("accrual_process_timeout must be positive"))
+	}
+
+	if cfg.AccrualRateLimit < 0 {
+		errs = append(errs, errors.New("accrual_rate_limit must not be negative"))
+	}
+
+	if cfg.AccrualWorkers < 1 {
+		errs = append(errs, errors.New("accrual_workers must be positive"))
+	}
+
+	if _, err := logger.ParseLevel(cfg.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// ServiceTokenMap parses service_tokens into tokens by service name.
+func (cfg *Config) ServiceTokenMap() (map[string]string, error) {
+	tokens := make(map[string]string, len(cfg.ServiceTokens))
+
+	for _, entry := range cfg.ServiceTokens {
+		name, token, ok := strings.Cut(entry, ":")
+
+		if !ok || name == "" {
+			return nil, errors.New("service_tokens entries must look like name:token")
+		}
+
+		if len(token) < minAdminTokenLength {
+			return nil, fmt.Errorf("service token of %q must be at least %d characters", name, minAdminTokenLength)
+		}
+
+		if _, ok := tokens[name]; ok {
+			return nil, fmt.Errorf("service %q has more than one token", name)
+		}
+
+		tokens[name] = token
+	}
+
+	return tokens, nil
+}
+
+// EnsureJWTSecret generates an ephemeral signing key when none is configured.
+// Tokens signed with it do not survive a restart and are not shared between
+// replicas, so it reports whether a key was generated for the caller to warn.
+func (cfg *Config) EnsureJWTSecret() (bool, error) {
+	if cfg.JWTSecret != "" {
+		return false, nil
+	}
+
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return false, err
+	}
+
+	cfg.JWTSecret = hex.EncodeToString(b)
+
+	return true, nil
+}
+
+// Redacted returns the effective configuration keyed like the config file,
+// with secrets masked.
+func (cfg *Config) Redacted() map[string]string {
+	values := map[string]string{}
+
+	for _, opt := range cfg.options() {
+		value := opt.value.String()
+
+		if opt.secret && value != "" {
+			value = redactSecret(opt.key, value)
+		}
+
+		values[opt.key] = value
+	}
+
+	return values
+}
+
+func (cfg *Config) Print(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(cfg.Redacted())
+}
+
+func redactSecret(key string, value string) string {
+	if key == "database_uri" {
+		if u, err := url.Parse(value); err == nil && u.Scheme != "" {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+			}
+
+			return u.String()
+		}
+	}
+
+	return redacted
+}
+
+func (cfg *Config) SameSite() http.SameSite {
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+
+	return http.SameSiteLaxMode
+}
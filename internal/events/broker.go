@@ -0,0 +1,204 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v4"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	subscriberBuffer   = 16
+	minListenBackoff   = time.Second
+	maxListenBackoff   = 30 * time.Second
+	listenCloseTimeout = 5 * time.Second
+)
+
+// Broker keeps one LISTEN connection per replica and fans notifications out
+// to the SSE streams of the user they belong to. Notifications sent while the
+// connection is being re-established are lost; clients refetch state when
+// their stream reconnects.
+type Broker struct {
+	connConfig *pgx.ConnConfig
+
+	mu          sync.Mutex
+	subscribers map[int]map[chan Event]struct{}
+	closed      bool
+	running     bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewBroker takes the pool's connection config: the listener uses a
+// dedicated connection so that it never holds a pool slot.
+func NewBroker(connConfig *pgx.ConnConfig) *Broker {
+	b := &Broker{
+		connConfig:  connConfig,
+		subscribers: map[int]map[chan Event]struct{}{},
+		done:        make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+
+	return b
+}
+
+func (b *Broker) Run() {
+	b.mu.Lock()
+
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+
+	b.running = true
+	b.mu.Unlock()
+
+	defer close(b.done)
+
+	backoff := minListenBackoff
+
+	for {
+		err := b.listen()
+
+		if b.ctx.Err() != nil {
+			return
+		}
+
+		slog.Error("Event listener disconnected", "error", err, "retry_in", backoff)
+
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxListenBackoff {
+			backoff = maxListenBackoff
+		}
+	}
+}
+
+func (b *Broker) listen() error {
+	conn, err := pgx.ConnectConfig(b.ctx, b.connConfig)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), listenCloseTimeout)
+		defer cancel()
+
+		_ = conn.Close(ctx)
+	}()
+
+	if _, err := conn.Exec(b.ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+
+	slog.Info("Listening for events", "channel", Channel)
+
+	for {
+		notification, err := conn.WaitForNotification(b.ctx)
+
+		if err != nil {
+			return err
+		}
+
+		var event Event
+
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			slog.Warn("Malformed event notification", "error", err)
+			continue
+		}
+
+		b.Deliver(event)
+	}
+}
+
+// Subscribe returns the events of one user. The channel is closed when the
+// broker is closed; the returned function unsubscribes.
+func (b *Broker) Subscribe(userID int) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = map[chan Event]struct{}{}
+	}
+
+	b.subscribers[userID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[userID][ch]; !ok {
+			return
+		}
+
+		delete(b.subscribers[userID], ch)
+
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+
+		close(ch)
+	}
+}
+
+// Deliver hands an event to the local subscribers of its user. A subscriber
+// that is not keeping up misses the event instead of blocking the others.
+func (b *Broker) Deliver(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Event subscriber is too slow, event dropped", "user_id", event.UserID, "type", event.Type)
+		}
+	}
+}
+
+// Close ends every stream and stops the listener. It is registered with
+// http.Server.RegisterOnShutdown, because open streams would otherwise keep
+// Shutdown waiting until its deadline.
+func (b *Broker) Close() {
+	b.mu.Lock()
+
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+
+	b.closed = true
+
+	for userID, channels := range b.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+
+		delete(b.subscribers, userID)
+	}
+
+	running := b.running
+	b.mu.Unlock()
+
+	b.cancel()
+
+	if running {
+		<-b.done
+	}
+}
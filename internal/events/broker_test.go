@@ -0,0 +1,52 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBroker_DeliversToSubscribersOfUser(t *testing.T) {
+	b := NewBroker(nil)
+
+	first, unsubscribeFirst := b.Subscribe(1)
+	defer unsubscribeFirst()
+	other, unsubscribeOther := b.Subscribe(2)
+	defer unsubscribeOther()
+
+	b.Deliver(Event{Type: TypeOrder, UserID: 1})
+
+	select {
+	case event := <-first:
+		if event.Type != TypeOrder {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	default:
+		t.Fatal("Expected event to be delivered to its user")
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("Expected no event for another user, got %+v", event)
+	default:
+	}
+}
+
+func TestBroker_CloseEndsStreams(t *testing.T) {
+	b := NewBroker(nil)
+	stream, unsubscribe := b.Subscribe(1)
+
+	b.Close()
+	unsubscribe()
+
+	if _, ok := <-stream; ok {
+		t.Error("Expected stream to be closed")
+	}
+
+	if _, ok := <-mustSubscribe(b); ok {
+		t.Error("Expected subscriptions after close to be closed")
+	}
+}
+
+func mustSubscribe(b *Broker) <-chan Event {
+	stream, _ := b.Subscribe(1)
+	return stream
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"gophermart/storage"
+)
+
+// Channel is the Postgres NOTIFY channel shared by all replicas.
+const Channel = "gophermart_events"
+
+const (
+	TypeOrder      = "order"
+	TypeBalance    = "balance"
+	TypeWithdrawal = "withdrawal"
+)
+
+type Event struct {
+	Type   string          `json:"type"`
+	UserID int             `json:"user_id"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type OrderData struct {
+	Number  string  `json:"number"`
+	Status  string  `json:"status"`
+	Accrual float32 `json:"accrual,omitempty"`
+}
+
+type BalanceData struct {
+	Current   float32 `json:"current"`
+	Withdrawn float32 `json:"withdrawn"`
+	Reserved  float32 `json:"reserved"`
+}
+
+type WithdrawalData struct {
+	Order    string  `json:"order"`
+	Sum      float32 `json:"sum"`
+	Status   string  `json:"status,omitempty"`
+	Refunded float32 `json:"refunded,omitempty"`
+}
+
+// Publish sends an event through NOTIFY. Inside WithTx the notification is
+// delivered only if the transaction commits, so listeners never see a change
+// that was rolled back. Notifications only push changes to the streams open
+// right now and are not kept; the outbox is the durable record of events.
+func Publish(ctx context.Context, db *storage.PgStorage, eventType string, userID int, data interface{}) error {
+	raw, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(Event{Type: eventType, UserID: userID, Data: raw})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Querier(ctx).Exec(ctx, "SELECT pg_notify($1, $2)", Channel, string(payload))
+
+	return err
+}
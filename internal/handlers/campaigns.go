@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"gophermart/internal/campaigns"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// CampaignHandler serves the admin API under /api/admin/campaigns.
+type CampaignHandler struct {
+	CampaignService interfaces.CampaignServiceInterface
+}
+
+func (ch *CampaignHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var campaign interfaces.Campaign
+
+	if err := json.NewDecoder(r.Body).Decode(&campaign); err != nil {
+		http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	campaign, err := ch.CampaignService.CreateCampaign(r.Context(), campaign)
+
+	if err != nil {
+		if errors.Is(err, campaigns.ErrInvalidCampaign) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to create campaign", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(campaign)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if _, err := w.Write(jsonData); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+func (ch *CampaignHandler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := ch.CampaignService.ListCampaigns(r.Context())
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list campaigns", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if len(list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, r, list)
+}
+
+func (ch *CampaignHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Неверный идентификатор", http.StatusBadRequest)
+		return
+	}
+
+	campaign, err := ch.CampaignService.GetCampaign(r.Context(), id)
+
+	if err != nil {
+		if errors.Is(err, repository.ErrCampaignNotFound) {
+			http.Error(w, "Кампания не найдена", http.StatusNotFound)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to get campaign", "campaign", id, "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, campaign)
+}
+
+// Update replaces the rules of a campaign. Bonuses already credited are kept.
+func (ch *CampaignHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Неверный идентификатор", http.StatusBadRequest)
+		return
+	}
+
+	var campaign interfaces.Campaign
+
+	if err := json.NewDecoder(r.Body).Decode(&campaign); err != nil {
+		http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	campaign.ID = id
+	campaign, err = ch.CampaignService.UpdateCampaign(r.Context(), campaign)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, campaigns.ErrInvalidCampaign):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, repository.ErrCampaignNotFound):
+			http.Error(w, "Кампания не найдена", http.StatusNotFound)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to update campaign", "campaign", id, "error", err)
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	writeJSON(w, r, campaign)
+}
+
+// Delete stops a campaign. It stays referenced by the bonuses it granted.
+func (ch *CampaignHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Неверный идентификатор", http.StatusBadRequest)
+		return
+	}
+
+	if err := ch.CampaignService.DeleteCampaign(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrCampaignNotFound) {
+			http.Error(w, "Кампания не найдена", http.StatusNotFound)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to delete campaign", "campaign", id, "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"gophermart/internal/campaigns"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockCampaignService struct {
+	UpdateCampaignFunc func(campaign interfaces.Campaign) (interfaces.Campaign, error)
+}
+
+func (cs *MockCampaignService) CreateCampaign(ctx context.Context, campaign interfaces.Campaign) (interfaces.Campaign, error) {
+	if campaign.Name == "" {
+		return campaign, fmt.Errorf("%w: name is required", campaigns.ErrInvalidCampaign)
+	}
+
+	campaign.ID = 1
+
+	return campaign, nil
+}
+
+func (cs *MockCampaignService) ListCampaigns(ctx context.Context) ([]interfaces.Campaign, error) {
+	return nil, nil
+}
+
+func (cs *MockCampaignService) GetCampaign(ctx context.Context, id int64) (interfaces.Campaign, error) {
+	return interfaces.Campaign{}, repository.ErrCampaignNotFound
+}
+
+func (cs *MockCampaignService) UpdateCampaign(ctx context.Context, campaign interfaces.Campaign) (interfaces.Campaign, error) {
+	return cs.UpdateCampaignFunc(campaign)
+}
+
+func (cs *MockCampaignService) DeleteCampaign(ctx context.Context, id int64) error {
+	return nil
+}
+
+func TestCampaignCreate(t *testing.T) {
+	handler := CampaignHandler{CampaignService: &MockCampaignService{}}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "created", body: `{"name":"double points","starts_at":"2026-11-01T00:00:00Z","ends_at":"2026-12-01T00:00:00Z","effects":{"multiplier":2}}`, wantStatus: http.StatusCreated},
+		{name: "invalid", body: `{"effects":{"flat_bonus":100}}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/admin/campaigns", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.Create).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestCampaignUpdate(t *testing.T) {
+	campaignService := &MockCampaignService{
+		UpdateCampaignFunc: func(campaign interfaces.Campaign) (interfaces.Campaign, error) {
+			if campaign.ID != 1 {
+				return campaign, repository.ErrCampaignNotFound
+			}
+
+			return campaign, nil
+		},
+	}
+	handler := CampaignHandler{CampaignService: campaignService}
+
+	router := chi.NewRouter()
+	router.Put("/api/admin/campaigns/{id}", handler.Update)
+	router.Get("/api/admin/campaigns/{id}", handler.Get)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "updated", method: "PUT", path: "/api/admin/campaigns/1", wantStatus: http.StatusOK},
+		{name: "unknown", method: "PUT", path: "/api/admin/campaigns/2", wantStatus: http.StatusNotFound},
+		{name: "bad id", method: "PUT", path: "/api/admin/campaigns/abc", wantStatus: http.StatusBadRequest},
+		{name: "get unknown", method: "GET", path: "/api/admin/campaigns/2", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name":"x","effects":{"flat_bonus":10}}`))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
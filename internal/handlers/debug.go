@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+type RuntimeStats struct {
+	Goroutines     int       `json:"goroutines"`
+	GOMAXPROCS     int       `json:"gomaxprocs"`
+	NumCPU         int       `json:"num_cpu"`
+	HeapAlloc      uint64    `json:"heap_alloc_bytes"`
+	HeapInuse      uint64    `json:"heap_inuse_bytes"`
+	HeapObjects    uint64    `json:"heap_objects"`
+	Sys            uint64    `json:"sys_bytes"`
+	NumGC          uint32    `json:"num_gc"`
+	PauseTotalNs   uint64    `json:"gc_pause_total_ns"`
+	LastGC         time.Time `json:"last_gc"`
+	GoVersion      string    `json:"go_version"`
+	UptimeSeconds  float64   `json:"uptime_seconds"`
+	TotalAllocated uint64    `json:"total_alloc_bytes"`
+}
+
+var startedAt = time.Now()
+
+func DebugRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Mount("/debug", chimiddleware.Profiler())
+	r.Get("/debug/runtime", RuntimeStatsHandler)
+
+	return r
+}
+
+func RuntimeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	stats := RuntimeStats{
+		Goroutines:     runtime.NumGoroutine(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		NumCPU:         runtime.NumCPU(),
+		HeapAlloc:      memStats.HeapAlloc,
+		HeapInuse:      memStats.HeapInuse,
+		HeapObjects:    memStats.HeapObjects,
+		Sys:            memStats.Sys,
+		NumGC:          memStats.NumGC,
+		PauseTotalNs:   memStats.PauseTotalNs,
+		LastGC:         time.Unix(0, int64(memStats.LastGC)),
+		GoVersion:      runtime.Version(),
+		UptimeSeconds:  time.Since(startedAt).Seconds(),
+		TotalAllocated: memStats.TotalAlloc,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
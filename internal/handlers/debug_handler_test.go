@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugRouter(t *testing.T) {
+	router := DebugRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/runtime", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v", rr.Code)
+	}
+
+	var stats RuntimeStats
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Goroutines == 0 {
+		t.Error("Expected goroutine count to be reported")
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/pprof/", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected pprof index, got %v", rr.Code)
+	}
+}
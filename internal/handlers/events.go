@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"gophermart/internal/middleware"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	eventsHeartbeat  = 15 * time.Second
+	eventsRetryDelay = 5 * time.Second
+)
+
+// Events streams order, balance and withdrawal events of the current user as
+// Server-Sent Events. Events are not replayed: after a reconnect the client
+// should refetch the state it displays.
+func (uh *UserHandler) Events(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	flusher, ok := w.(http.Flusher)
+
+	if !ok || uh.EventBroker == nil {
+		http.Error(w, "Потоковая передача не поддерживается", http.StatusNotImplemented)
+		return
+	}
+
+	stream, unsubscribe := uh.EventBroker.Subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetryDelay.Milliseconds())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data); err != nil {
+				slog.DebugContext(r.Context(), "Event stream closed", "error", err)
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
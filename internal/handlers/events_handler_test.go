@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"gophermart/internal/events"
+	"gophermart/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	broker := events.NewBroker(nil)
+	handler := UserHandler{EventBroker: broker}
+
+	req := httptest.NewRequest("GET", "/api/user/events", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		http.HandlerFunc(handler.Events).ServeHTTP(rr, req)
+	}()
+
+	// Wait for the handler to subscribe before publishing.
+	time.Sleep(20 * time.Millisecond)
+
+	data, _ := json.Marshal(events.OrderData{Number: "12345678903", Status: "PROCESSED"})
+	broker.Deliver(events.Event{Type: events.TypeOrder, UserID: 1, Data: data})
+	broker.Deliver(events.Event{Type: events.TypeOrder, UserID: 2, Data: data})
+	time.Sleep(20 * time.Millisecond)
+	broker.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected stream to end when the broker is closed")
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", ct)
+	}
+
+	body := rr.Body.String()
+
+	if strings.Count(body, "event: order\n") != 1 || !strings.Contains(body, `"number":"12345678903"`) {
+		t.Errorf("Unexpected stream %q", body)
+	}
+}
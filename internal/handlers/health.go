@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gophermart/internal/health"
+	"net/http"
+)
+
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+func (hh *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, health.Report{Status: health.StatusOK})
+}
+
+func (hh *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, hh.Checker.Check(r.Context()))
+}
+
+func writeHealthReport(w http.ResponseWriter, report health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if report.Status == health.StatusOK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(report)
+}
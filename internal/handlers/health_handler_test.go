@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gophermart/internal/health"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.AddCheck("database", func(ctx context.Context) error {
+		return nil
+	})
+	handler := HealthHandler{Checker: checker}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.Readiness).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %v", rr.Code)
+	}
+}
+
+func TestReadiness_CheckFailed(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.AddCheck("database", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	handler := HealthHandler{Checker: checker}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.Readiness).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %v", rr.Code)
+	}
+
+	var report health.Report
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Checks["database"].Error != "connection refused" {
+		t.Errorf("Expected database check detail, got %+v", report.Checks)
+	}
+}
+
+func TestReadiness_ShuttingDown(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.SetShuttingDown()
+	handler := HealthHandler{Checker: checker}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.Readiness).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(handler.Liveness).ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected liveness to stay 200 during shutdown, got %v", rr.Code)
+	}
+}
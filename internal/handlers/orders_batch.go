@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gophermart/internal/middleware"
+	"gophermart/internal/repository"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxBatchOrders   = 500
+	maxBatchBodySize = 1 << 20
+)
+
+const (
+	BatchOrderAccepted       = "accepted"
+	BatchOrderAlreadyYours   = "already_uploaded"
+	BatchOrderAnotherUser    = "owned_by_another_user"
+	BatchOrderInvalidNumber  = "invalid"
+	batchOrderUnknownOutcome = "unknown"
+)
+
+type BatchOrderResult struct {
+	Number  string `json:"number"`
+	Outcome string `json:"outcome"`
+}
+
+// SaveOrders handles POST /api/user/orders/batch. The body is a JSON array of
+// numbers when sent as application/json and a newline-separated list
+// otherwise. Valid numbers are saved in one transaction; the response lists
+// the outcome of every entry in request order.
+func (uh *UserHandler) SaveOrders(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	numbers, err := parseBatchOrders(r)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]BatchOrderResult, len(numbers))
+	var valid []string
+	var validIndexes []int
+
+	for i, number := range numbers {
+		results[i].Number = number
+
+		if !isDigits(number) || !ValidateNumber(number) {
+			results[i].Outcome = BatchOrderInvalidNumber
+			continue
+		}
+
+		valid = append(valid, number)
+		validIndexes = append(validIndexes, i)
+	}
+
+	accepted := 0
+
+	if len(valid) > 0 {
+		saved, err := uh.OrderService.SaveOrders(r.Context(), valid, userID)
+
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save order batch", "orders", len(valid), "error", err)
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+
+		for i, result := range saved {
+			results[validIndexes[i]].Outcome = batchOutcome(result)
+
+			if result == 0 {
+				accepted++
+
+				if uh.AccrualProcessor != nil {
+					uh.AccrualProcessor.Enqueue(r.Context(), valid[i], userID)
+				}
+			}
+		}
+	}
+
+	slog.InfoContext(r.Context(), "Order batch uploaded", "orders", len(numbers), "accepted", accepted)
+
+	status := http.StatusOK
+
+	if accepted > 0 {
+		status = http.StatusAccepted
+	}
+
+	jsonData, err := json.Marshal(results)
+
+	if err != nil {
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if _, err := w.Write(jsonData); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+func batchOutcome(result int) string {
+	switch result {
+	case 0:
+		return BatchOrderAccepted
+	case repository.OrderLoaderByThisUser:
+		return BatchOrderAlreadyYours
+	case repository.OrderLoadedByAnotherUser:
+		return BatchOrderAnotherUser
+	}
+
+	return batchOrderUnknownOutcome
+}
+
+func parseBatchOrders(r *http.Request) ([]string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBatchBodySize))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read body: %w", err)
+	}
+
+	defer r.Body.Close()
+
+	var numbers []string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/json" {
+		numbers, err = parseBatchJSON(body)
+
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+
+		for scanner.Scan() {
+			if number := strings.TrimSpace(scanner.Text()); number != "" {
+				numbers = append(numbers, number)
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("could not read body: %w", err)
+		}
+	}
+
+	if len(numbers) == 0 {
+		return nil, errors.New("no order numbers in request")
+	}
+
+	if len(numbers) > maxBatchOrders {
+		return nil, fmt.Errorf("at most %d orders per batch", maxBatchOrders)
+	}
+
+	return numbers, nil
+}
+
+// parseBatchJSON accepts both strings and bare numbers. Numbers are decoded as
+// json.Number so that long order numbers keep every digit.
+func parseBatchJSON(body []byte) ([]string, error) {
+	var raw []interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, errors.New("body must be a JSON array of order numbers")
+	}
+
+	numbers := make([]string, 0, len(raw))
+
+	for _, item := range raw {
+		switch v := item.(type) {
+		case string:
+			numbers = append(numbers, strings.TrimSpace(v))
+		case json.Number:
+			numbers = append(numbers, v.String())
+		default:
+			return nil, errors.New("body must be a JSON array of order numbers")
+		}
+	}
+
+	return numbers, nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"gophermart/internal/middleware"
+	"gophermart/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSaveOrders(t *testing.T) {
+	var saved []string
+
+	orderService := &MockOrderService{
+		SaveOrdersFunc: func(orderNumbers []string) ([]int, error) {
+			saved = orderNumbers
+			return []int{0, repository.OrderLoaderByThisUser, repository.OrderLoadedByAnotherUser}, nil
+		},
+	}
+	handler := UserHandler{OrderService: orderService}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{name: "json", contentType: "application/json", body: `["12345678903", 79927398713, "4561261212345467", "12345678900"]`},
+		{name: "text", contentType: "text/plain", body: "12345678903\r\n79927398713\n\n4561261212345467\n12345678900\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/user/orders/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.SaveOrders).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusAccepted {
+				t.Fatalf("Expected status 202, got %v", rr.Code)
+			}
+
+			if len(saved) != 3 {
+				t.Errorf("Expected only Luhn-valid numbers to be saved, got %v", saved)
+			}
+
+			var results []BatchOrderResult
+			if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []BatchOrderResult{
+				{Number: "12345678903", Outcome: BatchOrderAccepted},
+				{Number: "79927398713", Outcome: BatchOrderAlreadyYours},
+				{Number: "4561261212345467", Outcome: BatchOrderAnotherUser},
+				{Number: "12345678900", Outcome: BatchOrderInvalidNumber},
+			}
+
+			if len(results) != len(want) {
+				t.Fatalf("Expected %d results, got %v", len(want), results)
+			}
+
+			for i := range want {
+				if results[i] != want[i] {
+					t.Errorf("Result %d: expected %+v, got %+v", i, want[i], results[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSaveOrders_Empty(t *testing.T) {
+	handler := UserHandler{OrderService: &MockOrderService{}}
+
+	req := httptest.NewRequest("POST", "/api/user/orders/batch", strings.NewReader("[]"))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.SaveOrders).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %v", rr.Code)
+	}
+}
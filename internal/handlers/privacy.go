@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"gophermart/internal/repository"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Export returns everything stored about the user, as a ZIP archive with one
+// JSON file per part or, with format=json, as a single JSON document.
+func (uh *UserHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+	format := r.URL.Query().Get("format")
+
+	if format != "" && format != "zip" && format != "json" {
+		http.Error(w, "format must be zip or json", http.StatusBadRequest)
+		return
+	}
+
+	export, err := uh.PrivacyService.Export(r.Context(), userID)
+
+	if err != nil {
+		writePrivacyError(w, r, err)
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Disposition", `attachment; filename="gophermart-export.json"`)
+		writeJSON(w, r, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="gophermart-export.zip"`)
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeExportZip(w, export); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write export", "error", err)
+	}
+}
+
+func writeExportZip(w http.ResponseWriter, export interfaces.DataExport) error {
+	archive := zip.NewWriter(w)
+	modified := time.Now()
+
+	parts := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"orders.json", export.Orders},
+		{"withdrawals.json", export.Withdrawals},
+		{"ledger.json", export.Ledger},
+	}
+
+	for _, part := range parts {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate, Modified: modified})
+
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(part.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// DeleteAccount anonymizes the user and logs them out everywhere. Financial
+// records are kept, so the points left on the balance are forfeited.
+func (uh *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	if err := uh.PrivacyService.DeleteAccount(r.Context(), userID); err != nil {
+		writePrivacyError(w, r, err)
+		return
+	}
+
+	uh.clearTokenCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writePrivacyError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrUserDeleted) {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+
+	slog.ErrorContext(r.Context(), "Failed to process data subject request", "error", err)
+	http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+}
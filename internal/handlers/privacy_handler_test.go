@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"gophermart/internal/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockPrivacyService struct {
+	Deleted map[int]bool
+}
+
+func (m *MockPrivacyService) Export(ctx context.Context, userID int) (interfaces.DataExport, error) {
+	if m.Deleted[userID] {
+		return interfaces.DataExport{}, repository.ErrUserDeleted
+	}
+
+	return interfaces.DataExport{
+		Profile: interfaces.UserProfile{ID: userID, Login: "user", CreatedAt: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)},
+		Orders:  []interfaces.OrderData{{Number: "12345678903", Status: "PROCESSED", Accrual: 50}},
+		Ledger:  []interfaces.LedgerRecord{{Kind: repository.LedgerAccrual, Amount: 50, OrderNumber: "12345678903"}},
+	}, nil
+}
+
+func (m *MockPrivacyService) DeleteAccount(ctx context.Context, userID int) error {
+	if m.Deleted[userID] {
+		return repository.ErrUserDeleted
+	}
+
+	m.Deleted[userID] = true
+
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	handler := UserHandler{PrivacyService: &MockPrivacyService{}}
+
+	req := httptest.NewRequest("GET", "/api/user/export", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.Export).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+
+	if got := rr.Header().Get("Content-Type"); got != "application/zip" {
+		t.Fatalf("Expected a ZIP archive, got %q", got)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{}
+
+	for _, file := range archive.File {
+		f, err := file.Open()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[file.Name], _ = io.ReadAll(f)
+		f.Close()
+	}
+
+	for _, name := range []string{"profile.json", "orders.json", "withdrawals.json", "ledger.json"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("Expected %s in the archive", name)
+		}
+	}
+
+	var profile interfaces.UserProfile
+
+	if err := json.Unmarshal(files["profile.json"], &profile); err != nil || profile.Login != "user" {
+		t.Fatalf("Expected the profile of user, got %s", files["profile.json"])
+	}
+}
+
+func TestExport_JSON(t *testing.T) {
+	handler := UserHandler{PrivacyService: &MockPrivacyService{}}
+
+	req := httptest.NewRequest("GET", "/api/user/export?format=json", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.Export).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+
+	var export interfaces.DataExport
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(export.Orders) != 1 || len(export.Ledger) != 1 {
+		t.Fatalf("Expected one order and one ledger entry, got %s", rr.Body.String())
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	handler := UserHandler{PrivacyService: &MockPrivacyService{Deleted: map[int]bool{}}}
+
+	tests := []struct {
+		name       string
+		wantStatus int
+	}{
+		{name: "deleted", wantStatus: http.StatusNoContent},
+		{name: "already deleted", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", "/api/user", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.DeleteAccount).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantStatus != http.StatusNoContent {
+				return
+			}
+
+			cookies := rr.Result().Cookies()
+
+			if len(cookies) != 1 || cookies[0].Name != DefaultCookieSettings.Name || cookies[0].MaxAge != -1 {
+				t.Fatalf("Expected the token cookie to be cleared, got %v", cookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// ReservationHandler serves the API the shop settles checkout holds with
+// under /api/reservations.
+type ReservationHandler struct {
+	ReservationService interfaces.ReservationAdminInterface
+}
+
+type captureRequest struct {
+	// Amount is optional; without it the whole hold is captured.
+	Amount decimal.Decimal `json:"amount"`
+}
+
+func (rh *ReservationHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	id, ok := reservationID(w, r)
+
+	if !ok {
+		return
+	}
+
+	actor, _ := r.Context().Value(middleware.ActorKey).(string)
+
+	var request captureRequest
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+	}
+
+	reservation, err := rh.ReservationService.Capture(r.Context(), id, request.Amount, actor)
+
+	if err != nil {
+		writeReservationError(w, r, err, "reservation", id)
+		return
+	}
+
+	writeJSON(w, r, reservation)
+}
+
+func (rh *ReservationHandler) Release(w http.ResponseWriter, r *http.Request) {
+	id, ok := reservationID(w, r)
+
+	if !ok {
+		return
+	}
+
+	actor, _ := r.Context().Value(middleware.ActorKey).(string)
+
+	reservation, err := rh.ReservationService.Release(r.Context(), id, actor)
+
+	if err != nil {
+		writeReservationError(w, r, err, "reservation", id)
+		return
+	}
+
+	writeJSON(w, r, reservation)
+}
+
+// reservationID parses the id the shop got back when the hold was made.
+func reservationID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil || id <= 0 {
+		http.Error(w, "Удержание не найдено", http.StatusNotFound)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// writeReservationError maps a reservation error to a response; attrs
+// identify the hold in the log.
+func writeReservationError(w http.ResponseWriter, r *http.Request, err error, attrs ...interface{}) {
+	switch {
+	case errors.Is(err, repository.ErrReservationNotFound):
+		http.Error(w, "Удержание не найдено", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidReservation):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrReservationState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrNotEnoughPoints):
+		http.Error(w, "На счету недостаточно средств", http.StatusPaymentRequired)
+	default:
+		slog.ErrorContext(r.Context(), "Failed to process reservation", append(attrs, "error", err)...)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
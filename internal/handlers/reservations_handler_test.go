@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockReservationAdminService struct{}
+
+func (rs *MockReservationAdminService) Capture(ctx context.Context, id int64, amount decimal.Decimal, actor string) (interfaces.Reservation, error) {
+	switch {
+	case id != 7:
+		return interfaces.Reservation{}, repository.ErrReservationNotFound
+	case amount.GreaterThan(decimal.NewFromInt(100)):
+		return interfaces.Reservation{}, fmt.Errorf("%w: at most 100.00 points are reserved", service.ErrInvalidReservation)
+	case amount.Equal(decimal.NewFromInt(99)):
+		return interfaces.Reservation{}, fmt.Errorf("%w: reservation expired", service.ErrReservationState)
+	}
+
+	captured, _ := amount.Float64()
+
+	if amount.IsZero() {
+		captured = 100
+	}
+
+	return interfaces.Reservation{ID: id, OrderNumber: "2377225624", Sum: 100, Captured: captured, Status: repository.ReservationCaptured}, nil
+}
+
+func (rs *MockReservationAdminService) Release(ctx context.Context, id int64, actor string) (interfaces.Reservation, error) {
+	return interfaces.Reservation{}, repository.ErrReservationNotFound
+}
+
+func TestReservationCapture(t *testing.T) {
+	handler := ReservationHandler{ReservationService: &MockReservationAdminService{}}
+
+	router := chi.NewRouter()
+	router.Post("/api/reservations/{id}/capture", handler.Capture)
+	router.Post("/api/reservations/{id}/release", handler.Release)
+
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		wantStatus   int
+		wantCaptured string
+	}{
+		{name: "whole hold", path: "/api/reservations/7/capture", wantStatus: http.StatusOK, wantCaptured: `"captured":100`},
+		{name: "part of hold", path: "/api/reservations/7/capture", body: `{"amount":40}`, wantStatus: http.StatusOK, wantCaptured: `"captured":40`},
+		{name: "above hold", path: "/api/reservations/7/capture", body: `{"amount":400}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "expired", path: "/api/reservations/7/capture", body: `{"amount":99}`, wantStatus: http.StatusConflict},
+		{name: "unknown", path: "/api/reservations/1/capture", wantStatus: http.StatusNotFound},
+		{name: "malformed id", path: "/api/reservations/2377225624x/capture", wantStatus: http.StatusNotFound},
+		{name: "release unknown", path: "/api/reservations/1/release", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantCaptured != "" && !strings.Contains(rr.Body.String(), tt.wantCaptured) {
+				t.Errorf("Expected %s in body, got %s", tt.wantCaptured, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"gophermart/internal/pagination"
+	"gophermart/internal/statement"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// responseTracker reports whether anything reached the response, after which
+// an error can no longer change its status.
+type responseTracker struct {
+	w       io.Writer
+	written bool
+}
+
+func (rt *responseTracker) Write(p []byte) (int, error) {
+	rt.written = true
+
+	return rt.w.Write(p)
+}
+
+// Statement streams the statement of [from, to) as CSV or PDF. Both ends of
+// the period are required; a date without time in "to" includes that day.
+func (uh *UserHandler) Statement(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+	q := r.URL.Query()
+
+	from, to, err := pagination.ParseRange(q)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if from.IsZero() || to.IsZero() {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+
+	format := q.Get("format")
+
+	if format == "" {
+		format = statement.FormatCSV
+	}
+
+	tracker := &responseTracker{w: w}
+
+	var writer interfaces.StatementWriter
+
+	switch format {
+	case statement.FormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8; header=present")
+		writer = statement.NewCSV(tracker)
+	case statement.FormatPDF:
+		w.Header().Set("Content-Type", "application/pdf")
+		writer = statement.NewPDF(tracker)
+	default:
+		http.Error(w, "format must be csv or pdf", http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s.%s", from.Format("20060102"), to.Format("20060102"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := uh.StatementService.Statement(r.Context(), userID, from, to, writer); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write statement", "format", format, "error", err)
+
+		if !tracker.written {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockStatementService struct {
+	Err error
+}
+
+func (m *MockStatementService) Statement(ctx context.Context, userID int, from time.Time, to time.Time, w interfaces.StatementWriter) error {
+	if m.Err != nil {
+		return m.Err
+	}
+
+	if err := w.Begin(interfaces.StatementPeriod{Username: "user", From: from, To: to, Opening: decimal.NewFromInt(10)}); err != nil {
+		return err
+	}
+
+	return w.End(interfaces.StatementTotals{Closing: decimal.NewFromInt(10)})
+}
+
+func TestStatement(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		err             error
+		wantStatus      int
+		wantType        string
+		wantBody        string
+		wantDisposition string
+	}{
+		{
+			name: "csv", query: "from=2026-09-01&to=2026-09-30", wantStatus: http.StatusOK,
+			wantType: "text/csv; charset=utf-8; header=present", wantBody: "date,type,order,status,amount,balance\r\n",
+			wantDisposition: `attachment; filename="statement-20260901-20261001.csv"`,
+		},
+		{
+			name: "pdf", query: "from=2026-09-01&to=2026-09-30&format=pdf", wantStatus: http.StatusOK,
+			wantType: "application/pdf", wantBody: "%PDF-1.4\n",
+			wantDisposition: `attachment; filename="statement-20260901-20261001.pdf"`,
+		},
+		{name: "missing to", query: "from=2026-09-01", wantStatus: http.StatusBadRequest},
+		{name: "reversed", query: "from=2026-09-30&to=2026-09-01", wantStatus: http.StatusBadRequest},
+		{name: "unknown format", query: "from=2026-09-01&to=2026-09-30&format=xlsx", wantStatus: http.StatusBadRequest},
+		{name: "failed", query: "from=2026-09-01&to=2026-09-30", err: errors.New("boom"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := UserHandler{StatementService: &MockStatementService{Err: tt.err}}
+
+			req := httptest.NewRequest("GET", "/api/user/statement?"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.Statement).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if got := rr.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.wantType, got)
+			}
+
+			if got := rr.Header().Get("Content-Disposition"); got != tt.wantDisposition {
+				t.Errorf("Expected Content-Disposition %q, got %q", tt.wantDisposition, got)
+			}
+
+			if !strings.HasPrefix(rr.Body.String(), tt.wantBody) {
+				t.Errorf("Expected body to start with %q, got %q", tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
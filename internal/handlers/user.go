@@ -1,40 +1,72 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
 	"gophermart/internal/accrual"
+	"gophermart/internal/events"
 	"gophermart/internal/interfaces"
+	"gophermart/internal/metrics"
 	"gophermart/internal/middleware"
 	"gophermart/internal/models"
+	"gophermart/internal/pagination"
 	"gophermart/internal/repository"
+	"gophermart/internal/service"
+	"gophermart/internal/tracing"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
 type UserHandler struct {
-	UserService          interfaces.UserServiceInterface
-	OrderService         interfaces.OrderServiceInterface
-	WithdrawService      interfaces.WithdrawRepositoryInterface
-	UserBalanceService   interfaces.UserBalanceRepositoryInterface
-	AccrualSystemAddress string
-	DBConnectionString   string
-	TokenGenerator       TokenGeneratorInterface
+	UserService        interfaces.UserServiceInterface
+	OrderService       interfaces.OrderServiceInterface
+	WithdrawService    interfaces.WithdrawRepositoryInterface
+	UserBalanceService interfaces.UserBalanceRepositoryInterface
+	TierService        interfaces.TierServiceInterface
+	ReferralService    interfaces.ReferralServiceInterface
+	TransferService    interfaces.TransferServiceInterface
+	ReservationService interfaces.ReservationServiceInterface
+	StatementService   interfaces.StatementServiceInterface
+	PrivacyService     interfaces.PrivacyServiceInterface
+	AccrualProcessor   *accrual.Processor
+	EventBroker        *events.Broker
+	DBConnectionString string
+	TokenGenerator     TokenGeneratorInterface
+	Cookie             CookieSettings
 }
 
 type TokenGeneratorInterface interface {
 	GenerateToken(user models.User) (string, error)
 }
 
-type TokenGenerator struct{}
+type TokenGenerator struct {
+	SecretKey string
+	TTL       time.Duration
+}
+
+type CookieSettings struct {
+	Name     string
+	MaxAge   time.Duration
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+var DefaultCookieSettings = CookieSettings{
+	Name:     "token",
+	MaxAge:   time.Hour,
+	SameSite: http.SameSiteLaxMode,
+}
 
 func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var user models.User
@@ -46,9 +78,10 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	userRepository := uh.UserService.GetUserRepository()
 
-	userID := userRepository.GetUserID(user.Username)
+	userID := userRepository.GetUserID(r.Context(), user.Username)
 
 	if userID == repository.DatabaseError {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "login", user.Username)
 		http.Error(w, "Failed to register user", http.StatusInternalServerError)
 		return
 	}
@@ -63,6 +96,7 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	err := dbStorage.Init(uh.DBConnectionString)
 
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to initialize db connection", "error", err)
 		http.Error(w, "Failed to register user", http.StatusInternalServerError)
 		return
 	}
@@ -74,13 +108,21 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if user, err = uh.UserService.RegisterUser(user); err != nil {
+	if user, err = uh.UserService.RegisterUser(r.Context(), user); err != nil {
+		if errors.Is(err, service.ErrInvalidReferralCode) {
+			http.Error(w, "Неверный реферальный код", http.StatusBadRequest)
+			_ = dbStorage.Rollback()
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to register user", "login", user.Username, "error", err)
 		http.Error(w, "Failed to register user", http.StatusInternalServerError)
 		_ = dbStorage.Rollback()
 		return
 	}
 
-	if err = uh.UserBalanceService.CreateUserBalance(user); err != nil {
+	if err = uh.UserBalanceService.CreateUserBalance(r.Context(), user); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create user balance", "login", user.Username, "error", err)
 		http.Error(w, "Failed to register user", http.StatusInternalServerError)
 		_ = dbStorage.Rollback()
 		return
@@ -98,15 +140,10 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    token,
-		HttpOnly: true,
-		Path:     "/",
-		MaxAge:   3600,
-	})
+	uh.setTokenCookie(w, token)
 
 	dbStorage.Close()
+	metrics.RegistrationsTotal.Inc()
 
 	w.Header().Set("Authorization", token)
 	w.WriteHeader(http.StatusOK)
@@ -122,9 +159,10 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authUser, err := uh.UserService.AuthenticateUser(creds.Username, creds.Password)
+	authUser, err := uh.UserService.AuthenticateUser(r.Context(), creds.Username, creds.Password)
 
 	if err != nil {
+		metrics.FailedLoginsTotal.Inc()
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -135,26 +173,61 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	uh.setTokenCookie(w, token)
+
+	w.Header().Set("Authorization", token)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Login successful"})
+}
+
+func (uh *UserHandler) setTokenCookie(w http.ResponseWriter, token string) {
+	uh.writeTokenCookie(w, token, false)
+}
+
+// clearTokenCookie makes the browser drop the token cookie.
+func (uh *UserHandler) clearTokenCookie(w http.ResponseWriter) {
+	uh.writeTokenCookie(w, "", true)
+}
+
+func (uh *UserHandler) writeTokenCookie(w http.ResponseWriter, token string, expire bool) {
+	settings := uh.Cookie
+
+	if settings.Name == "" {
+		settings = DefaultCookieSettings
+	}
+
+	maxAge := int(settings.MaxAge.Seconds())
+
+	if expire {
+		maxAge = -1
+	}
+
 	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
+		Name:     settings.Name,
 		Value:    token,
 		HttpOnly: true,
 		Path:     "/",
-		MaxAge:   3600,
+		MaxAge:   maxAge,
+		Secure:   settings.Secure,
+		SameSite: settings.SameSite,
+		Domain:   settings.Domain,
 	})
-
-	w.Header().Set("Authorization", token)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Login successful"})
 }
 
 func (gt *TokenGenerator) GenerateToken(user models.User) (string, error) {
+	ttl := gt.TTL
+
+	if ttl == 0 {
+		ttl = time.Hour * 72
+	}
+
 	claims := jwt.MapClaims{
 		"id":  user.ID,
-		"exp": time.Now().Add(time.Hour * 72).Unix(),
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(ttl).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(middleware.SecretKey))
+	return token.SignedString([]byte(gt.SecretKey))
 }
 
 func (uh *UserHandler) SaveOrder(w http.ResponseWriter, r *http.Request) {
@@ -170,6 +243,7 @@ func (uh *UserHandler) SaveOrder(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	orderNumber := string(body)
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.OrderNumberKey.String(orderNumber))
 	isDigit := isDigits(orderNumber)
 
 	if !isDigit {
@@ -182,9 +256,10 @@ func (uh *UserHandler) SaveOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := uh.OrderService.GetOrderID(orderNumber, userID)
+	result, err := uh.OrderService.GetOrderID(r.Context(), orderNumber, userID)
 
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up order", "order", orderNumber, "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
@@ -197,90 +272,163 @@ func (uh *UserHandler) SaveOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = uh.OrderService.SaveOrder(orderNumber, userID)
+	err = uh.OrderService.SaveOrder(r.Context(), orderNumber, userID)
 
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save order", "order", orderNumber, "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	if uh.AccrualProcessor != nil {
+		uh.AccrualProcessor.Enqueue(r.Context(), orderNumber, userID)
+	}
+}
 
-	go func() {
-		defer wg.Done()
+func isDigits(s string) bool {
+	re := regexp.MustCompile(`^\d+$`)
+	return re.MatchString(s)
+}
 
-		_, cancel := context.WithTimeout(context.Background(), time.Minute)
-		defer cancel()
+func (uh *UserHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
 
-		orderRepository := uh.OrderService.GetOrderRepository()
-		dbStorage := orderRepository.GetDBStorage()
+	if pagination.Requested(r.URL.Query(), "status") {
+		uh.getOrdersPage(w, r, userID)
+		return
+	}
 
-		if err := dbStorage.Init(uh.DBConnectionString); err != nil {
-			log.Printf("Error while initializing db connection: %v", err)
-			return
-		}
+	orderData, err := uh.OrderService.GetUserOrders(r.Context(), userID)
 
-		if err := dbStorage.BeginTransaction(); err != nil {
-			log.Printf("Failed to begin transaction: %v", err)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoOrdersFound) {
+			http.Error(w, err.Error(), http.StatusNoContent)
 			return
 		}
 
-		var registerResponse accrual.RegisterResponse
-		registerResponse, err := accrual.GetOrderInfo(uh.AccrualSystemAddress, orderNumber)
-		if err != nil {
-			log.Printf("Failed to get order info: %v", err)
-			_ = dbStorage.Rollback()
-			return
-		}
+		slog.ErrorContext(r.Context(), "Failed to get user orders", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
 
-		if registerResponse.Order != "" {
-			if err := uh.OrderService.UpdateOrder(orderNumber, registerResponse.Accrual, registerResponse.Status); err != nil {
-				_ = dbStorage.Rollback()
-				log.Printf("Failed to update order: %v", err)
-				return
-			}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 
-			if err := uh.UserBalanceService.UpdateUserBalance(registerResponse.Accrual, userID); err != nil {
-				_ = dbStorage.Rollback()
-				log.Printf("Failed to update user balance: %v", err)
-				return
-			}
+	jsonData, err := json.Marshal(orderData)
 
-			// Здесь можно выполнить коммит после успешных операций
-			if err := dbStorage.Commit(); err != nil {
-				log.Printf("Failed to commit transaction: %v", err)
-				return
-			}
-		} else {
-			log.Printf("Order not found in accrual service")
-			if err := dbStorage.Rollback(); err != nil {
-				log.Printf("Failed to rollback transaction: %v", err)
-			}
-		}
-	}()
+	if err != nil {
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
 
-	wg.Wait()
-}
+	_, err = w.Write(jsonData)
 
-func isDigits(s string) bool {
-	re := regexp.MustCompile(`^\d+$`)
-	return re.MatchString(s)
+	if err != nil {
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
 }
 
-func (uh *UserHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
+// GetOrder serves GET /api/user/orders/{number}: the order, its status
+// timeline and, while it is still being polled, when the next poll is due.
+func (uh *UserHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(middleware.UserIDKey).(int)
+	orderNumber := chi.URLParam(r, "number")
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.OrderNumberKey.String(orderNumber))
 
-	orderData, err := uh.OrderService.GetUserOrders(userID)
+	if !isDigits(orderNumber) {
+		http.Error(w, "Неверный формат номера заказа", http.StatusBadRequest)
+		return
+	}
+
+	order, err := uh.OrderService.GetUserOrder(r.Context(), userID, orderNumber)
 
 	if err != nil {
-		if errors.Is(err, repository.ErrNoOrdersFound) {
-			http.Error(w, err.Error(), http.StatusNoContent)
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			http.Error(w, "Заказ не найден", http.StatusNotFound)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to get order", "order", orderNumber, "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if uh.AccrualProcessor != nil && (order.Status == repository.NEW || order.Status == repository.PROCESSING) {
+		nextPoll := uh.AccrualProcessor.NextScan()
+		order.NextPollAt = &nextPoll
+	}
+
+	writeJSON(w, r, order)
+}
+
+// getOrdersPage serves GET /api/user/orders when any of limit, cursor, sort,
+// status, from or to is given. The body keeps the unpaginated format; the
+// next page is advertised in the Link and X-Next-Cursor headers.
+func (uh *UserHandler) getOrdersPage(w http.ResponseWriter, r *http.Request, userID int) {
+	q := r.URL.Query()
+	page, err := pagination.Parse(q, repository.OrderSortFields, "-"+repository.OrderSortUploadedAt)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseOrderFilter(q)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	orderData, next, err := uh.OrderService.GetUserOrdersPage(r.Context(), userID, filter, page)
+
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
+		slog.ErrorContext(r.Context(), "Failed to get user orders", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if len(orderData) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	pagination.SetNext(w, r, next)
+	writeJSON(w, r, orderData)
+}
+
+func parseOrderFilter(q url.Values) (interfaces.OrderFilter, error) {
+	var filter interfaces.OrderFilter
+
+	if raw := q.Get("status"); raw != "" {
+		for _, status := range strings.Split(raw, ",") {
+			status = strings.ToUpper(strings.TrimSpace(status))
+
+			switch status {
+			case repository.NEW, repository.PROCESSING, repository.INVALID, repository.PROCESSED:
+				filter.Statuses = append(filter.Statuses, status)
+			default:
+				return filter, fmt.Errorf("unknown order status %q", status)
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	jsonData, err := json.Marshal(v)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode response", "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
@@ -288,25 +436,46 @@ func (uh *UserHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	jsonData, err := json.Marshal(orderData)
+	if _, err := w.Write(jsonData); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+// GetTier reports the loyalty tier of the user and their progress toward the
+// next one.
+func (uh *UserHandler) GetTier(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	status, err := uh.TierService.GetTierStatus(r.Context(), userID)
 
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get tier", "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = w.Write(jsonData)
+	writeJSON(w, r, status)
+}
+
+// GetReferrals returns the user's referral code and the users invited with it.
+func (uh *UserHandler) GetReferrals(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	referrals, err := uh.ReferralService.GetReferrals(r.Context(), userID)
 
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get referrals", "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
+
+	writeJSON(w, r, referrals)
 }
 
 func (uh *UserHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(middleware.UserIDKey).(int)
 
-	userBalance, err := uh.UserBalanceService.GetUserBalance(userID)
+	userBalance, err := uh.UserBalanceService.GetUserBalance(r.Context(), userID)
 
 	if err != nil {
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
@@ -342,6 +511,7 @@ func (uh *UserHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.OrderNumberKey.String(withdraw.Order))
 	isDigit := isDigits(withdraw.Order)
 
 	if !isDigit {
@@ -349,7 +519,7 @@ func (uh *UserHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := uh.OrderService.GetOrderID(withdraw.Order, userID)
+	result, err := uh.OrderService.GetOrderID(r.Context(), withdraw.Order, userID)
 
 	if err != nil {
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
@@ -362,9 +532,10 @@ func (uh *UserHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var code int
-	code, err = uh.WithdrawService.Withdraw(userID, withdraw.Order, withdraw.Sum)
+	code, err = uh.WithdrawService.Withdraw(r.Context(), userID, withdraw.Order, withdraw.Sum)
 
 	if code == repository.WithdrawTransactionError {
+		slog.ErrorContext(r.Context(), "Failed to withdraw", "order", withdraw.Order, "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
@@ -383,32 +554,210 @@ func (uh *UserHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (uh *UserHandler) Withdrawals(w http.ResponseWriter, r *http.Request) {
+// Transfer sends points to another user. Repeating a request with the same
+// Idempotency-Key returns the original transfer instead of sending again.
+func (uh *UserHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	var request interfaces.TransferRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	request.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	transfer, replayed, err := uh.TransferService.Transfer(r.Context(), userID, request)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTransfer):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrRecipientNotFound):
+			http.Error(w, "Получатель не найден", http.StatusNotFound)
+		case errors.Is(err, service.ErrIdempotencyConflict):
+			http.Error(w, "Ключ идемпотентности уже использован для другого перевода", http.StatusConflict)
+		case errors.Is(err, service.ErrNotEnoughPoints):
+			http.Error(w, "На счету недостаточно средств", http.StatusPaymentRequired)
+		case errors.Is(err, service.ErrTransferLimit):
+			http.Error(w, "Превышен дневной лимит переводов", http.StatusUnprocessableEntity)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to transfer", "to", request.To, "error", err)
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	writeJSON(w, r, transfer)
+}
+
+// Reserve holds points for an order at checkout until the shop captures or
+// releases them.
+func (uh *UserHandler) Reserve(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(middleware.UserIDKey).(int)
 
-	withdrawalInfo, err := uh.WithdrawService.Withdrawals(userID)
+	var request interfaces.ReservationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	if !isDigits(request.Order) || !ValidateNumber(request.Order) {
+		http.Error(w, "Неверный номер заказа", http.StatusUnprocessableEntity)
+		return
+	}
+
+	reservation, err := uh.ReservationService.Reserve(r.Context(), userID, request)
 
 	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidReservation):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, repository.ErrReservationExists):
+			http.Error(w, "Баллы по заказу уже удерживаются", http.StatusConflict)
+		case errors.Is(err, service.ErrNotEnoughPoints):
+			http.Error(w, "На счету недостаточно средств", http.StatusPaymentRequired)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to reserve points", "order", request.Order, "error", err)
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	jsonData, err := json.Marshal(reservation)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode response", "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusCreated)
+
+	if _, err := w.Write(jsonData); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+func (uh *UserHandler) Reservations(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
 
-	jsonData, err := json.Marshal(withdrawalInfo)
+	reservations, err := uh.ReservationService.Reservations(r.Context(), userID)
 
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get reservations", "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = w.Write(jsonData)
+	if len(reservations) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, r, reservations)
+}
+
+// ReleaseReservation lets the user abandon a checkout before the shop does.
+func (uh *UserHandler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+	order := chi.URLParam(r, "order")
+
+	reservation, err := uh.ReservationService.ReleaseOwn(r.Context(), userID, order)
+
+	if err != nil {
+		writeReservationError(w, r, err, "order", order)
+		return
+	}
+
+	writeJSON(w, r, reservation)
+}
+
+func (uh *UserHandler) Transfers(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	transfers, err := uh.TransferService.Transfers(r.Context(), userID)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get transfers", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if len(transfers) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, r, transfers)
+}
+
+func (uh *UserHandler) Withdrawals(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(int)
+
+	if pagination.Requested(r.URL.Query()) {
+		uh.getWithdrawalsPage(w, r, userID)
+		return
+	}
+
+	withdrawalInfo, err := uh.WithdrawService.Withdrawals(r.Context(), userID)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get withdrawals", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if len(withdrawalInfo) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, r, withdrawalInfo)
+}
+
+// getWithdrawalsPage follows the same contract as getOrdersPage. Totals of the
+// selected period, across all pages, are returned in the X-Total-Count and
+// X-Total-Sum headers.
+func (uh *UserHandler) getWithdrawalsPage(w http.ResponseWriter, r *http.Request, userID int) {
+	page, err := pagination.Parse(r.URL.Query(), repository.WithdrawalSortFields, "-"+repository.WithdrawalSortProcessedAt)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := uh.WithdrawService.WithdrawalsPage(r.Context(), userID, page)
 
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to get withdrawals", "error", err)
 		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(result.TotalCount))
+	w.Header().Set("X-Total-Sum", result.TotalSum.String())
+
+	if len(result.Withdrawals) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	pagination.SetNext(w, r, result.Next)
+	writeJSON(w, r, result.Withdrawals)
 }
 
 func ValidateNumber(orderNumber string) bool {
@@ -2,18 +2,25 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v4"
 	"github.com/shopspring/decimal"
 	"gophermart/internal/interfaces"
 	"gophermart/internal/middleware"
 	"gophermart/internal/models"
+	"gophermart/internal/pagination"
 	"gophermart/internal/repository"
+	"gophermart/internal/service"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 type MockUserService struct {
@@ -22,15 +29,15 @@ type MockUserService struct {
 	GetUserRepositoryFunc func() interfaces.UserRepositoryInterface
 }
 
-func (m *MockUserService) GetUserID(username string) int {
+func (m *MockUserService) GetUserID(ctx context.Context, username string) int {
 	return 1
 }
 
-func (m *MockUserService) RegisterUser(u models.User) (models.User, error) {
+func (m *MockUserService) RegisterUser(ctx context.Context, u models.User) (models.User, error) {
 	return m.RegisterUserFunc(u)
 }
 
-func (m *MockUserService) AuthenticateUser(username, password string) (models.User, error) {
+func (m *MockUserService) AuthenticateUser(ctx context.Context, username, password string) (models.User, error) {
 	return m.AuthenticateUserFunc(username, password)
 }
 
@@ -42,15 +49,15 @@ type MockUserRepository struct {
 	GetUserIDFunc func(string) int
 }
 
-func (ur *MockUserRepository) CreateUser(user models.User) (int, error) {
+func (ur *MockUserRepository) CreateUser(ctx context.Context, user models.User) (int, error) {
 	return -1, nil
 }
 
-func (ur *MockUserRepository) GetUserID(username string) int {
+func (ur *MockUserRepository) GetUserID(ctx context.Context, username string) int {
 	return ur.GetUserIDFunc(username)
 }
 
-func (ur *MockUserRepository) GetUserByUsername(username string) (models.User, error) {
+func (ur *MockUserRepository) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
 	return models.User{}, nil
 }
 
@@ -62,24 +69,63 @@ type MockOrderService struct {
 	RegisterUserFunc       func(models.User) (models.User, error)
 	AuthenticateUserFunc   func(string, string) (models.User, error)
 	GetOrderRepositoryFunc func() interfaces.OrderRepositoryInterface
+	GetUserOrdersPageFunc  func(interfaces.OrderFilter, pagination.Page) ([]interfaces.OrderData, *pagination.Cursor, error)
+	SaveOrdersFunc         func([]string) ([]int, error)
+	GetUserOrderFunc       func(string) (interfaces.OrderDetail, error)
 }
 
-func (os *MockOrderService) GetOrderID(orderNumber string, userID int) (int, error) {
+func (os *MockOrderService) GetOrderID(ctx context.Context, orderNumber string, userID int) (int, error) {
 	return -1, nil
 }
 
-func (os *MockOrderService) SaveOrder(orderNumber string, userID int) error {
+func (os *MockOrderService) SaveOrder(ctx context.Context, orderNumber string, userID int) error {
 	return nil
 }
 
-func (os *MockOrderService) UpdateOrder(orderNumber string, accrual decimal.Decimal, status string) error {
+func (os *MockOrderService) SaveOrders(ctx context.Context, orderNumbers []string, userID int) ([]int, error) {
+	if os.SaveOrdersFunc != nil {
+		return os.SaveOrdersFunc(orderNumbers)
+	}
+
+	return make([]int, len(orderNumbers)), nil
+}
+
+func (os *MockOrderService) UpdateOrder(ctx context.Context, orderNumber string, accrual decimal.Decimal, status string) error {
 	return nil
 }
 
-func (os *MockOrderService) GetUserOrders(userID int) ([]interfaces.OrderData, error) {
+func (os *MockOrderService) GetUserOrders(ctx context.Context, userID int) ([]interfaces.OrderData, error) {
 	return []interfaces.OrderData{}, nil
 }
 
+func (os *MockOrderService) GetUserOrdersPage(ctx context.Context, userID int, filter interfaces.OrderFilter, page pagination.Page) ([]interfaces.OrderData, *pagination.Cursor, error) {
+	if os.GetUserOrdersPageFunc != nil {
+		return os.GetUserOrdersPageFunc(filter, page)
+	}
+
+	return nil, nil, nil
+}
+
+func (os *MockOrderService) GetUserOrder(ctx context.Context, userID int, orderNumber string) (interfaces.OrderDetail, error) {
+	if os.GetUserOrderFunc != nil {
+		return os.GetUserOrderFunc(orderNumber)
+	}
+
+	return interfaces.OrderDetail{}, repository.ErrOrderNotFound
+}
+
+func (os *MockOrderService) MarkPolled(ctx context.Context, orderNumber string) error {
+	return nil
+}
+
+func (os *MockOrderService) GetPendingOrders(ctx context.Context, limit int) ([]interfaces.PendingOrder, error) {
+	return nil, nil
+}
+
+func (os *MockOrderService) ApplyAccrual(ctx context.Context, orderNumber string, status string, accrual decimal.Decimal) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
+
 func (os *MockOrderService) GetOrderRepository() interfaces.OrderRepositoryInterface {
 	return os.GetOrderRepositoryFunc()
 }
@@ -88,26 +134,51 @@ type MockOrderRepository struct {
 	GetDBStorageFunc func() interfaces.DBStorageInterface
 }
 
-func (or *MockOrderRepository) GetOrderID(orderNumber string, userID int) (int, error) {
+func (or *MockOrderRepository) GetOrderID(ctx context.Context, orderNumber string, userID int) (int, error) {
 	return -1, nil
 }
 
-func (or *MockOrderRepository) SaveOrder(orderNumber string, userID int) error {
+func (or *MockOrderRepository) SaveOrder(ctx context.Context, orderNumber string, userID int) error {
 	return nil
 }
 
-func (or *MockOrderRepository) UpdateOrder(orderNumber string, accrual decimal.Decimal, status string) error {
+func (or *MockOrderRepository) UpdateOrder(ctx context.Context, orderNumber string, accrual decimal.Decimal, status string) error {
 	return nil
 }
 
-func (or *MockOrderRepository) GetUserOrders(userID int) ([]interfaces.OrderData, error) {
+func (or *MockOrderRepository) GetUserOrders(ctx context.Context, userID int) ([]interfaces.OrderData, error) {
 	return []interfaces.OrderData{}, nil
 }
 
+func (or *MockOrderRepository) GetUserOrdersPage(ctx context.Context, userID int, filter interfaces.OrderFilter, page pagination.Page) ([]interfaces.OrderData, *pagination.Cursor, error) {
+	return nil, nil, nil
+}
+
 func (or *MockOrderRepository) GetDBStorage() interfaces.DBStorageInterface {
 	return or.GetDBStorageFunc()
 }
 
+func (or *MockOrderRepository) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+type MockWithdrawService struct {
+	WithdrawalsFunc     func() ([]interfaces.WithdrawInfo, error)
+	WithdrawalsPageFunc func(pagination.Page) (interfaces.WithdrawalsPage, error)
+}
+
+func (ws *MockWithdrawService) Withdraw(ctx context.Context, userID int, orderNumber string, sum decimal.Decimal) (int, error) {
+	return 0, nil
+}
+
+func (ws *MockWithdrawService) Withdrawals(ctx context.Context, userID int) ([]interfaces.WithdrawInfo, error) {
+	return ws.WithdrawalsFunc()
+}
+
+func (ws *MockWithdrawService) WithdrawalsPage(ctx context.Context, userID int, page pagination.Page) (interfaces.WithdrawalsPage, error) {
+	return ws.WithdrawalsPageFunc(page)
+}
+
 type MockDBStorage struct {
 	InitFunc             func() error
 	BeginTransactionFunc func() error
@@ -132,15 +203,20 @@ func (dbs *MockDBStorage) QueryRow(query string, args ...interface{}) pgx.Row {
 
 type MockUserBalanceRepository struct {
 	CreateUserBalanceFunc func(models.User) error
+	GetUserBalanceFunc    func() (interfaces.UserBalance, error)
 }
 
-func (ubr *MockUserBalanceRepository) UpdateUserBalance(accrual decimal.Decimal, userID int) error {
+func (ubr *MockUserBalanceRepository) UpdateUserBalance(ctx context.Context, accrual decimal.Decimal, userID int) error {
 	return nil
 }
-func (ubr *MockUserBalanceRepository) GetUserBalance(userID int) (interfaces.UserBalance, error) {
-	return interfaces.UserBalance{}, nil
+func (ubr *MockUserBalanceRepository) GetUserBalance(ctx context.Context, userID int) (interfaces.UserBalance, error) {
+	if ubr.GetUserBalanceFunc == nil {
+		return interfaces.UserBalance{}, nil
+	}
+
+	return ubr.GetUserBalanceFunc()
 }
-func (ubr *MockUserBalanceRepository) CreateUserBalance(user models.User) error {
+func (ubr *MockUserBalanceRepository) CreateUserBalance(ctx context.Context, user models.User) error {
 	return ubr.CreateUserBalanceFunc(user)
 }
 
@@ -216,6 +292,57 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+func TestRegister_InvalidReferralCode(t *testing.T) {
+	mockUserService := &MockUserService{
+		RegisterUserFunc: func(user models.User) (models.User, error) {
+			if user.ReferralCode != "NOPE2345" {
+				t.Errorf("Expected the referral code to be passed through, got %q", user.ReferralCode)
+			}
+
+			return user, service.ErrInvalidReferralCode
+		},
+		GetUserRepositoryFunc: func() interfaces.UserRepositoryInterface {
+			return &MockUserRepository{
+				GetUserIDFunc: func(username string) int {
+					return repository.UserNotFound
+				},
+			}
+		},
+	}
+	dbStorage := &MockDBStorage{
+		InitFunc: func() error {
+			return nil
+		},
+		BeginTransactionFunc: func() error {
+			return nil
+		},
+	}
+	orderService := &MockOrderService{
+		GetOrderRepositoryFunc: func() interfaces.OrderRepositoryInterface {
+			return &MockOrderRepository{
+				GetDBStorageFunc: func() interfaces.DBStorageInterface {
+					return dbStorage
+				},
+			}
+		},
+	}
+	handler := UserHandler{
+		UserService:        mockUserService,
+		OrderService:       orderService,
+		DBConnectionString: "fake-connection-string",
+	}
+
+	body := `{"login":"testuser","password":"password","referral_code":"NOPE2345"}`
+	req := httptest.NewRequest("POST", "/api/user/register", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.Register).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %v", rr.Code)
+	}
+}
+
 func TestRegister_InvalidData(t *testing.T) {
 	user := "{ invalid json "
 	body := bytes.NewBuffer([]byte(user))
@@ -659,3 +786,505 @@ func TestLogin(t *testing.T) {
 		t.Errorf("Expected success message, got %v", message)
 	}
 }
+
+func TestGetOrders_Page(t *testing.T) {
+	var gotFilter interfaces.OrderFilter
+	var gotPage pagination.Page
+
+	orderService := &MockOrderService{
+		GetUserOrdersPageFunc: func(filter interfaces.OrderFilter, page pagination.Page) ([]interfaces.OrderData, *pagination.Cursor, error) {
+			gotFilter, gotPage = filter, page
+			return []interfaces.OrderData{{Number: "12345678903", Status: repository.PROCESSED}},
+				&pagination.Cursor{Sort: page.Sort, Value: "2024-09-01T00:00:00Z", ID: 7}, nil
+		},
+	}
+	handler := UserHandler{OrderService: orderService}
+
+	req := httptest.NewRequest("GET", "/api/user/orders?limit=1&status=processed,new", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.GetOrders).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v", rr.Code)
+	}
+
+	if gotPage.Limit != 1 || gotPage.Sort != "-uploaded_at" {
+		t.Errorf("Unexpected page %+v", gotPage)
+	}
+
+	if len(gotFilter.Statuses) != 2 || gotFilter.Statuses[0] != repository.PROCESSED {
+		t.Errorf("Unexpected filter %+v", gotFilter)
+	}
+
+	if rr.Header().Get("X-Next-Cursor") == "" || rr.Header().Get("Link") == "" {
+		t.Error("Expected next page to be advertised")
+	}
+
+	var orders []interfaces.OrderData
+	if err := json.NewDecoder(rr.Body).Decode(&orders); err != nil || len(orders) != 1 {
+		t.Errorf("Expected a plain array of orders, got %v (%v)", orders, err)
+	}
+}
+
+func TestGetOrders_PageInvalidStatus(t *testing.T) {
+	handler := UserHandler{OrderService: &MockOrderService{}}
+
+	req := httptest.NewRequest("GET", "/api/user/orders?status=DONE", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.GetOrders).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %v", rr.Code)
+	}
+}
+
+func TestWithdrawals_Empty(t *testing.T) {
+	withdrawService := &MockWithdrawService{
+		WithdrawalsFunc: func() ([]interfaces.WithdrawInfo, error) {
+			return nil, nil
+		},
+	}
+	handler := UserHandler{WithdrawService: withdrawService}
+
+	req := httptest.NewRequest("GET", "/api/user/withdrawals", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.Withdrawals).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %v", rr.Code)
+	}
+}
+
+func TestWithdrawals_Page(t *testing.T) {
+	var gotPage pagination.Page
+
+	withdrawService := &MockWithdrawService{
+		WithdrawalsPageFunc: func(page pagination.Page) (interfaces.WithdrawalsPage, error) {
+			gotPage = page
+			return interfaces.WithdrawalsPage{
+				Withdrawals: []interfaces.WithdrawInfo{{OrderNumber: "2377225624", Sum: 500}},
+				TotalCount:  3,
+				TotalSum:    decimal.NewFromInt(751),
+			}, nil
+		},
+	}
+	handler := UserHandler{WithdrawService: withdrawService}
+
+	req := httptest.NewRequest("GET", "/api/user/withdrawals?from=2024-09-01&sort=sum", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.Withdrawals).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v", rr.Code)
+	}
+
+	if gotPage.Sort != "sum" || gotPage.From.IsZero() {
+		t.Errorf("Unexpected page %+v", gotPage)
+	}
+
+	if rr.Header().Get("X-Total-Count") != "3" || rr.Header().Get("X-Total-Sum") != "751" {
+		t.Errorf("Unexpected totals %v", rr.Header())
+	}
+
+	if rr.Header().Get("Link") != "" {
+		t.Error("Expected no next link on the last page")
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	orderService := &MockOrderService{
+		GetUserOrderFunc: func(orderNumber string) (interfaces.OrderDetail, error) {
+			if orderNumber != "12345678903" {
+				return interfaces.OrderDetail{}, repository.ErrOrderNotFound
+			}
+
+			return interfaces.OrderDetail{
+				Number:  orderNumber,
+				Status:  repository.PROCESSED,
+				History: []interfaces.OrderStatusChange{{Status: repository.NEW}, {Status: repository.PROCESSED}},
+				Revisions: []interfaces.AccrualRevision{{
+					PreviousStatus: repository.PROCESSED, Status: repository.PROCESSED, PreviousAccrual: 500, Accrual: 300, Delta: -200,
+				}},
+			}, nil
+		},
+	}
+	handler := UserHandler{OrderService: orderService}
+
+	r := chi.NewRouter()
+	r.Get("/api/user/orders/{number}", handler.GetOrder)
+
+	tests := []struct {
+		number string
+		code   int
+	}{
+		{number: "12345678903", code: http.StatusOK},
+		{number: "79927398713", code: http.StatusNotFound},
+		{number: "abc", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/user/orders/"+tt.number, nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.number, tt.code, rr.Code)
+		}
+
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var order interfaces.OrderDetail
+		if err := json.NewDecoder(rr.Body).Decode(&order); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(order.History) != 2 || len(order.Revisions) != 1 || order.NextPollAt != nil {
+			t.Errorf("Unexpected order %+v", order)
+		}
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	expiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		balance interfaces.UserBalance
+		want    string
+	}{
+		{
+			name:    "nothing expiring",
+			balance: interfaces.UserBalance{Current: 500.5, Withdrawn: 42},
+			want:    `{"current":500.5,"withdrawn":42}`,
+		},
+		{
+			name: "expiring soon",
+			balance: interfaces.UserBalance{
+				Current:      500.5,
+				Withdrawn:    42,
+				ExpiringSoon: []interfaces.ExpiringPoints{{Amount: 100, ExpiresAt: expiresAt}},
+			},
+			want: `{"current":500.5,"withdrawn":42,"expiring_soon":[{"amount":100,"expires_at":"2026-11-01T00:00:00Z"}]}`,
+		},
+		{
+			name:    "reserved",
+			balance: interfaces.UserBalance{Current: 500.5, Withdrawn: 42, Reserved: 120},
+			want:    `{"current":500.5,"withdrawn":42,"reserved":120}`,
+		},
+		{
+			name:    "debt",
+			balance: interfaces.UserBalance{Withdrawn: 42, Debt: 75},
+			want:    `{"current":0,"withdrawn":42,"debt":75}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := UserHandler{
+				UserBalanceService: &MockUserBalanceRepository{
+					GetUserBalanceFunc: func() (interfaces.UserBalance, error) { return tt.balance, nil },
+				},
+			}
+
+			req := httptest.NewRequest("GET", "/api/user/balance", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.GetBalance).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %v", rr.Code)
+			}
+
+			if got := strings.TrimSpace(rr.Body.String()); got != tt.want {
+				t.Errorf("Expected body %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+type MockTierService struct {
+	GetTierStatusFunc func(userID int) (interfaces.TierStatus, error)
+}
+
+func (ts *MockTierService) GetTierStatus(ctx context.Context, userID int) (interfaces.TierStatus, error) {
+	return ts.GetTierStatusFunc(userID)
+}
+
+func TestGetTier(t *testing.T) {
+	handler := UserHandler{
+		TierService: &MockTierService{
+			GetTierStatusFunc: func(userID int) (interfaces.TierStatus, error) {
+				if userID != 1 {
+					return interfaces.TierStatus{}, errors.New("unexpected user")
+				}
+
+				return interfaces.TierStatus{
+					Tier:       "silver",
+					Multiplier: 1.1,
+					Points:     1500,
+					Orders:     12,
+					Next:       &interfaces.TierProgress{Tier: "gold", MinPoints: 5000, MinOrders: 50, PointsLeft: 3500, OrdersLeft: 38, Progress: 0.3},
+				}, nil
+			},
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/api/user/tier", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.GetTier).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v", rr.Code)
+	}
+
+	var status interfaces.TierStatus
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Tier != "silver" || status.Next == nil || status.Next.Tier != "gold" || status.Next.OrdersLeft != 38 {
+		t.Errorf("Unexpected tier status %+v", status)
+	}
+}
+
+type MockReferralService struct {
+	GetReferralsFunc func(userID int) (interfaces.Referrals, error)
+}
+
+func (rs *MockReferralService) GetReferrals(ctx context.Context, userID int) (interfaces.Referrals, error) {
+	return rs.GetReferralsFunc(userID)
+}
+
+func TestGetReferrals(t *testing.T) {
+	rewardedAt := time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC)
+	handler := UserHandler{
+		ReferralService: &MockReferralService{
+			GetReferralsFunc: func(userID int) (interfaces.Referrals, error) {
+				if userID != 1 {
+					return interfaces.Referrals{}, errors.New("unexpected user")
+				}
+
+				return interfaces.Referrals{
+					Code:   "ABCD2345",
+					Earned: 100,
+					Invitees: []interfaces.Invitee{
+						{Login: "friend", Status: "REWARDED", Bonus: 100, RegisteredAt: rewardedAt.Add(-24 * time.Hour), RewardedAt: &rewardedAt},
+						{Login: "newcomer", Status: "PENDING", RegisteredAt: rewardedAt},
+					},
+				}, nil
+			},
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/api/user/referrals", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.GetReferrals).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v", rr.Code)
+	}
+
+	var referrals interfaces.Referrals
+	if err := json.NewDecoder(rr.Body).Decode(&referrals); err != nil {
+		t.Fatal(err)
+	}
+
+	if referrals.Code != "ABCD2345" || len(referrals.Invitees) != 2 || referrals.Invitees[1].RewardedAt != nil {
+		t.Errorf("Unexpected referrals %+v", referrals)
+	}
+}
+
+type MockTransferService struct {
+	TransferFunc func(senderID int, request interfaces.TransferRequest) (interfaces.Transfer, bool, error)
+}
+
+func (ts *MockTransferService) Transfer(ctx context.Context, senderID int, request interfaces.TransferRequest) (interfaces.Transfer, bool, error) {
+	return ts.TransferFunc(senderID, request)
+}
+
+func (ts *MockTransferService) Transfers(ctx context.Context, userID int) ([]interfaces.Transfer, error) {
+	return nil, nil
+}
+
+func TestTransfer(t *testing.T) {
+	handler := UserHandler{
+		TransferService: &MockTransferService{
+			TransferFunc: func(senderID int, request interfaces.TransferRequest) (interfaces.Transfer, bool, error) {
+				switch {
+				case request.IdempotencyKey == "":
+					return interfaces.Transfer{}, false, fmt.Errorf("%w: key required", service.ErrInvalidTransfer)
+				case request.To == "nobody":
+					return interfaces.Transfer{}, false, service.ErrRecipientNotFound
+				case request.IdempotencyKey == "reused":
+					return interfaces.Transfer{}, false, service.ErrIdempotencyConflict
+				case request.Amount.GreaterThan(decimal.NewFromInt(100)):
+					return interfaces.Transfer{}, false, service.ErrNotEnoughPoints
+				}
+
+				amount, _ := request.Amount.Float64()
+
+				return interfaces.Transfer{ID: 1, Direction: "out", Counterparty: request.To, Amount: amount},
+					request.IdempotencyKey == "repeated", nil
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		key          string
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{name: "sent", body: `{"to":"family","amount":50}`, key: "k1", wantStatus: http.StatusOK},
+		{name: "replayed", body: `{"to":"family","amount":50}`, key: "repeated", wantStatus: http.StatusOK, wantReplayed: true},
+		{name: "no key", body: `{"to":"family","amount":50}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown recipient", body: `{"to":"nobody","amount":50}`, key: "k2", wantStatus: http.StatusNotFound},
+		{name: "key conflict", body: `{"to":"family","amount":50}`, key: "reused", wantStatus: http.StatusConflict},
+		{name: "not enough points", body: `{"to":"family","amount":500}`, key: "k3", wantStatus: http.StatusPaymentRequired},
+		{name: "malformed", body: `{`, key: "k4", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/user/balance/transfer", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
+			}
+
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.Transfer).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+
+			if replayed := rr.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("Expected replayed %v, got %v", tt.wantReplayed, replayed)
+			}
+		})
+	}
+}
+
+type MockReservationService struct {
+	ReleaseOwnFunc func(userID int, orderNumber string) (interfaces.Reservation, error)
+}
+
+func (rs *MockReservationService) Reserve(ctx context.Context, userID int, request interfaces.ReservationRequest) (interfaces.Reservation, error) {
+	switch {
+	case request.TTL < 0:
+		return interfaces.Reservation{}, fmt.Errorf("%w: ttl must be positive", service.ErrInvalidReservation)
+	case request.Order == "12345678903":
+		return interfaces.Reservation{}, repository.ErrReservationExists
+	case request.Sum.GreaterThan(decimal.NewFromInt(100)):
+		return interfaces.Reservation{}, service.ErrNotEnoughPoints
+	}
+
+	sum, _ := request.Sum.Float64()
+
+	return interfaces.Reservation{OrderNumber: request.Order, Sum: sum, Status: repository.ReservationHeld}, nil
+}
+
+func (rs *MockReservationService) ReleaseOwn(ctx context.Context, userID int, orderNumber string) (interfaces.Reservation, error) {
+	return rs.ReleaseOwnFunc(userID, orderNumber)
+}
+
+func (rs *MockReservationService) Reservations(ctx context.Context, userID int) ([]interfaces.Reservation, error) {
+	return nil, nil
+}
+
+func TestReserve(t *testing.T) {
+	handler := UserHandler{ReservationService: &MockReservationService{}}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "held", body: `{"order":"2377225624","sum":50,"ttl":600}`, wantStatus: http.StatusCreated},
+		{name: "bad order", body: `{"order":"abc","sum":50}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "failed luhn check", body: `{"order":"2377225625","sum":50}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid ttl", body: `{"order":"2377225624","sum":50,"ttl":-1}`, wantStatus: http.StatusBadRequest},
+		{name: "already held", body: `{"order":"12345678903","sum":50}`, wantStatus: http.StatusConflict},
+		{name: "not enough points", body: `{"order":"2377225624","sum":500}`, wantStatus: http.StatusPaymentRequired},
+		{name: "malformed", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/user/balance/reservations", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.Reserve).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestReleaseReservation(t *testing.T) {
+	handler := UserHandler{
+		ReservationService: &MockReservationService{
+			ReleaseOwnFunc: func(userID int, orderNumber string) (interfaces.Reservation, error) {
+				switch orderNumber {
+				case "2377225624":
+					return interfaces.Reservation{OrderNumber: orderNumber, Status: repository.ReservationReleased}, nil
+				case "12345678903":
+					return interfaces.Reservation{}, fmt.Errorf("%w: reservation is CAPTURED", service.ErrReservationState)
+				}
+
+				return interfaces.Reservation{}, repository.ErrReservationNotFound
+			},
+		},
+	}
+
+	router := chi.NewRouter()
+	router.Delete("/api/user/balance/reservations/{order}", handler.ReleaseReservation)
+
+	tests := []struct {
+		name       string
+		order      string
+		wantStatus int
+	}{
+		{name: "released", order: "2377225624", wantStatus: http.StatusOK},
+		{name: "captured", order: "12345678903", wantStatus: http.StatusConflict},
+		{name: "unknown", order: "1", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", "/api/user/balance/reservations/"+tt.order, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
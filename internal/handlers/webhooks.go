@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/pagination"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// WebhookHandler serves the admin API under /api/admin/webhooks.
+type WebhookHandler struct {
+	WebhookService interfaces.WebhookServiceInterface
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// Create registers an endpoint. The signing secret is only returned here.
+func (wh *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request webhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := wh.WebhookService.CreateEndpoint(r.Context(), request.URL, request.Events)
+
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhook) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to create webhook", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(endpoint)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if _, err := w.Write(jsonData); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write response", "error", err)
+	}
+}
+
+func (wh *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := wh.WebhookService.ListEndpoints(r.Context())
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list webhooks", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if len(endpoints) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, r, endpoints)
+}
+
+// Delete deactivates an endpoint; its pending deliveries move to dead letters.
+func (wh *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Неверный идентификатор", http.StatusBadRequest)
+		return
+	}
+
+	if err := wh.WebhookService.DeleteEndpoint(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			http.Error(w, "Вебхук не найден", http.StatusNotFound)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to delete webhook", "webhook", id, "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeadLetters lists deliveries that ran out of attempts, newest first.
+func (wh *WebhookHandler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r.URL.Query(), repository.WebhookDeliverySortFields, "-"+repository.WebhookDeliverySortCreatedAt)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deliveries, next, err := wh.WebhookService.DeadLetters(r.Context(), page)
+
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to get dead letters", "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	pagination.SetNext(w, r, next)
+	writeJSON(w, r, deliveries)
+}
+
+// Retry puts a dead delivery back into the queue with a fresh attempt budget.
+func (wh *WebhookHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Неверный идентификатор", http.StatusBadRequest)
+		return
+	}
+
+	if err := wh.WebhookService.RetryDelivery(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			http.Error(w, "Доставка не найдена", http.StatusNotFound)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "Failed to retry webhook delivery", "delivery", id, "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
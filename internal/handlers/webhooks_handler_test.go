@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"gophermart/internal/pagination"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockWebhookService struct {
+	CreateEndpointFunc func(url string, eventTypes []string) (interfaces.WebhookEndpoint, error)
+	DeadLettersFunc    func(pagination.Page) ([]interfaces.WebhookDelivery, *pagination.Cursor, error)
+	RetryDeliveryFunc  func(id int64) error
+}
+
+func (ws *MockWebhookService) CreateEndpoint(ctx context.Context, url string, eventTypes []string) (interfaces.WebhookEndpoint, error) {
+	return ws.CreateEndpointFunc(url, eventTypes)
+}
+
+func (ws *MockWebhookService) ListEndpoints(ctx context.Context) ([]interfaces.WebhookEndpoint, error) {
+	return nil, nil
+}
+
+func (ws *MockWebhookService) DeleteEndpoint(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (ws *MockWebhookService) DeadLetters(ctx context.Context, page pagination.Page) ([]interfaces.WebhookDelivery, *pagination.Cursor, error) {
+	return ws.DeadLettersFunc(page)
+}
+
+func (ws *MockWebhookService) RetryDelivery(ctx context.Context, id int64) error {
+	return ws.RetryDeliveryFunc(id)
+}
+
+func TestWebhookCreate(t *testing.T) {
+	webhookService := &MockWebhookService{
+		CreateEndpointFunc: func(url string, eventTypes []string) (interfaces.WebhookEndpoint, error) {
+			if len(eventTypes) == 0 {
+				return interfaces.WebhookEndpoint{}, fmt.Errorf("%w: no events", service.ErrInvalidWebhook)
+			}
+
+			return interfaces.WebhookEndpoint{ID: 1, URL: url, EventTypes: eventTypes, Secret: "s3cr3t"}, nil
+		},
+	}
+	handler := WebhookHandler{WebhookService: webhookService}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "created", body: `{"url":"https://shop.example/hook","events":["order.processed"]}`, wantStatus: http.StatusCreated},
+		{name: "invalid", body: `{"url":"https://shop.example/hook","events":[]}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/admin/webhooks", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.Create).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var endpoint interfaces.WebhookEndpoint
+			if err := json.NewDecoder(rr.Body).Decode(&endpoint); err != nil {
+				t.Fatal(err)
+			}
+
+			if endpoint.Secret != "s3cr3t" {
+				t.Errorf("Expected the secret to be returned on creation, got %q", endpoint.Secret)
+			}
+		})
+	}
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	createdAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	webhookService := &MockWebhookService{
+		DeadLettersFunc: func(page pagination.Page) ([]interfaces.WebhookDelivery, *pagination.Cursor, error) {
+			if page.Cursor != nil {
+				return nil, nil, nil
+			}
+
+			deliveries := []interfaces.WebhookDelivery{
+				{ID: 7, EndpointID: 1, URL: "https://shop.example/hook", EventType: "order.processed", Attempts: 8, CreatedAt: createdAt, Secret: "s3cr3t"},
+			}
+
+			return deliveries, &pagination.Cursor{Sort: page.Sort, Value: createdAt.Format(time.RFC3339Nano), ID: 7}, nil
+		},
+	}
+	handler := WebhookHandler{WebhookService: webhookService}
+
+	req := httptest.NewRequest("GET", "/api/admin/webhooks/dead-letters?limit=1", nil)
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.DeadLetters).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v", rr.Code)
+	}
+
+	if strings.Contains(rr.Body.String(), "s3cr3t") {
+		t.Error("Expected the endpoint secret not to be exposed")
+	}
+
+	next := rr.Header().Get("X-Next-Cursor")
+
+	if next == "" {
+		t.Fatal("Expected a next cursor")
+	}
+
+	req = httptest.NewRequest("GET", "/api/admin/webhooks/dead-letters?cursor="+next, nil)
+	rr = httptest.NewRecorder()
+
+	http.HandlerFunc(handler.DeadLetters).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204 after the last page, got %v", rr.Code)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	webhookService := &MockWebhookService{
+		RetryDeliveryFunc: func(id int64) error {
+			if id != 7 {
+				return repository.ErrWebhookNotFound
+			}
+
+			return nil
+		},
+	}
+	handler := WebhookHandler{WebhookService: webhookService}
+
+	r := chi.NewRouter()
+	r.Post("/deliveries/{id}/retry", handler.Retry)
+
+	tests := []struct {
+		id         string
+		wantStatus int
+	}{
+		{id: "7", wantStatus: http.StatusNoContent},
+		{id: "8", wantStatus: http.StatusNotFound},
+		{id: "x", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/deliveries/"+tt.id+"/retry", nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("Retry(%s): expected status %v, got %v", tt.id, tt.wantStatus, rr.Code)
+		}
+	}
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	defer func(token string) { middleware.AdminToken = token }(middleware.AdminToken)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		configured string
+		header     string
+		wantStatus int
+	}{
+		{name: "disabled", configured: "", header: "Bearer ", wantStatus: http.StatusNotFound},
+		{name: "missing", configured: "admin-token-0123456789", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong", configured: "admin-token-0123456789", header: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "valid", configured: "admin-token-0123456789", header: "Bearer admin-token-0123456789", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware.AdminToken = tt.configured
+			req := httptest.NewRequest("GET", "/api/admin/webhooks", nil)
+			req.Header.Set("Authorization", tt.header)
+			rr := httptest.NewRecorder()
+
+			middleware.AdminAuthMiddleware(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
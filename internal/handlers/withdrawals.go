@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// WithdrawalHandler serves the withdrawal settlement API used by shops and
+// admins under /api/withdrawals.
+type WithdrawalHandler struct {
+	WithdrawalService interfaces.WithdrawalAdminInterface
+}
+
+type refundRequest struct {
+	// Amount is optional; without it everything not refunded yet is returned.
+	Amount decimal.Decimal `json:"amount"`
+	Reason string          `json:"reason"`
+}
+
+type withdrawalHistory struct {
+	interfaces.Withdrawal
+	History []interfaces.WithdrawalEvent `json:"history"`
+}
+
+func (wh *WithdrawalHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	id, ok := withdrawalID(w, r)
+
+	if !ok {
+		return
+	}
+
+	actor, _ := r.Context().Value(middleware.ActorKey).(string)
+
+	withdrawal, err := wh.WithdrawalService.ConfirmWithdrawal(r.Context(), id, actor)
+
+	if err != nil {
+		writeWithdrawalError(w, r, id, err)
+		return
+	}
+
+	writeJSON(w, r, withdrawal)
+}
+
+func (wh *WithdrawalHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	id, ok := withdrawalID(w, r)
+
+	if !ok {
+		return
+	}
+
+	actor, _ := r.Context().Value(middleware.ActorKey).(string)
+
+	var request refundRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+
+	withdrawal, err := wh.WithdrawalService.RefundWithdrawal(r.Context(), id, request.Amount, request.Reason, actor)
+
+	if err != nil {
+		writeWithdrawalError(w, r, id, err)
+		return
+	}
+
+	writeJSON(w, r, withdrawal)
+}
+
+func (wh *WithdrawalHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, ok := withdrawalID(w, r)
+
+	if !ok {
+		return
+	}
+
+	withdrawal, history, err := wh.WithdrawalService.WithdrawalHistory(r.Context(), id)
+
+	if err != nil {
+		writeWithdrawalError(w, r, id, err)
+		return
+	}
+
+	writeJSON(w, r, withdrawalHistory{Withdrawal: withdrawal, History: history})
+}
+
+// withdrawalID parses the id of the withdrawal, which its owner finds in
+// their withdrawal list.
+func withdrawalID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+
+	if err != nil || id <= 0 {
+		http.Error(w, "Списание не найдено", http.StatusNotFound)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func writeWithdrawalError(w http.ResponseWriter, r *http.Request, id int64, err error) {
+	switch {
+	case errors.Is(err, repository.ErrWithdrawalNotFound):
+		http.Error(w, "Списание не найдено", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidRefund):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrWithdrawalState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		slog.ErrorContext(r.Context(), "Failed to process withdrawal", "withdrawal", id, "error", err)
+		http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
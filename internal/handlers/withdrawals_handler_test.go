@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/middleware"
+	"gophermart/internal/repository"
+	"gophermart/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockWithdrawalAdminService struct {
+	refundedBy string
+}
+
+func (ws *MockWithdrawalAdminService) ConfirmWithdrawal(ctx context.Context, id int64, actor string) (interfaces.Withdrawal, error) {
+	if id != 7 {
+		return interfaces.Withdrawal{}, repository.ErrWithdrawalNotFound
+	}
+
+	return interfaces.Withdrawal{}, fmt.Errorf("%w: withdrawal is CONFIRMED", service.ErrWithdrawalState)
+}
+
+func (ws *MockWithdrawalAdminService) RefundWithdrawal(ctx context.Context, id int64, amount decimal.Decimal, reason string, actor string) (interfaces.Withdrawal, error) {
+	if id != 7 {
+		return interfaces.Withdrawal{}, repository.ErrWithdrawalNotFound
+	}
+
+	if amount.GreaterThan(decimal.NewFromInt(100)) {
+		return interfaces.Withdrawal{}, fmt.Errorf("%w: at most 100.00 points can be refunded", service.ErrInvalidRefund)
+	}
+
+	ws.refundedBy = actor
+	refunded, _ := amount.Float64()
+
+	return interfaces.Withdrawal{ID: id, OrderNumber: "12345678903", Sum: 100, Refunded: refunded, Status: repository.WithdrawalConfirmed}, nil
+}
+
+func (ws *MockWithdrawalAdminService) WithdrawalHistory(ctx context.Context, id int64) (interfaces.Withdrawal, []interfaces.WithdrawalEvent, error) {
+	return interfaces.Withdrawal{}, nil, repository.ErrWithdrawalNotFound
+}
+
+func TestWithdrawalRefund(t *testing.T) {
+	withdrawalService := &MockWithdrawalAdminService{}
+	handler := WithdrawalHandler{WithdrawalService: withdrawalService}
+
+	router := chi.NewRouter()
+	router.Post("/api/withdrawals/{id}/refund", handler.Refund)
+	router.Post("/api/withdrawals/{id}/confirm", handler.Confirm)
+	router.Get("/api/withdrawals/{id}", handler.History)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "partial refund", method: "POST", path: "/api/withdrawals/7/refund", body: `{"amount":40,"reason":"order cancelled"}`, wantStatus: http.StatusOK},
+		{name: "too much", method: "POST", path: "/api/withdrawals/7/refund", body: `{"amount":400}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown", method: "POST", path: "/api/withdrawals/1/refund", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "malformed id", method: "POST", path: "/api/withdrawals/abc/refund", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "malformed", method: "POST", path: "/api/withdrawals/7/refund", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "already confirmed", method: "POST", path: "/api/withdrawals/7/confirm", wantStatus: http.StatusConflict},
+		{name: "history unknown", method: "GET", path: "/api/withdrawals/1", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middleware.ActorKey, "service:shop"))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+
+	if withdrawalService.refundedBy != "service:shop" {
+		t.Errorf("Expected refund to be attributed to the service, got %q", withdrawalService.refundedBy)
+	}
+}
@@ -0,0 +1,40 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+type CampaignServiceInterface interface {
+	CreateCampaign(ctx context.Context, campaign Campaign) (Campaign, error)
+	ListCampaigns(ctx context.Context) ([]Campaign, error)
+	GetCampaign(ctx context.Context, id int64) (Campaign, error)
+	UpdateCampaign(ctx context.Context, campaign Campaign) (Campaign, error)
+	DeleteCampaign(ctx context.Context, id int64) error
+}
+
+// Campaign grants bonus points for orders processed between StartsAt and
+// EndsAt that meet all of its conditions.
+type Campaign struct {
+	ID         int64              `json:"id"`
+	Name       string             `json:"name"`
+	StartsAt   time.Time          `json:"starts_at"`
+	EndsAt     time.Time          `json:"ends_at"`
+	Conditions CampaignConditions `json:"conditions"`
+	Effects    CampaignEffects    `json:"effects"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
+
+type CampaignConditions struct {
+	FirstOrder bool     `json:"first_order"`
+	MinAccrual float64  `json:"min_accrual"`
+	Tiers      []string `json:"tiers,omitempty"`
+}
+
+// CampaignEffects add up: a multiplier of 2 with a flat bonus of 100 grants
+// the accrual once more plus 100 points.
+type CampaignEffects struct {
+	Multiplier float64 `json:"multiplier,omitempty"`
+	FlatBonus  float64 `json:"flat_bonus,omitempty"`
+}
@@ -1,16 +1,20 @@
 package interfaces
 
 import (
+	"context"
 	"github.com/shopspring/decimal"
+	"gophermart/internal/pagination"
 	"time"
 )
 
 type OrderRepositoryInterface interface {
-	GetOrderID(orderNumber string, userID int) (int, error)
-	SaveOrder(orderNumber string, userID int) error
-	UpdateOrder(orderNumber string, accrual decimal.Decimal, status string) error
-	GetUserOrders(userID int) ([]OrderData, error)
+	GetOrderID(ctx context.Context, orderNumber string, userID int) (int, error)
+	SaveOrder(ctx context.Context, orderNumber string, userID int) error
+	UpdateOrder(ctx context.Context, orderNumber string, accrual decimal.Decimal, status string) error
+	GetUserOrders(ctx context.Context, userID int) ([]OrderData, error)
+	GetUserOrdersPage(ctx context.Context, userID int, filter OrderFilter, page pagination.Page) ([]OrderData, *pagination.Cursor, error)
 	GetDBStorage() DBStorageInterface
+	CountOrdersByStatus(ctx context.Context) (map[string]int, error)
 }
 
 type OrderData struct {
@@ -19,3 +23,49 @@ type OrderData struct {
 	Accrual    float32   `json:"accrual"`
 	UploadedAt time.Time `json:"uploaded_at"`
 }
+
+type OrderStatusChange struct {
+	Status    string    `json:"status"`
+	Accrual   float32   `json:"accrual,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// OrderDetail is a single order with its status timeline. NextPollAt is set
+// while the order is still polled from the accrual system.
+type OrderDetail struct {
+	Number       string              `json:"number"`
+	Status       string              `json:"status"`
+	Accrual      float32             `json:"accrual,omitempty"`
+	UploadedAt   time.Time           `json:"uploaded_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	LastPolledAt *time.Time          `json:"last_polled_at,omitempty"`
+	NextPollAt   *time.Time          `json:"next_poll_at,omitempty"`
+	History      []OrderStatusChange `json:"history"`
+	Revisions    []AccrualRevision   `json:"revisions,omitempty"`
+}
+
+// AccrualRevision is a change of the accrual of an order after it was
+// credited. Delta is what the balance was adjusted by and Debt the part of a
+// reduction recorded as debt instead of being debited.
+type AccrualRevision struct {
+	UserID          int       `json:"-"`
+	OrderNumber     string    `json:"-"`
+	PreviousStatus  string    `json:"previous_status"`
+	Status          string    `json:"status"`
+	PreviousAccrual float64   `json:"previous_accrual"`
+	Accrual         float64   `json:"accrual"`
+	Delta           float64   `json:"delta"`
+	BonusDelta      float64   `json:"bonus_delta,omitempty"`
+	Debt            float64   `json:"debt,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// OrderFilter narrows GetUserOrdersPage. Empty fields do not filter.
+type OrderFilter struct {
+	Statuses []string
+}
+
+type PendingOrder struct {
+	Number string
+	UserID int
+}
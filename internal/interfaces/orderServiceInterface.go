@@ -1,11 +1,21 @@
 package interfaces
 
-import "github.com/shopspring/decimal"
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/pagination"
+)
 
 type OrderServiceInterface interface {
-	GetOrderID(orderNumber string, userID int) (int, error)
-	SaveOrder(orderNumber string, userID int) error
-	UpdateOrder(orderNumber string, accrual decimal.Decimal, status string) error
-	GetUserOrders(userID int) ([]OrderData, error)
+	GetOrderID(ctx context.Context, orderNumber string, userID int) (int, error)
+	SaveOrder(ctx context.Context, orderNumber string, userID int) error
+	SaveOrders(ctx context.Context, orderNumbers []string, userID int) ([]int, error)
+	UpdateOrder(ctx context.Context, orderNumber string, accrual decimal.Decimal, status string) error
+	GetUserOrders(ctx context.Context, userID int) ([]OrderData, error)
+	GetUserOrdersPage(ctx context.Context, userID int, filter OrderFilter, page pagination.Page) ([]OrderData, *pagination.Cursor, error)
+	GetUserOrder(ctx context.Context, userID int, orderNumber string) (OrderDetail, error)
+	GetPendingOrders(ctx context.Context, limit int) ([]PendingOrder, error)
+	ApplyAccrual(ctx context.Context, orderNumber string, status string, accrual decimal.Decimal) (decimal.Decimal, error)
+	MarkPolled(ctx context.Context, orderNumber string) error
 	GetOrderRepository() OrderRepositoryInterface
 }
@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is a domain event as stored in the outbox and handed to sinks.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	UserID    int             `json:"user_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,39 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+// PrivacyServiceInterface answers data subject requests.
+type PrivacyServiceInterface interface {
+	Export(ctx context.Context, userID int) (DataExport, error)
+	// DeleteAccount anonymizes the user, revokes their tokens and releases
+	// their reservations. Orders, withdrawals and the ledger are kept under
+	// the user's ID; PENDING withdrawals are still settled by the shop.
+	DeleteAccount(ctx context.Context, userID int) error
+}
+
+// DataExport is everything stored about a user.
+type DataExport struct {
+	Profile     UserProfile    `json:"profile"`
+	Orders      []OrderData    `json:"orders"`
+	Withdrawals []WithdrawInfo `json:"withdrawals"`
+	Ledger      []LedgerRecord `json:"ledger"`
+}
+
+type UserProfile struct {
+	ID           int         `json:"id"`
+	Login        string      `json:"login"`
+	ReferralCode string      `json:"referral_code,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	Balance      UserBalance `json:"balance"`
+}
+
+// LedgerRecord is a ledger entry as exported; Amount is signed.
+type LedgerRecord struct {
+	Kind        string    `json:"kind"`
+	Amount      float64   `json:"amount"`
+	OrderNumber string    `json:"order,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
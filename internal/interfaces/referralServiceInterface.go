@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+type ReferralServiceInterface interface {
+	GetReferrals(ctx context.Context, userID int) (Referrals, error)
+}
+
+// Referrals is the referral code of a user, the users who registered with it
+// and the bonuses earned so far.
+type Referrals struct {
+	Code     string    `json:"code"`
+	Earned   float64   `json:"earned"`
+	Invitees []Invitee `json:"invitees"`
+}
+
+type Invitee struct {
+	Login        string     `json:"login"`
+	Status       string     `json:"status"`
+	Bonus        float64    `json:"bonus"`
+	RegisteredAt time.Time  `json:"registered_at"`
+	RewardedAt   *time.Time `json:"rewarded_at,omitempty"`
+}
+
+// Referral is a pending invitation, locked while its bonuses are decided.
+type Referral struct {
+	ID         int64
+	ReferrerID int
+	RefereeID  int
+}
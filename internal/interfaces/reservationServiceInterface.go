@@ -0,0 +1,42 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// ReservationServiceInterface is the user side of checkout holds.
+type ReservationServiceInterface interface {
+	Reserve(ctx context.Context, userID int, request ReservationRequest) (Reservation, error)
+	ReleaseOwn(ctx context.Context, userID int, orderNumber string) (Reservation, error)
+	Reservations(ctx context.Context, userID int) ([]Reservation, error)
+}
+
+// ReservationAdminInterface is the API the shop settles holds with once the
+// payment went through or was abandoned. Holds are addressed by id, since
+// order numbers are only unique per user.
+type ReservationAdminInterface interface {
+	// Capture turns the hold into a confirmed withdrawal of amount points, or
+	// of the whole hold when amount is zero; the rest is released.
+	Capture(ctx context.Context, id int64, amount decimal.Decimal, actor string) (Reservation, error)
+	Release(ctx context.Context, id int64, actor string) (Reservation, error)
+}
+
+type ReservationRequest struct {
+	Order string          `json:"order"`
+	Sum   decimal.Decimal `json:"sum"`
+	// TTL is in seconds; the configured default applies when it is zero.
+	TTL int `json:"ttl"`
+}
+
+type Reservation struct {
+	ID          int64     `json:"id"`
+	UserID      int       `json:"-"`
+	OrderNumber string    `json:"order"`
+	Sum         float64   `json:"sum"`
+	Captured    float64   `json:"captured,omitempty"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type StatementServiceInterface interface {
+	// Statement renders the statement of a user for [from, to) into w while it
+	// is read from the database.
+	Statement(ctx context.Context, userID int, from time.Time, to time.Time, w StatementWriter) error
+}
+
+// StatementWriter renders a statement: Begin gets the opening balance, Line
+// every entry in time order and End the totals of the period.
+type StatementWriter interface {
+	Begin(period StatementPeriod) error
+	Line(line StatementLine) error
+	End(totals StatementTotals) error
+}
+
+type StatementPeriod struct {
+	Username string
+	From     time.Time
+	To       time.Time
+	Opening  decimal.Decimal
+}
+
+// StatementLine is an uploaded order or a balance movement. Orders do not move
+// the balance and have no Amount; their Status is the current order status.
+type StatementLine struct {
+	Time        time.Time
+	Kind        string
+	OrderNumber string
+	Status      string
+	Amount      *decimal.Decimal
+	Balance     decimal.Decimal
+}
+
+type StatementTotals struct {
+	Credits decimal.Decimal
+	Debits  decimal.Decimal
+	Closing decimal.Decimal
+}
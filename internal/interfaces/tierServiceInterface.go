@@ -0,0 +1,40 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type TierServiceInterface interface {
+	GetTierStatus(ctx context.Context, userID int) (TierStatus, error)
+}
+
+// TierStatus is the tier a user is credited at and their statistics over the
+// rolling window, counted from Since.
+type TierStatus struct {
+	Tier       string        `json:"tier"`
+	Multiplier float64       `json:"multiplier"`
+	Points     float64       `json:"points"`
+	Orders     int           `json:"orders"`
+	Since      time.Time     `json:"since"`
+	UpdatedAt  *time.Time    `json:"updated_at,omitempty"`
+	Next       *TierProgress `json:"next,omitempty"`
+}
+
+type TierProgress struct {
+	Tier       string  `json:"tier"`
+	Multiplier float64 `json:"multiplier"`
+	MinPoints  float64 `json:"min_points"`
+	MinOrders  int     `json:"min_orders"`
+	PointsLeft float64 `json:"points_left"`
+	OrdersLeft int     `json:"orders_left"`
+	Progress   float64 `json:"progress"`
+}
+
+// TierStats are the rolling statistics of one user.
+type TierStats struct {
+	UserID int
+	Points decimal.Decimal
+	Orders int
+}
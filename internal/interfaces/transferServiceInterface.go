@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type TransferServiceInterface interface {
+	// Transfer reports true when the idempotency key was already used for the
+	// same transfer, which is then returned instead of being made again.
+	Transfer(ctx context.Context, senderID int, request TransferRequest) (Transfer, bool, error)
+	Transfers(ctx context.Context, userID int) ([]Transfer, error)
+}
+
+type TransferRequest struct {
+	To             string          `json:"to"`
+	Amount         decimal.Decimal `json:"amount"`
+	IdempotencyKey string          `json:"-"`
+}
+
+// Transfer is a transfer as seen by one of its sides.
+type Transfer struct {
+	ID int64 `json:"id"`
+	// Direction is "in" for received points and "out" for sent ones.
+	Direction    string    `json:"direction"`
+	Counterparty string    `json:"counterparty"`
+	Amount       float64   `json:"amount"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -1,17 +1,50 @@
 package interfaces
 
 import (
+	"context"
 	"github.com/shopspring/decimal"
 	"gophermart/internal/models"
+	"time"
 )
 
 type UserBalanceRepositoryInterface interface {
-	UpdateUserBalance(accrual decimal.Decimal, userID int) error
-	GetUserBalance(userID int) (UserBalance, error)
-	CreateUserBalance(user models.User) error
+	UpdateUserBalance(ctx context.Context, accrual decimal.Decimal, userID int) error
+	GetUserBalance(ctx context.Context, userID int) (UserBalance, error)
+	CreateUserBalance(ctx context.Context, user models.User) error
 }
 
 type UserBalance struct {
 	Current   float32 `json:"current"`
 	Withdrawn float32 `json:"withdrawn"`
+	// Reserved is the part of Current held for checkouts in progress.
+	Reserved float32 `json:"reserved,omitempty"`
+	// Debt is owed from reduced accruals and is repaid from future credits.
+	Debt         float32          `json:"debt,omitempty"`
+	ExpiringSoon []ExpiringPoints `json:"expiring_soon,omitempty"`
+}
+
+// ExpiringPoints is the part of the balance that expires at ExpiresAt unless
+// withdrawn first.
+type ExpiringPoints struct {
+	Amount    float32   `json:"amount"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PointLot is the unspent rest of one accrual.
+type PointLot struct {
+	ID          int64
+	UserID      int
+	OrderNumber string
+	Remaining   decimal.Decimal
+	ExpiresAt   time.Time
+}
+
+// LedgerEntry is one signed balance movement. CampaignID references the
+// campaign a bonus was granted by.
+type LedgerEntry struct {
+	Kind        string
+	Amount      decimal.Decimal
+	OrderNumber string
+	CampaignID  *int64
+	TransferID  *int64
 }
@@ -1,12 +1,13 @@
 package interfaces
 
 import (
+	"context"
 	"gophermart/internal/models"
 )
 
 type UserRepositoryInterface interface {
-	CreateUser(user models.User) (int, error)
-	GetUserID(username string) int
-	GetUserByUsername(username string) (models.User, error)
+	CreateUser(ctx context.Context, user models.User) (int, error)
+	GetUserID(ctx context.Context, username string) int
+	GetUserByUsername(ctx context.Context, username string) (models.User, error)
 	GetDBStorage() DBStorageInterface
 }
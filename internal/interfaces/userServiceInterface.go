@@ -1,12 +1,13 @@
 package interfaces
 
 import (
+	"context"
 	"gophermart/internal/models"
 )
 
 type UserServiceInterface interface {
-	GetUserID(username string) int
-	RegisterUser(user models.User) (models.User, error)
-	AuthenticateUser(username, password string) (models.User, error)
+	GetUserID(ctx context.Context, username string) int
+	RegisterUser(ctx context.Context, user models.User) (models.User, error)
+	AuthenticateUser(ctx context.Context, username, password string) (models.User, error)
 	GetUserRepository() UserRepositoryInterface
 }
@@ -0,0 +1,38 @@
+package interfaces
+
+import (
+	"context"
+	"gophermart/internal/pagination"
+	"time"
+)
+
+type WebhookServiceInterface interface {
+	CreateEndpoint(ctx context.Context, url string, eventTypes []string) (WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context) ([]WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id int64) error
+	DeadLetters(ctx context.Context, page pagination.Page) ([]WebhookDelivery, *pagination.Cursor, error)
+	RetryDelivery(ctx context.Context, id int64) error
+}
+
+// WebhookEndpoint carries the signing secret only in the response to its
+// creation.
+type WebhookEndpoint struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID            int64     `json:"id"`
+	EndpointID    int64     `json:"endpoint_id"`
+	URL           string    `json:"url"`
+	EventType     string    `json:"event_type"`
+	Payload       string    `json:"payload"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `json:"-"`
+	Secret        string    `json:"-"`
+}
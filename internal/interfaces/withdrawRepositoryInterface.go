@@ -1,17 +1,66 @@
 package interfaces
 
 import (
+	"context"
 	"github.com/shopspring/decimal"
+	"gophermart/internal/pagination"
 	"time"
 )
 
 type WithdrawRepositoryInterface interface {
-	Withdraw(userID int, orderNumber string, sum decimal.Decimal) (int, error)
-	Withdrawals(userID int) ([]WithdrawInfo, error)
+	Withdraw(ctx context.Context, userID int, orderNumber string, sum decimal.Decimal) (int, error)
+	Withdrawals(ctx context.Context, userID int) ([]WithdrawInfo, error)
+	WithdrawalsPage(ctx context.Context, userID int, page pagination.Page) (WithdrawalsPage, error)
 }
 
 type WithdrawInfo struct {
+	// ID addresses the withdrawal in the shop API.
+	ID          int64     `json:"id"`
 	OrderNumber string    `json:"order"`
 	Sum         float32   `json:"sum"`
+	Status      string    `json:"status"`
+	Refunded    float32   `json:"refunded,omitempty"`
 	ProcessedAt time.Time `json:"processed_at"`
 }
+
+// WithdrawalAdminInterface is the API shops and admins use to settle
+// withdrawals. Withdrawals are addressed by id, as order numbers are not
+// unique across users; the id is in the user's withdrawal list and in the
+// withdrawal webhooks.
+type WithdrawalAdminInterface interface {
+	ConfirmWithdrawal(ctx context.Context, id int64, actor string) (Withdrawal, error)
+	// RefundWithdrawal returns amount points, or everything not refunded yet
+	// when amount is zero.
+	RefundWithdrawal(ctx context.Context, id int64, amount decimal.Decimal, reason string, actor string) (Withdrawal, error)
+	WithdrawalHistory(ctx context.Context, id int64) (Withdrawal, []WithdrawalEvent, error)
+}
+
+type Withdrawal struct {
+	ID          int64     `json:"id"`
+	UserID      int       `json:"user_id"`
+	OrderNumber string    `json:"order"`
+	Sum         float64   `json:"sum"`
+	Refunded    float64   `json:"refunded"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	// LotsExpireAt is the earliest expiry of the lots the points came from,
+	// nil when they never expire or the withdrawal predates tracking it.
+	LotsExpireAt *time.Time `json:"-"`
+}
+
+type WithdrawalEvent struct {
+	Action    string    `json:"action"`
+	Amount    float64   `json:"amount,omitempty"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WithdrawalsPage is one page of withdrawals together with the totals of the
+// whole selected period.
+type WithdrawalsPage struct {
+	Withdrawals []WithdrawInfo
+	Next        *pagination.Cursor
+	TotalCount  int
+	TotalSum    decimal.Decimal
+}
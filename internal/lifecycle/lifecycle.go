@@ -0,0 +1,74 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type Step struct {
+	Name    string
+	Timeout time.Duration
+	Fn      func(ctx context.Context) error
+}
+
+// Manager runs shutdown steps in the order they were added. Every step gets
+// its own deadline and a failed or timed out step does not prevent the
+// following ones, so the database pool is closed even if draining failed.
+type Manager struct {
+	steps []Step
+}
+
+func (m *Manager) Add(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	m.steps = append(m.steps, Step{Name: name, Timeout: timeout, Fn: fn})
+}
+
+func (m *Manager) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	for _, step := range m.steps {
+		start := time.Now()
+
+		if err := runStep(ctx, step); err != nil {
+			slog.ErrorContext(ctx, "Shutdown step failed", "step", step.Name, "duration", time.Since(start), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.Name, err))
+
+			continue
+		}
+
+		slog.InfoContext(ctx, "Shutdown step finished", "step", step.Name, "duration", time.Since(start))
+	}
+
+	return errors.Join(errs...)
+}
+
+// runStep does not rely on fn honouring ctx: a step that blocks past its
+// deadline is abandoned and reported as timed out.
+func runStep(ctx context.Context, step Step) error {
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+
+	result := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		result <- step.Fn(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	var order []string
+	stepErr := errors.New("step failed")
+
+	m := &Manager{}
+	m.Add("first", time.Second, func(ctx context.Context) error {
+		order = append(order, "first")
+		return stepErr
+	})
+	m.Add("blocked", 20*time.Millisecond, func(ctx context.Context) error {
+		select {}
+	})
+	m.Add("last", time.Second, func(ctx context.Context) error {
+		order = append(order, "last")
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+
+	if !errors.Is(err, stepErr) {
+		t.Errorf("Expected error to wrap %v, got %v", stepErr, err)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected blocked step to time out, got %v", err)
+	}
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "last" {
+		t.Errorf("Expected every step to run in order, got %v", order)
+	}
+}
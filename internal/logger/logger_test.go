@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextHandler_AddsRequestAndUserID(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := InitWithWriter(&buf, "debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithUserID(WithRequestID(context.Background(), "req-1"), 42)
+	slog.InfoContext(ctx, "hello")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record["request_id"] != "req-1" {
+		t.Errorf("Expected request_id req-1, got %v", record["request_id"])
+	}
+
+	if record["user_id"] != float64(42) {
+		t.Errorf("Expected user_id 42, got %v", record["user_id"])
+	}
+}
+
+func TestSetLevel_Invalid(t *testing.T) {
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
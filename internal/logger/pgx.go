@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type PgxLogger struct{}
+
+func (l *PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	attrs := make([]slog.Attr, 0, len(data))
+
+	for key, value := range data {
+		if key == "args" {
+			continue
+		}
+
+		attrs = append(attrs, slog.Any(key, value))
+	}
+
+	slog.LogAttrs(ctx, pgxToSlogLevel(level), "pgx: "+msg, attrs...)
+}
+
+func pgxToSlogLevel(level pgx.LogLevel) slog.Level {
+	switch level {
+	case pgx.LogLevelError:
+		return slog.LevelError
+	case pgx.LogLevelWarn:
+		return slog.LevelWarn
+	case pgx.LogLevelInfo:
+		return slog.LevelDebug
+	}
+
+	return slog.LevelDebug - 4
+}
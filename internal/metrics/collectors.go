@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"gophermart/internal/interfaces"
+)
+
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	maxConns             *prometheus.Desc
+	totalConns           *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+	}
+
+	return &PoolCollector{
+		pool:                 pool,
+		acquireCount:         desc("acquire_count_total", "Cumulative count of successful acquires from the pool."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Total time spent waiting for a connection."),
+		acquiredConns:        desc("acquired_conns", "Number of currently acquired connections."),
+		canceledAcquireCount: desc("canceled_acquire_count_total", "Cumulative count of acquires canceled by a context."),
+		emptyAcquireCount:    desc("empty_acquire_count_total", "Cumulative count of acquires that waited for a connection."),
+		idleConns:            desc("idle_conns", "Number of currently idle connections."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		totalConns:           desc("total_conns", "Total number of connections in the pool."),
+	}
+}
+
+func (pc *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pc.acquireCount
+	ch <- pc.acquireDuration
+	ch <- pc.acquiredConns
+	ch <- pc.canceledAcquireCount
+	ch <- pc.emptyAcquireCount
+	ch <- pc.idleConns
+	ch <- pc.maxConns
+	ch <- pc.totalConns
+}
+
+func (pc *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := pc.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(pc.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(pc.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(pc.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(pc.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(pc.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+}
+
+const scrapeTimeout = 5 * time.Second
+
+type OrderQueueCollector struct {
+	orderRepository interfaces.OrderRepositoryInterface
+	depth           *prometheus.Desc
+}
+
+func NewOrderQueueCollector(orderRepository interfaces.OrderRepositoryInterface) *OrderQueueCollector {
+	return &OrderQueueCollector{
+		orderRepository: orderRepository,
+		depth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "orders", "by_status"),
+			"Number of orders in each accrual status.",
+			[]string{"status"}, nil),
+	}
+}
+
+func (oc *OrderQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- oc.depth
+}
+
+func (oc *OrderQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	counts, err := oc.orderRepository.CountOrdersByStatus(ctx)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count orders by status", "error", err)
+		ch <- prometheus.NewInvalidMetric(oc.depth, err)
+		return
+	}
+
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(oc.depth, prometheus.GaugeValue, float64(count), status)
+	}
+}
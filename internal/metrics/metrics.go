@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gophermart/internal/interfaces"
+)
+
+const namespace = "gophermart"
+
+const (
+	AccrualOutcomeOK               = "ok"
+	AccrualOutcomeNotRegistered    = "not_registered"
+	AccrualOutcomeRateLimited      = "rate_limited"
+	AccrualOutcomeUnexpectedStatus = "unexpected_status"
+	AccrualOutcomeError            = "error"
+	AccrualOutcomeCircuitOpen      = "circuit_open"
+)
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "code"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method and chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	AccrualRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "requests_total",
+		Help:      "Number of accrual system calls by outcome.",
+	}, []string{"outcome"})
+
+	AccrualRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "request_duration_seconds",
+		Help:      "Accrual system call latency by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	PointsAccruedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_accrued_total",
+		Help:      "Loyalty points credited to user balances.",
+	})
+
+	PointsWithdrawnTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_withdrawn_total",
+		Help:      "Loyalty points withdrawn by users, including captured reservations.",
+	})
+
+	PointsRefundedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_refunded_total",
+		Help:      "Withdrawn loyalty points refunded to users.",
+	})
+
+	RegistrationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Number of successful user registrations.",
+	})
+
+	FailedLoginsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_logins_total",
+		Help:      "Number of rejected login attempts.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		AccrualRequestsTotal,
+		AccrualRequestDuration,
+		PointsAccruedTotal,
+		PointsWithdrawnTotal,
+		PointsRefundedTotal,
+		RegistrationsTotal,
+		FailedLoginsTotal,
+	)
+}
+
+func RegisterStorageCollectors(pool *pgxpool.Pool, orderRepository interfaces.OrderRepositoryInterface) {
+	Registry.MustRegister(
+		NewPoolCollector(pool),
+		NewOrderQueueCollector(orderRepository),
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
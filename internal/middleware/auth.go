@@ -2,15 +2,36 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel/trace"
+	"gophermart/internal/logger"
+	"gophermart/internal/tracing"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 type contextKey string
 
-const SecretKey = "sectet_key"
 const UserIDKey contextKey = "ID"
 
+// Set from config at startup. SecretKey signs new tokens; PreviousSecretKeys
+// are still accepted so that keys can be rotated without logging users out.
+var (
+	SecretKey          string
+	PreviousSecretKeys []string
+	TokenCookieName    = "token"
+)
+
+// TokenRevoked reports whether a token of a user issued at issuedAt was
+// revoked, as it is when the account is deleted. Tokens are not checked while
+// it is nil. It runs on every authenticated request and is deliberately not
+// cached, so that a revocation applies at once on every replica; the lookup is
+// by primary key and costs one query per request.
+var TokenRevoked func(ctx context.Context, userID int, issuedAt time.Time) (bool, error)
+
 type Credentials struct {
 	Username string `json:"login"`
 	Password string `json:"password"`
@@ -23,7 +44,7 @@ type Claims struct {
 
 func TokenAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("token")
+		cookie, err := r.Cookie(TokenCookieName)
 		authHeader := r.Header.Get("Authorization")
 
 		if err != nil && authHeader == "" {
@@ -39,18 +60,68 @@ func TokenAuthMiddleware(next http.Handler) http.Handler {
 			tokenString = authHeader
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(SecretKey), nil
-		})
+		claims, err := ParseToken(tokenString)
 
-		if err != nil || !token.Valid {
+		if err != nil {
 			http.Error(w, "недействительный токен", http.StatusUnauthorized)
 			return
 		}
 
+		if TokenRevoked != nil {
+			revoked, err := TokenRevoked(r.Context(), claims.ID, time.Unix(claims.IssuedAt, 0))
+
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to check token revocation", "error", err)
+				http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+				return
+			}
+
+			if revoked {
+				http.Error(w, "недействительный токен", http.StatusUnauthorized)
+				return
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), UserIDKey, claims.ID)
+		ctx = logger.WithUserID(ctx, claims.ID)
+		trace.SpanFromContext(ctx).SetAttributes(tracing.UserIDKey.Int(claims.ID))
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	})
 }
+
+func ParseToken(tokenString string) (*Claims, error) {
+	var lastErr error
+
+	for _, key := range append([]string{SecretKey}, PreviousSecretKeys...) {
+		if key == "" {
+			continue
+		}
+
+		claims := &Claims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+
+			return []byte(key), nil
+		})
+
+		if err == nil && token.Valid {
+			return claims, nil
+		}
+
+		lastErr = err
+
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			return nil, err
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no signing key configured")
+	}
+
+	return nil, lastErr
+}
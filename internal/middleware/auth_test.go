@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"github.com/dgrijalva/jwt-go"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenAuthMiddleware_Revoked(t *testing.T) {
+	SecretKey = "secret"
+	revokedAt := time.Now().Add(-time.Hour)
+	TokenRevoked = func(ctx context.Context, userID int, issuedAt time.Time) (bool, error) {
+		return !issuedAt.After(revokedAt), nil
+	}
+
+	defer func() {
+		SecretKey = ""
+		TokenRevoked = nil
+	}()
+
+	handler := TokenAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		issuedAt   time.Time
+		wantStatus int
+	}{
+		{name: "issued before revocation", issuedAt: revokedAt.Add(-time.Hour), wantStatus: http.StatusUnauthorized},
+		{name: "issued after revocation", issuedAt: time.Now(), wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{"id": 1, "iat": tt.issuedAt.Unix(), "exp": time.Now().Add(time.Hour).Unix()}
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SecretKey))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", "/api/user/balance", nil)
+			req.Header.Set("Authorization", token)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
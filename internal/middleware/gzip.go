@@ -3,7 +3,7 @@ package middleware
 import (
 	"compress/gzip"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 )
@@ -27,6 +27,16 @@ func (rw *gzipResponseWriter) WriteHeader(statusCode int) {
 	rw.w.WriteHeader(statusCode)
 }
 
+// Flush lets streaming handlers such as the event stream work behind the
+// compressor.
+func (rw *gzipResponseWriter) Flush() {
+	_ = rw.gz.Flush()
+
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func RequestDecompressor(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rw := w
@@ -38,7 +48,7 @@ func RequestDecompressor(next http.Handler) http.Handler {
 				err := gz.Close()
 
 				if err != nil {
-					log.Printf("Error closing gzip.Writer: %s", err)
+					slog.ErrorContext(r.Context(), "Error closing gzip.Writer", "error", err)
 					http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
 
 					return
@@ -52,7 +62,7 @@ func RequestDecompressor(next http.Handler) http.Handler {
 		if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
 			reader, err := gzip.NewReader(r.Body)
 			if err != nil {
-				log.Printf("Error decompressing request body %s", err)
+				slog.WarnContext(r.Context(), "Error decompressing request body", "error", err)
 				http.Error(rw, "Error decompressing request body", http.StatusInternalServerError)
 
 				return
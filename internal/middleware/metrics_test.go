@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gophermart/internal/metrics"
+)
+
+func TestRequestMetrics_RoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(RequestMetrics)
+	r.Get("/api/user/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	before := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues("GET", "/api/user/orders/{number}", "204"))
+
+	req := httptest.NewRequest("GET", "/api/user/orders/12345678903", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	after := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues("GET", "/api/user/orders/{number}", "204"))
+
+	if after-before != 1 {
+		t.Errorf("Expected request to be counted under route pattern, got delta %v", after-before)
+	}
+}
+
+func TestRequestMetrics_Unmatched(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(RequestMetrics)
+	r.Get("/known", func(w http.ResponseWriter, r *http.Request) {})
+
+	before := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues("GET", unmatchedRoute, "404"))
+
+	req := httptest.NewRequest("GET", "/unknown", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	after := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues("GET", unmatchedRoute, "404"))
+
+	if after-before != 1 {
+		t.Errorf("Expected unmatched request to be counted, got delta %v", after-before)
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gophermart/internal/logger"
+)
+
+func TestRequestID_Propagates(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if seen != "abc" || rr.Header().Get(RequestIDHeader) != "abc" {
+		t.Errorf("Expected request id abc to be propagated, got %q / %q", seen, rr.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestRequestID_Generates(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.RequestID(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if seen == "" || rr.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("Expected generated request id in context and header, got %q / %q", seen, rr.Header().Get(RequestIDHeader))
+	}
+}
@@ -1,7 +1,8 @@
 package models
 
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"login"`
-	Password string `json:"password"` // Храним хэшированный пароль
+	ID           int    `json:"id"`
+	Username     string `json:"login"`
+	Password     string `json:"password"`                // Храним хэшированный пароль
+	ReferralCode string `json:"referral_code,omitempty"` // Код пригласившего пользователя, только при регистрации
 }
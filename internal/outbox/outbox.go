@@ -0,0 +1,248 @@
+package outbox
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"log/slog"
+	"time"
+)
+
+// Event types of the outbox. Events of the webhook types (webhooks.EventTypes)
+// are appended to the outbox too, with the webhook data as payload, and are
+// also delivered to the subscribed endpoints.
+const (
+	TypeOrderUpdated       = "order.updated"
+	TypeBalanceCredited    = "balance.credited"
+	TypePointsExpired      = "points.expired"
+	TypeTierChanged        = "tier.changed"
+	TypeTransferSent       = "transfer.sent"
+	TypeReservationUpdated = "reservation.updated"
+	TypeAccrualRevised     = "accrual.revised"
+)
+
+type OrderUpdated struct {
+	Number  string          `json:"number"`
+	Status  string          `json:"status"`
+	Accrual decimal.Decimal `json:"accrual"`
+}
+
+type BalanceCredited struct {
+	Kind     string          `json:"kind"`
+	Amount   decimal.Decimal `json:"amount"`
+	Order    string          `json:"order,omitempty"`
+	Campaign *int64          `json:"campaign_id,omitempty"`
+	Transfer *int64          `json:"transfer_id,omitempty"`
+}
+
+type PointsExpired struct {
+	Amount decimal.Decimal `json:"amount"`
+	Order  string          `json:"order,omitempty"`
+}
+
+type TierChanged struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+type TransferSent struct {
+	ID        int64           `json:"transfer_id"`
+	Recipient int             `json:"recipient_id"`
+	Amount    decimal.Decimal `json:"amount"`
+}
+
+// ReservationUpdated is emitted whenever a hold is placed, captured,
+// released or expires.
+type ReservationUpdated struct {
+	Order    string          `json:"order"`
+	Status   string          `json:"status"`
+	Sum      decimal.Decimal `json:"sum"`
+	Captured decimal.Decimal `json:"captured"`
+}
+
+// AccrualRevised is emitted when the accrual of a credited order changes.
+type AccrualRevised struct {
+	Order           string          `json:"order"`
+	Status          string          `json:"status"`
+	PreviousAccrual decimal.Decimal `json:"previous_accrual"`
+	Accrual         decimal.Decimal `json:"accrual"`
+	BonusDelta      decimal.Decimal `json:"bonus_delta"`
+	Debt            decimal.Decimal `json:"debt"`
+}
+
+// maxBackoff bounds how long the events of a user wait after failures.
+const maxBackoff = 5 * time.Minute
+
+type Store interface {
+	// WithRelayLock runs fn holding a lock shared by all replicas and reports
+	// false when another replica holds it. fn does not run in a transaction.
+	WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+	// Unpublished returns the oldest events not published yet, in id order,
+	// leaving out the events of the skipped users. It holds back the events of
+	// a user while a lower id of that user may still commit.
+	Unpublished(ctx context.Context, limit int, skip []int) ([]interfaces.OutboxEvent, error)
+	MarkPublished(ctx context.Context, ids []int64) error
+}
+
+// Sink is where the relay publishes events. Publish must be safe to repeat:
+// an event is published again if the relay fails before recording it.
+type Sink interface {
+	Publish(ctx context.Context, event interfaces.OutboxEvent) error
+}
+
+// Relay publishes the outbox to a sink. Delivery is at least once, and the
+// events of one user are published in order: after a failure the remaining
+// events of that user wait, backing off from the poll interval up to
+// maxBackoff, while other users proceed.
+type Relay struct {
+	store        Store
+	sink         Sink
+	pollInterval time.Duration
+	batchSize    int
+	now          func() time.Time
+
+	// blocked is only used by the goroutine running the relay.
+	blocked map[int]backoff
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type backoff struct {
+	failures int
+	until    time.Time
+}
+
+func NewRelay(store Store, sink Sink, pollInterval time.Duration, batchSize int) *Relay {
+	r := &Relay{
+		store:        store,
+		sink:         sink,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		now:          time.Now,
+		blocked:      map[int]backoff{},
+		done:         make(chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	return r
+}
+
+func (r *Relay) Run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if published, err := r.relay(); err != nil {
+			if r.ctx.Err() == nil {
+				slog.Error("Failed to relay outbox", "error", err)
+			}
+		} else if published == r.batchSize {
+			// A full batch means more events are probably waiting.
+			continue
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown lets the current batch finish, so that published events are
+// recorded and not sent again after a restart.
+func (r *Relay) Shutdown(ctx context.Context) error {
+	r.cancel()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Relay) relay() (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, nil
+	}
+
+	published := 0
+
+	// A started batch is finished even if shutdown begins meanwhile, so that
+	// what was published gets recorded; the shutdown deadline bounds it.
+	_, err := r.store.WithRelayLock(context.WithoutCancel(r.ctx), func(ctx context.Context) error {
+		events, err := r.store.Unpublished(ctx, r.batchSize, r.skipped())
+
+		if err != nil {
+			return err
+		}
+
+		var ids []int64
+		failed := map[int]bool{}
+
+		for _, event := range events {
+			if failed[event.UserID] {
+				continue
+			}
+
+			if err := r.sink.Publish(ctx, event); err != nil {
+				slog.Warn("Failed to publish outbox event", "event", event.ID, "type", event.Type, "user_id", event.UserID, "error", err)
+				failed[event.UserID] = true
+				r.block(event.UserID)
+
+				continue
+			}
+
+			delete(r.blocked, event.UserID)
+			ids = append(ids, event.ID)
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		published = len(ids)
+
+		return r.store.MarkPublished(ctx, ids)
+	})
+
+	return published, err
+}
+
+// skipped returns the users whose events wait out a backoff and forgets the
+// failures of users that have not failed for a while. The result is never nil,
+// so that the store can pass it on as an empty array.
+func (r *Relay) skipped() []int {
+	now := r.now()
+	users := []int{}
+
+	for userID, b := range r.blocked {
+		switch {
+		case now.Before(b.until):
+			users = append(users, userID)
+		case now.Sub(b.until) > maxBackoff:
+			delete(r.blocked, userID)
+		}
+	}
+
+	return users
+}
+
+func (r *Relay) block(userID int) {
+	b := r.blocked[userID]
+	b.failures++
+
+	delay := r.pollInterval
+
+	for i := 1; i < b.failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	b.until = r.now().Add(min(delay, maxBackoff))
+	r.blocked[userID] = b
+}
@@ -0,0 +1,226 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"gophermart/internal/interfaces"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	mu        sync.Mutex
+	events    []interfaces.OutboxEvent
+	published map[int64]bool
+	locked    bool
+}
+
+func (s *fakeStore) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	if s.locked {
+		return false, nil
+	}
+
+	return true, fn(ctx)
+}
+
+func (s *fakeStore) Unpublished(ctx context.Context, limit int, skip []int) ([]interfaces.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []interfaces.OutboxEvent
+
+	for _, event := range s.events {
+		if !s.published[event.ID] && !slices.Contains(skip, event.UserID) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (s *fakeStore) MarkPublished(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.published[id] = true
+	}
+
+	return nil
+}
+
+// flakySink fails the given event ids once and the events of failUsers always.
+type flakySink struct {
+	failOnce  map[int64]bool
+	failUsers map[int]bool
+	seen      []int64
+}
+
+func (fs *flakySink) Publish(ctx context.Context, event interfaces.OutboxEvent) error {
+	if fs.failOnce[event.ID] || fs.failUsers[event.UserID] {
+		delete(fs.failOnce, event.ID)
+		return errors.New("sink unavailable")
+	}
+
+	fs.seen = append(fs.seen, event.ID)
+
+	return nil
+}
+
+func TestRelay_KeepsPerUserOrder(t *testing.T) {
+	store := &fakeStore{
+		events: []interfaces.OutboxEvent{
+			{ID: 1, UserID: 1},
+			{ID: 2, UserID: 2},
+			{ID: 3, UserID: 1},
+			{ID: 4, UserID: 2},
+		},
+		published: map[int64]bool{},
+	}
+	sink := &flakySink{failOnce: map[int64]bool{1: true}}
+	now := time.Now()
+	r := NewRelay(store, sink, time.Minute, 10)
+	r.now = func() time.Time { return now }
+
+	if published, err := r.relay(); err != nil || published != 2 {
+		t.Fatalf("relay() = %d, %v, want 2 events of user 2", published, err)
+	}
+
+	if published, err := r.relay(); err != nil || published != 0 {
+		t.Fatalf("relay() = %d, %v, want user 1 to back off", published, err)
+	}
+
+	now = now.Add(time.Minute)
+
+	if published, err := r.relay(); err != nil || published != 2 {
+		t.Fatalf("relay() = %d, %v, want the 2 held back events of user 1", published, err)
+	}
+
+	if want := []int64{2, 4, 1, 3}; !reflect.DeepEqual(sink.seen, want) {
+		t.Errorf("Expected events in order %v, got %v", want, sink.seen)
+	}
+}
+
+func TestRelay_BlockedUserDoesNotStallOthers(t *testing.T) {
+	store := &fakeStore{published: map[int64]bool{}}
+
+	for id := int64(1); id <= 5; id++ {
+		store.events = append(store.events, interfaces.OutboxEvent{ID: id, UserID: 1})
+	}
+
+	store.events = append(store.events, interfaces.OutboxEvent{ID: 6, UserID: 2})
+	sink := &flakySink{failUsers: map[int]bool{1: true}}
+	now := time.Now()
+	r := NewRelay(store, sink, time.Minute, 3)
+	r.now = func() time.Time { return now }
+
+	if published, err := r.relay(); err != nil || published != 0 {
+		t.Fatalf("relay() = %d, %v, want only failures of user 1", published, err)
+	}
+
+	if published, err := r.relay(); err != nil || published != 1 {
+		t.Fatalf("relay() = %d, %v, want the event of user 2 past the blocked user", published, err)
+	}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		skipped bool
+	}{
+		{name: "first backoff", elapsed: time.Minute, skipped: false},
+		{name: "doubled backoff", elapsed: time.Minute, skipped: true},
+		{name: "doubled backoff over", elapsed: time.Minute, skipped: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.elapsed)
+
+			if got := slices.Contains(r.skipped(), 1); got != tt.skipped {
+				t.Fatalf("Expected user 1 skipped = %v, got %v", tt.skipped, got)
+			}
+
+			if _, err := r.relay(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRelay_SkipsWithoutLock(t *testing.T) {
+	store := &fakeStore{events: []interfaces.OutboxEvent{{ID: 1, UserID: 1}}, published: map[int64]bool{}, locked: true}
+	sink := &flakySink{}
+
+	if published, err := NewRelay(store, sink, time.Hour, 10).relay(); err != nil || published != 0 {
+		t.Fatalf("relay() = %d, %v, want nothing while another replica relays", published, err)
+	}
+}
+
+func TestBrokerSink(t *testing.T) {
+	broker := NewMemoryBroker()
+	sink := &BrokerSink{Producer: broker, Topic: "events"}
+
+	for _, event := range []interfaces.OutboxEvent{{ID: 1, UserID: 7, Type: TypeOrderUpdated}, {ID: 2, UserID: 7, Type: TypeBalanceCredited}} {
+		if err := sink.Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := broker.Messages("events")
+
+	if len(messages) != 2 || string(messages[0].Key) != "7" {
+		t.Fatalf("Expected 2 messages keyed by user, got %+v", messages)
+	}
+
+	var event interfaces.OutboxEvent
+
+	if err := json.Unmarshal(messages[1].Value, &event); err != nil || event.Type != TypeBalanceCredited {
+		t.Errorf("Expected the second message to be %s, got %+v (%v)", TypeBalanceCredited, event, err)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewFileSink(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := json.RawMessage(`{"order":"12345678903","sum":"100"}`)
+
+	for id := int64(1); id <= 2; id++ {
+		if err := sink.Publish(context.Background(), interfaces.OutboxEvent{ID: id, Type: TypeTransferSent, Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		lines++
+	}
+
+	if lines != 2 {
+		t.Errorf("Expected 2 lines, got %d", lines)
+	}
+}
@@ -0,0 +1,182 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gophermart/internal/interfaces"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	SinkLog    = "log"
+	SinkFile   = "file"
+	SinkHTTP   = "http"
+	SinkMemory = "memory"
+)
+
+// LogSink writes events to the application log.
+type LogSink struct{}
+
+func (LogSink) Publish(ctx context.Context, event interfaces.OutboxEvent) error {
+	slog.InfoContext(ctx, "Domain event",
+		"event", event.ID, "type", event.Type, "user_id", event.UserID, "payload", event.Payload)
+
+	return nil
+}
+
+// FileSink appends events to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{file: file}, nil
+}
+
+func (fs *FileSink) Publish(ctx context.Context, event interfaces.OutboxEvent) error {
+	line, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, err := fs.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return fs.file.Sync()
+}
+
+func (fs *FileSink) Close() error {
+	return fs.file.Close()
+}
+
+// HTTPSink posts each event as JSON. Receivers deduplicate by the
+// Idempotency-Key header, which is the event id.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (hs *HTTPSink) Publish(ctx context.Context, event interfaces.OutboxEvent) error {
+	body, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hs.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatInt(event.ID, 10))
+
+	resp, err := hs.Client.Do(req)
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sink responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Producer is the subset of a Kafka or NATS client the broker sink needs.
+// Messages with the same key must keep their order, as Kafka does within a
+// partition.
+type Producer interface {
+	Produce(ctx context.Context, topic string, key []byte, value []byte) error
+}
+
+// BrokerSink publishes events to a message broker, keyed by user so that the
+// events of one user stay ordered.
+type BrokerSink struct {
+	Producer Producer
+	Topic    string
+}
+
+func (bs *BrokerSink) Publish(ctx context.Context, event interfaces.OutboxEvent) error {
+	value, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	return bs.Producer.Produce(ctx, bs.Topic, []byte(strconv.Itoa(event.UserID)), value)
+}
+
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// MemoryBroker is an in-process Producer standing in for a real broker in
+// development and tests.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string][]Message
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: map[string][]Message{}}
+}
+
+func (mb *MemoryBroker) Produce(ctx context.Context, topic string, key []byte, value []byte) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.topics[topic] = append(mb.topics[topic], Message{Key: key, Value: value})
+
+	return nil
+}
+
+// Messages returns the messages produced to a topic, in order.
+func (mb *MemoryBroker) Messages(topic string) []Message {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return append([]Message(nil), mb.topics[topic]...)
+}
+
+// MultiSink publishes to every sink. A failure in one sink fails the event,
+// so sinks that succeeded see it again on the retry.
+type MultiSink []Sink
+
+func (ms MultiSink) Publish(ctx context.Context, event interfaces.OutboxEvent) error {
+	var errs []error
+
+	for _, sink := range ms {
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+
+	dateLayout = "2006-01-02"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last item of a page. Value is the sort key of that item
+// and ID breaks ties between items with equal sort keys.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// Page is a request for one page of a listing. From is inclusive and To is
+// exclusive; zero values mean the range is open on that side.
+type Page struct {
+	Limit  int
+	Sort   string
+	Cursor *Cursor
+	From   time.Time
+	To     time.Time
+}
+
+// Field returns the sort field without the direction prefix.
+func (p Page) Field() string {
+	return strings.TrimPrefix(p.Sort, "-")
+}
+
+func (p Page) Descending() bool {
+	return strings.HasPrefix(p.Sort, "-")
+}
+
+// Requested reports whether the query uses any pagination parameter. Listings
+// keep their unpaginated response when it does not.
+func Requested(q url.Values, extra ...string) bool {
+	for _, key := range append([]string{"limit", "cursor", "sort", "from", "to"}, extra...) {
+		if q.Has(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse reads limit, cursor, sort, from and to. Sort is one of sortFields,
+// optionally prefixed with "-" for descending order. A date without time in
+// "to" includes the whole day.
+func Parse(q url.Values, sortFields []string, defaultSort string) (Page, error) {
+	page := Page{Limit: DefaultLimit, Sort: defaultSort}
+
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+
+		page.Limit = limit
+	}
+
+	if raw := q.Get("sort"); raw != "" {
+		page.Sort = raw
+	}
+
+	if !contains(sortFields, page.Field()) {
+		return page, fmt.Errorf("sort must be one of %s, optionally prefixed with -", strings.Join(sortFields, ", "))
+	}
+
+	if raw := q.Get("cursor"); raw != "" {
+		cursor, err := DecodeCursor(raw)
+
+		if err != nil {
+			return page, err
+		}
+
+		if cursor.Sort != page.Sort {
+			return page, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, cursor.Sort)
+		}
+
+		page.Cursor = &cursor
+	}
+
+	var err error
+
+	page.From, page.To, err = ParseRange(q)
+
+	return page, err
+}
+
+// ParseRange reads from and to like Parse does; either may be missing.
+func ParseRange(q url.Values) (time.Time, time.Time, error) {
+	from, err := parseTime(q.Get("from"), false)
+
+	if err != nil {
+		return from, time.Time{}, fmt.Errorf("from: %w", err)
+	}
+
+	to, err := parseTime(q.Get("to"), true)
+
+	if err != nil {
+		return from, to, fmt.Errorf("to: %w", err)
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+func parseTime(raw string, endOfDay bool) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(dateLayout, raw); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected %s or RFC 3339 time", dateLayout)
+	}
+
+	return t, nil
+}
+
+// SetNext advertises the next page in the Link and X-Next-Cursor headers,
+// keeping every other query parameter of the current request.
+func SetNext(w http.ResponseWriter, r *http.Request, next *Cursor) {
+	if next == nil {
+		return
+	}
+
+	cursor := next.Encode()
+	q := r.URL.Query()
+	q.Set("cursor", cursor)
+
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", u.String()))
+	w.Header().Set("X-Next-Cursor", cursor)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cursor := Cursor{Sort: "-uploaded_at", Value: "2024-09-01T00:00:00Z", ID: 3}
+	q := url.Values{
+		"limit":  {"10"},
+		"cursor": {cursor.Encode()},
+		"from":   {"2024-09-01"},
+		"to":     {"2024-09-30"},
+	}
+
+	page, err := Parse(q, []string{"uploaded_at"}, "-uploaded_at")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Limit != 10 || page.Field() != "uploaded_at" || !page.Descending() {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	if page.Cursor == nil || *page.Cursor != cursor {
+		t.Errorf("Expected cursor %+v, got %+v", cursor, page.Cursor)
+	}
+
+	if want := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC); !page.To.Equal(want) {
+		t.Errorf("Expected date-only to to include the whole day, got %v", page.To)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"100000"}},
+		{"sort": {"number"}},
+		{"cursor": {"not a cursor"}},
+		{"cursor": {Cursor{Sort: "accrual"}.Encode()}},
+		{"from": {"yesterday"}},
+		{"from": {"2024-09-02"}, "to": {"2024-09-01"}},
+	}
+
+	for _, q := range tests {
+		if _, err := Parse(q, []string{"uploaded_at", "accrual"}, "-uploaded_at"); err == nil {
+			t.Errorf("Expected error for %v", q)
+		}
+	}
+
+	_, err := Parse(url.Values{"cursor": {"!"}}, []string{"uploaded_at"}, "uploaded_at")
+
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"gophermart/internal/interfaces"
+	"gophermart/storage"
+	"strings"
+	"time"
+)
+
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+const campaignColumns = "id, name, starts_at, ends_at, first_order, min_accrual, tiers, multiplier, flat_bonus, created_at, updated_at"
+
+type CampaignRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+func (cr *CampaignRepository) CreateCampaign(ctx context.Context, campaign interfaces.Campaign) (interfaces.Campaign, error) {
+	campaign.CreatedAt = time.Now()
+	campaign.UpdatedAt = campaign.CreatedAt
+
+	query := `INSERT INTO campaigns (name, starts_at, ends_at, first_order, min_accrual, tiers, multiplier, flat_bonus, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TRUE, $9, $9) RETURNING id`
+	err := cr.DBStorage.Querier(ctx).QueryRow(ctx, query, campaign.Name, campaign.StartsAt, campaign.EndsAt,
+		campaign.Conditions.FirstOrder, campaign.Conditions.MinAccrual, strings.Join(campaign.Conditions.Tiers, ","),
+		campaign.Effects.Multiplier, campaign.Effects.FlatBonus, campaign.CreatedAt).Scan(&campaign.ID)
+
+	return campaign, err
+}
+
+func (cr *CampaignRepository) UpdateCampaign(ctx context.Context, campaign interfaces.Campaign) (interfaces.Campaign, error) {
+	campaign.UpdatedAt = time.Now()
+
+	query := `UPDATE campaigns SET name = $1, starts_at = $2, ends_at = $3, first_order = $4, min_accrual = $5,
+		tiers = $6, multiplier = $7, flat_bonus = $8, updated_at = $9
+		WHERE id = $10 AND active RETURNING created_at`
+	err := cr.DBStorage.Querier(ctx).QueryRow(ctx, query, campaign.Name, campaign.StartsAt, campaign.EndsAt,
+		campaign.Conditions.FirstOrder, campaign.Conditions.MinAccrual, strings.Join(campaign.Conditions.Tiers, ","),
+		campaign.Effects.Multiplier, campaign.Effects.FlatBonus, campaign.UpdatedAt, campaign.ID).Scan(&campaign.CreatedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return campaign, ErrCampaignNotFound
+	}
+
+	return campaign, err
+}
+
+func (cr *CampaignRepository) GetCampaign(ctx context.Context, id int64) (interfaces.Campaign, error) {
+	campaigns, err := cr.selectCampaigns(ctx, "SELECT "+campaignColumns+" FROM campaigns WHERE id = $1 AND active", id)
+
+	if err != nil {
+		return interfaces.Campaign{}, err
+	}
+
+	if len(campaigns) == 0 {
+		return interfaces.Campaign{}, ErrCampaignNotFound
+	}
+
+	return campaigns[0], nil
+}
+
+func (cr *CampaignRepository) ListCampaigns(ctx context.Context) ([]interfaces.Campaign, error) {
+	return cr.selectCampaigns(ctx, "SELECT "+campaignColumns+" FROM campaigns WHERE active ORDER BY starts_at DESC, id DESC")
+}
+
+// ActiveAt returns the campaigns running at the given time.
+func (cr *CampaignRepository) ActiveAt(ctx context.Context, at time.Time) ([]interfaces.Campaign, error) {
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE active AND starts_at <= $1 AND ends_at > $1 ORDER BY id"
+
+	return cr.selectCampaigns(ctx, query, at)
+}
+
+// DeactivateCampaign stops a campaign but keeps it for the ledger lines that
+// reference it.
+func (cr *CampaignRepository) DeactivateCampaign(ctx context.Context, id int64) error {
+	query := "UPDATE campaigns SET active = FALSE, updated_at = $1 WHERE id = $2 AND active"
+	tag, err := cr.DBStorage.Querier(ctx).Exec(ctx, query, time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrCampaignNotFound
+	}
+
+	return nil
+}
+
+func (cr *CampaignRepository) selectCampaigns(ctx context.Context, query string, args ...interface{}) ([]interfaces.Campaign, error) {
+	rows, err := cr.DBStorage.Querier(ctx).Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campaigns []interfaces.Campaign
+
+	for rows.Next() {
+		var campaign interfaces.Campaign
+		var tiers string
+
+		err := rows.Scan(&campaign.ID, &campaign.Name, &campaign.StartsAt, &campaign.EndsAt,
+			&campaign.Conditions.FirstOrder, &campaign.Conditions.MinAccrual, &tiers,
+			&campaign.Effects.Multiplier, &campaign.Effects.FlatBonus, &campaign.CreatedAt, &campaign.UpdatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if tiers != "" {
+			campaign.Conditions.Tiers = strings.Split(tiers, ",")
+		}
+
+		campaigns = append(campaigns, campaign)
+	}
+
+	return campaigns, rows.Err()
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/storage"
+	"time"
+)
+
+// Ledger entry kinds.
+const (
+	LedgerAccrual       = "ACCRUAL"
+	LedgerWithdrawal    = "WITHDRAWAL"
+	LedgerExpiry        = "EXPIRY"
+	LedgerTierBonus     = "TIER_BONUS"
+	LedgerCampaignBonus = "CAMPAIGN_BONUS"
+	LedgerReferralBonus = "REFERRAL_BONUS"
+	LedgerTransferIn    = "TRANSFER_IN"
+	LedgerTransferOut   = "TRANSFER_OUT"
+	LedgerRefund        = "REFUND"
+	LedgerRevision      = "ACCRUAL_REVISION"
+	LedgerBonusRevision = "BONUS_REVISION"
+	LedgerDebtRepayment = "DEBT_REPAYMENT"
+)
+
+// LedgerRepository keeps the point lots and the balance ledger. Callers hold
+// the user's balance row lock, which serializes all changes to their lots.
+type LedgerRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+func (lr *LedgerRepository) Record(ctx context.Context, userID int, entry interfaces.LedgerEntry) error {
+	query := `INSERT INTO balance_ledger (user_id, kind, amount, order_number, campaign_id, transfer_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := lr.DBStorage.Querier(ctx).Exec(ctx, query, userID, entry.Kind, entry.Amount, entry.OrderNumber,
+		entry.CampaignID, entry.TransferID, time.Now())
+
+	return err
+}
+
+// OrderGrants returns what was credited for an order when it was processed:
+// the ACCRUAL line and the bonus lines, summed per kind and campaign.
+// Revisions are recorded under their own kinds and are not included.
+func (lr *LedgerRepository) OrderGrants(ctx context.Context, userID int, orderNumber string) ([]interfaces.LedgerEntry, error) {
+	query := `SELECT kind, campaign_id, SUM(amount) FROM balance_ledger
+		WHERE user_id = $1 AND order_number = $2 AND kind IN ($3, $4, $5, $6)
+		GROUP BY kind, campaign_id ORDER BY kind, campaign_id`
+	rows, err := lr.DBStorage.Querier(ctx).Query(ctx, query, userID, orderNumber,
+		LedgerAccrual, LedgerTierBonus, LedgerCampaignBonus, LedgerReferralBonus)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []interfaces.LedgerEntry
+
+	for rows.Next() {
+		grant := interfaces.LedgerEntry{OrderNumber: orderNumber}
+
+		if err := rows.Scan(&grant.Kind, &grant.CampaignID, &grant.Amount); err != nil {
+			return nil, err
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// AddLot records accrued points. A nil expiresAt means they never expire.
+func (lr *LedgerRepository) AddLot(ctx context.Context, userID int, orderNumber string, amount decimal.Decimal, expiresAt *time.Time) error {
+	query := `INSERT INTO point_lots (user_id, order_number, amount, remaining, created_at, expires_at)
+		VALUES ($1, $2, $3, $3, $4, $5)`
+	_, err := lr.DBStorage.Querier(ctx).Exec(ctx, query, userID, orderNumber, amount, time.Now(), expiresAt)
+
+	return err
+}
+
+// LiveTotal returns the unspent points of a user's lots that have not expired.
+func (lr *LedgerRepository) LiveTotal(ctx context.Context, userID int, now time.Time) (decimal.Decimal, error) {
+	var total decimal.Decimal
+
+	query := `SELECT COALESCE(SUM(remaining), 0) FROM point_lots
+		WHERE user_id = $1 AND remaining > 0 AND (expires_at IS NULL OR expires_at > $2)`
+	err := lr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, now).Scan(&total)
+
+	return total, err
+}
+
+// ConsumeLots takes amount from the live lots of a user, oldest first, and
+// returns the earliest expiry of the lots it took points from, nil when none
+// of them expire.
+func (lr *LedgerRepository) ConsumeLots(ctx context.Context, userID int, amount decimal.Decimal, now time.Time) (*time.Time, error) {
+	var expiresAt *time.Time
+
+	query := `WITH lots AS (
+			SELECT id, remaining, SUM(remaining) OVER (ORDER BY created_at, id) - remaining AS before
+			FROM point_lots
+			WHERE user_id = $1 AND remaining > 0 AND (expires_at IS NULL OR expires_at > $3)
+		), consumed AS (
+			UPDATE point_lots p SET remaining = p.remaining - LEAST(l.remaining, $2 - l.before)
+			FROM lots l
+			WHERE p.id = l.id AND l.before < $2
+			RETURNING p.expires_at
+		)
+		SELECT MIN(expires_at) FROM consumed`
+	err := lr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, amount, now).Scan(&expiresAt)
+
+	return expiresAt, err
+}
+
+// DueLots returns lots past their expiry that still hold points, of one user
+// or, with userID 0, of everyone.
+func (lr *LedgerRepository) DueLots(ctx context.Context, userID int, now time.Time, limit int) ([]interfaces.PointLot, error) {
+	query := `SELECT id, user_id, order_number, remaining, expires_at FROM point_lots
+		WHERE remaining > 0 AND expires_at <= $1 AND ($2 = 0 OR user_id = $2)
+		ORDER BY expires_at, id LIMIT $3`
+	rows, err := lr.DBStorage.Querier(ctx).Query(ctx, query, now, userID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []interfaces.PointLot
+
+	for rows.Next() {
+		var lot interfaces.PointLot
+
+		if err := rows.Scan(&lot.ID, &lot.UserID, &lot.OrderNumber, &lot.Remaining, &lot.ExpiresAt); err != nil {
+			return nil, err
+		}
+
+		lots = append(lots, lot)
+	}
+
+	return lots, rows.Err()
+}
+
+// ExpireLot empties a lot and returns the points it still held, zero if
+// another transaction got there first.
+func (lr *LedgerRepository) ExpireLot(ctx context.Context, id int64) (decimal.Decimal, error) {
+	var expired decimal.Decimal
+
+	query := `UPDATE point_lots p SET remaining = 0
+		FROM (SELECT id, remaining FROM point_lots WHERE id = $1 FOR UPDATE) old
+		WHERE p.id = old.id AND old.remaining > 0
+		RETURNING old.remaining`
+	err := lr.DBStorage.Querier(ctx).QueryRow(ctx, query, id).Scan(&expired)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return decimal.Zero, nil
+	}
+
+	return expired, err
+}
+
+// ExpiringSoon returns the points of a user expiring before until, grouped by
+// expiry time.
+func (lr *LedgerRepository) ExpiringSoon(ctx context.Context, userID int, now time.Time, until time.Time) ([]interfaces.ExpiringPoints, error) {
+	query := `SELECT SUM(remaining), expires_at FROM point_lots
+		WHERE user_id = $1 AND remaining > 0 AND expires_at > $2 AND expires_at <= $3
+		GROUP BY expires_at ORDER BY expires_at`
+	rows, err := lr.DBStorage.Querier(ctx).Query(ctx, query, userID, now, until)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expiring []interfaces.ExpiringPoints
+
+	for rows.Next() {
+		var points interfaces.ExpiringPoints
+
+		if err := rows.Scan(&points.Amount, &points.ExpiresAt); err != nil {
+			return nil, err
+		}
+
+		expiring = append(expiring, points)
+	}
+
+	return expiring, rows.Err()
+}
+
+// StatementKindOrder is the kind of the statement lines of uploaded orders.
+const StatementKindOrder = "ORDER"
+
+// BalanceAt returns the balance of a user at t: the current balance less
+// every movement recorded since. Balances from before the ledger was kept are
+// thus still accounted for.
+func (lr *LedgerRepository) BalanceAt(ctx context.Context, userID int, t time.Time) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+
+	query := `SELECT current - COALESCE((SELECT SUM(amount) FROM balance_ledger WHERE user_id = $1 AND created_at >= $2), 0)
+		FROM user_balance WHERE user_id = $1`
+	err := lr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, t).Scan(&balance)
+
+	return balance, err
+}
+
+// StatementLines calls fn with the orders a user uploaded and the ledger
+// entries in [from, to), oldest first, as they are read. Balance is left to
+// the caller.
+func (lr *LedgerRepository) StatementLines(ctx context.Context, userID int, from time.Time, to time.Time, fn func(interfaces.StatementLine) error) error {
+	query := `SELECT created_at, kind, order_number, status, amount FROM (
+			SELECT created_at, 0 AS source, id, '` + StatementKindOrder + `' AS kind, number AS order_number, status, NULL::DECIMAL AS amount
+			FROM orders WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+			UNION ALL
+			SELECT created_at, 1, id, kind, order_number, '', amount
+			FROM balance_ledger WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		) lines
+		ORDER BY created_at, source, id`
+	rows, err := lr.DBStorage.Querier(ctx).Query(ctx, query, userID, from, to)
+
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line interfaces.StatementLine
+		var amount decimal.NullDecimal
+
+		if err := rows.Scan(&line.Time, &line.Kind, &line.OrderNumber, &line.Status, &amount); err != nil {
+			return err
+		}
+
+		if amount.Valid {
+			line.Amount = &amount.Decimal
+		}
+
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Entries returns the whole ledger of a user, oldest first.
+func (lr *LedgerRepository) Entries(ctx context.Context, userID int) ([]interfaces.LedgerRecord, error) {
+	query := "SELECT kind, amount, order_number, created_at FROM balance_ledger WHERE user_id = $1 ORDER BY created_at, id"
+	rows, err := lr.DBStorage.Querier(ctx).Query(ctx, query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []interfaces.LedgerRecord
+
+	for rows.Next() {
+		var record interfaces.LedgerRecord
+		var amount decimal.Decimal
+
+		if err := rows.Scan(&record.Kind, &amount, &record.OrderNumber, &record.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		record.Amount, _ = amount.Float64()
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/storage/storagetest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConsumeLots(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		want   []string
+	}{
+		{name: "part of the oldest lot", amount: 30, want: []string{"70", "50", "40"}},
+		{name: "oldest lot and part of the next live one", amount: 120, want: []string{"0", "50", "20"}},
+		{name: "more than the live lots hold", amount: 200, want: []string{"0", "50", "0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pgs := storagetest.Open(t)
+			ctx := context.Background()
+			repo := LedgerRepository{DBStorage: pgs}
+			userID := storagetest.CreateUser(t, pgs, "user")
+			now := time.Now()
+			expired := now.Add(-time.Hour)
+
+			lots := []struct {
+				amount    int64
+				expiresAt *time.Time
+			}{
+				{amount: 100},
+				{amount: 50, expiresAt: &expired},
+				{amount: 40},
+			}
+
+			for _, lot := range lots {
+				if err := repo.AddLot(ctx, userID, "", decimal.NewFromInt(lot.amount), lot.expiresAt); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := repo.ConsumeLots(ctx, userID, decimal.NewFromInt(tt.amount), now); err != nil {
+				t.Fatal(err)
+			}
+
+			rows, err := pgs.Conn.Query(ctx, "SELECT remaining FROM point_lots WHERE user_id = $1 ORDER BY id", userID)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var remaining []string
+
+			for rows.Next() {
+				var lot decimal.Decimal
+
+				if err := rows.Scan(&lot); err != nil {
+					t.Fatal(err)
+				}
+
+				remaining = append(remaining, lot.String())
+			}
+
+			if !reflect.DeepEqual(remaining, tt.want) {
+				t.Fatalf("Expected lots %v, got %v", tt.want, remaining)
+			}
+		})
+	}
+}
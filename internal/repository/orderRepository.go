@@ -1,36 +1,53 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"github.com/jackc/pgx/v4"
 	"github.com/shopspring/decimal"
 	"gophermart/internal/interfaces"
+	"gophermart/internal/pagination"
 	"gophermart/storage"
 	"time"
 )
 
 const (
 	NEW        = "NEW"
-	PROCESSING = "PROCESSED"
+	PROCESSING = "PROCESSING"
 	INVALID    = "INVALID"
 	PROCESSED  = "PROCESSED"
 )
 
+const (
+	OrderSortUploadedAt = "uploaded_at"
+	OrderSortAccrual    = "accrual"
+)
+
+// OrderSortFields lists the sort fields accepted by GetUserOrdersPage.
+var OrderSortFields = []string{OrderSortUploadedAt, OrderSortAccrual}
+
+var orderSortColumns = map[string]sortColumn{
+	OrderSortUploadedAt: {expr: "created_at", parse: timeCursorValue},
+	OrderSortAccrual:    {expr: "COALESCE(accrual, 0)", parse: decimalCursorValue},
+}
+
 type OrderRepository struct {
 	DBStorage *storage.PgStorage
 }
 
 var (
 	ErrNoOrdersFound = errors.New("no orders found for the given user ID")
+	ErrOrderNotFound = errors.New("order not found")
 )
 
 func (or *OrderRepository) GetDBStorage() interfaces.DBStorageInterface {
 	return &storage.PgStorage{}
 }
 
-func (or *OrderRepository) GetOrderID(orderNumber string, userID int) (int, error) {
+func (or *OrderRepository) GetOrderID(ctx context.Context, orderNumber string, userID int) (int, error) {
 	var id, orderUserID int
 	query := "SELECT id, user_id FROM orders WHERE number = $1"
-	err := or.DBStorage.Conn.QueryRow(or.DBStorage.Ctx, query, orderNumber).Scan(&id, &orderUserID)
+	err := or.DBStorage.Querier(ctx).QueryRow(ctx, query, orderNumber).Scan(&id, &orderUserID)
 
 	if err != nil && err.Error() != "no rows in result set" {
 		return 0, err
@@ -47,29 +64,115 @@ func (or *OrderRepository) GetOrderID(orderNumber string, userID int) (int, erro
 	}
 }
 
-func (or *OrderRepository) SaveOrder(orderNumber string, userID int) error {
+func (or *OrderRepository) SaveOrder(ctx context.Context, orderNumber string, userID int) error {
 	currentTime := time.Now()
 
-	query := "INSERT INTO orders (number, user_id, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)"
-	_, err := or.DBStorage.Conn.Exec(or.DBStorage.Ctx, query, orderNumber, userID, NEW, currentTime, currentTime)
+	return or.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		query := "INSERT INTO orders (number, user_id, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)"
+
+		if _, err := or.DBStorage.Querier(ctx).Exec(ctx, query, orderNumber, userID, NEW, currentTime, currentTime); err != nil {
+			return err
+		}
+
+		return or.saveStatusHistory(ctx, orderNumber, NEW, decimal.Zero, currentTime)
+	})
+}
+
+// LockOrderNumber serialises uploads of the same number until the end of the
+// current transaction. orders.number has no unique constraint, so this is what
+// keeps two concurrent uploads from both inserting it.
+func (or *OrderRepository) LockOrderNumber(ctx context.Context, orderNumber string) error {
+	_, err := or.DBStorage.Querier(ctx).Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", orderNumber)
 
 	return err
 }
 
-func (or *OrderRepository) UpdateOrder(orderNumber string, accrual decimal.Decimal, status string) error {
+// UpdateOrder also records the new status in order_status_history, in the
+// same transaction.
+func (or *OrderRepository) UpdateOrder(ctx context.Context, orderNumber string, accrual decimal.Decimal, status string) error {
 	currentTime := time.Now()
 
-	query := "UPDATE orders SET status = $1, accrual = $2, updated_at = $3 WHERE number = $4"
-	_, err := or.DBStorage.Conn.Exec(or.DBStorage.Ctx, query, status, accrual, currentTime, orderNumber)
+	return or.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		query := "UPDATE orders SET status = $1, accrual = $2, updated_at = $3 WHERE number = $4"
+
+		if _, err := or.DBStorage.Querier(ctx).Exec(ctx, query, status, accrual, currentTime, orderNumber); err != nil {
+			return err
+		}
+
+		return or.saveStatusHistory(ctx, orderNumber, status, accrual, currentTime)
+	})
+}
+
+func (or *OrderRepository) saveStatusHistory(ctx context.Context, orderNumber string, status string, accrual decimal.Decimal, changedAt time.Time) error {
+	query := "INSERT INTO order_status_history (order_number, status, accrual, created_at) VALUES ($1, $2, $3, $4)"
+	_, err := or.DBStorage.Querier(ctx).Exec(ctx, query, orderNumber, status, accrual, changedAt)
+
+	return err
+}
+
+func (or *OrderRepository) MarkPolled(ctx context.Context, orderNumber string) error {
+	query := "UPDATE orders SET polled_at = $1 WHERE number = $2"
+	_, err := or.DBStorage.Querier(ctx).Exec(ctx, query, time.Now(), orderNumber)
 
 	return err
 }
 
-func (or *OrderRepository) GetUserOrders(userID int) ([]interfaces.OrderData, error) {
+// GetUserOrder returns ErrOrderNotFound both for unknown numbers and for
+// orders of other users.
+func (or *OrderRepository) GetUserOrder(ctx context.Context, userID int, orderNumber string) (interfaces.OrderDetail, error) {
+	var order interfaces.OrderDetail
+	var accrual decimal.Decimal
+
+	query := "SELECT number, status, COALESCE(accrual, 0), created_at, updated_at, polled_at FROM orders WHERE number = $1 AND user_id = $2"
+	err := or.DBStorage.Querier(ctx).QueryRow(ctx, query, orderNumber, userID).
+		Scan(&order.Number, &order.Status, &accrual, &order.UploadedAt, &order.UpdatedAt, &order.LastPolledAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return order, ErrOrderNotFound
+	}
+
+	if err != nil {
+		return order, err
+	}
+
+	value, _ := accrual.Float64()
+	order.Accrual = float32(value)
+
+	return order, nil
+}
+
+func (or *OrderRepository) GetOrderStatusHistory(ctx context.Context, orderNumber string) ([]interfaces.OrderStatusChange, error) {
+	var history []interfaces.OrderStatusChange
+
+	query := "SELECT status, COALESCE(accrual, 0), created_at FROM order_status_history WHERE order_number = $1 ORDER BY created_at, id"
+	rows, err := or.DBStorage.Querier(ctx).Query(ctx, query, orderNumber)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change interfaces.OrderStatusChange
+		var accrual decimal.Decimal
+
+		if err := rows.Scan(&change.Status, &accrual, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+
+		value, _ := accrual.Float64()
+		change.Accrual = float32(value)
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+func (or *OrderRepository) GetUserOrders(ctx context.Context, userID int) ([]interfaces.OrderData, error) {
 	var orders []interfaces.OrderData
 
 	query := "SELECT number, status, accrual, created_at FROM orders WHERE user_id = $1 ORDER BY updated_at DESC"
-	rows, err := or.DBStorage.Conn.Query(or.DBStorage.Ctx, query, userID)
+	rows, err := or.DBStorage.Querier(ctx).Query(ctx, query, userID)
 
 	if err != nil {
 		return nil, err
@@ -90,3 +193,196 @@ func (or *OrderRepository) GetUserOrders(userID int) ([]interfaces.OrderData, er
 
 	return orders, nil
 }
+
+func (or *OrderRepository) GetUserOrdersPage(ctx context.Context, userID int, filter interfaces.OrderFilter, page pagination.Page) ([]interfaces.OrderData, *pagination.Cursor, error) {
+	base := "SELECT id, number, status, COALESCE(accrual, 0), created_at FROM orders WHERE user_id = $1"
+	args := []interface{}{userID}
+
+	if len(filter.Statuses) > 0 {
+		base += " AND status = ANY($2)"
+		args = append(args, filter.Statuses)
+	}
+
+	query, args, err := pageQuery(base, args, page, orderSortColumns, "created_at", "id")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := or.DBStorage.Querier(ctx).Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var orders []interfaces.OrderData
+	var ids []int64
+	var accruals []decimal.Decimal
+
+	for rows.Next() {
+		var order interfaces.OrderData
+		var id int64
+		var accrual decimal.Decimal
+
+		if err := rows.Scan(&id, &order.Number, &order.Status, &accrual, &order.UploadedAt); err != nil {
+			return nil, nil, err
+		}
+
+		value, _ := accrual.Float64()
+		order.Accrual = float32(value)
+		orders = append(orders, order)
+		ids = append(ids, id)
+		accruals = append(accruals, accrual)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	count, next := nextCursor(page, len(orders), func(i int) (string, int64) {
+		if page.Field() == OrderSortAccrual {
+			return accruals[i].String(), ids[i]
+		}
+
+		return formatTimeCursor(orders[i].UploadedAt), ids[i]
+	})
+
+	return orders[:count], next, nil
+}
+
+func (or *OrderRepository) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	counts := map[string]int{NEW: 0, PROCESSING: 0, INVALID: 0, PROCESSED: 0}
+
+	query := "SELECT status, COUNT(*) FROM orders GROUP BY status"
+	rows, err := or.DBStorage.Querier(ctx).Query(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// LockOrder locks an order and returns its status, user and accrual.
+func (or *OrderRepository) LockOrder(ctx context.Context, orderNumber string) (string, int, decimal.Decimal, error) {
+	var status string
+	var userID int
+	var accrual decimal.Decimal
+
+	query := "SELECT status, user_id, COALESCE(accrual, 0) FROM orders WHERE number = $1 FOR UPDATE"
+	err := or.DBStorage.Querier(ctx).QueryRow(ctx, query, orderNumber).Scan(&status, &userID, &accrual)
+
+	return status, userID, accrual, err
+}
+
+// GetRevisableOrders returns orders in a final status changed after since
+// and not polled after polledBefore, so that revisions of their accrual are
+// noticed.
+func (or *OrderRepository) GetRevisableOrders(ctx context.Context, since time.Time, polledBefore time.Time, limit int) ([]interfaces.PendingOrder, error) {
+	query := `SELECT number, user_id FROM orders
+		WHERE status IN ($1, $2) AND updated_at > $3 AND COALESCE(polled_at, updated_at) < $4
+		ORDER BY COALESCE(polled_at, updated_at) LIMIT $5`
+
+	return or.queryPendingOrders(ctx, query, PROCESSED, INVALID, since, polledBefore, limit)
+}
+
+func (or *OrderRepository) SaveRevision(ctx context.Context, revision interfaces.AccrualRevision) error {
+	query := `INSERT INTO accrual_revisions
+		(user_id, order_number, previous_status, status, previous_accrual, accrual, delta, bonus_delta, debt, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := or.DBStorage.Querier(ctx).Exec(ctx, query, revision.UserID, revision.OrderNumber, revision.PreviousStatus,
+		revision.Status, revision.PreviousAccrual, revision.Accrual, revision.Delta, revision.BonusDelta, revision.Debt, time.Now())
+
+	return err
+}
+
+func (or *OrderRepository) GetRevisions(ctx context.Context, orderNumber string) ([]interfaces.AccrualRevision, error) {
+	query := `SELECT previous_status, status, previous_accrual, accrual, delta, bonus_delta, debt, created_at
+		FROM accrual_revisions WHERE order_number = $1 ORDER BY created_at, id`
+	rows, err := or.DBStorage.Querier(ctx).Query(ctx, query, orderNumber)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []interfaces.AccrualRevision
+
+	for rows.Next() {
+		var revision interfaces.AccrualRevision
+		var previousAccrual, accrual, delta, bonusDelta, debt decimal.Decimal
+
+		if err := rows.Scan(&revision.PreviousStatus, &revision.Status, &previousAccrual, &accrual, &delta, &bonusDelta,
+			&debt, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		revision.PreviousAccrual, _ = previousAccrual.Float64()
+		revision.Accrual, _ = accrual.Float64()
+		revision.Delta, _ = delta.Float64()
+		revision.BonusDelta, _ = bonusDelta.Float64()
+		revision.Debt, _ = debt.Float64()
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetPendingOrders returns the orders polled least recently first, so a batch
+// that stays pending does not starve the orders behind it.
+func (or *OrderRepository) GetPendingOrders(ctx context.Context, limit int) ([]interfaces.PendingOrder, error) {
+	query := `SELECT number, user_id FROM orders WHERE status IN ($1, $2)
+		ORDER BY COALESCE(polled_at, updated_at), id LIMIT $3`
+
+	return or.queryPendingOrders(ctx, query, NEW, PROCESSING, limit)
+}
+
+func (or *OrderRepository) queryPendingOrders(ctx context.Context, query string, args ...interface{}) ([]interfaces.PendingOrder, error) {
+	var orders []interfaces.PendingOrder
+
+	rows, err := or.DBStorage.Querier(ctx).Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order interfaces.PendingOrder
+		if err := rows.Scan(&order.Number, &order.UserID); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// IsFirstOrder reports whether an order is the first of the user to earn
+// points. The ledger keeps the accruals of orders later revised to INVALID,
+// so first-order bonuses are granted once per user. Processed orders are
+// checked as well for accruals credited before the ledger existed.
+func (or *OrderRepository) IsFirstOrder(ctx context.Context, userID int, orderNumber string) (bool, error) {
+	var first bool
+
+	query := `SELECT NOT EXISTS (SELECT 1 FROM balance_ledger WHERE user_id = $1 AND kind = $2 AND order_number <> $3)
+		AND NOT EXISTS (SELECT 1 FROM orders WHERE user_id = $1 AND status = $4 AND number <> $3)`
+	err := or.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, LedgerAccrual, orderNumber, PROCESSED).Scan(&first)
+
+	return first, err
+}
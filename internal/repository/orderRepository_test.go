@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gophermart/storage/storagetest"
+	"testing"
+)
+
+func TestGetPendingOrders_RotatesBatches(t *testing.T) {
+	pgs := storagetest.Open(t)
+	ctx := context.Background()
+	repo := OrderRepository{DBStorage: pgs}
+	userID := storagetest.CreateUser(t, pgs, "user")
+
+	const batch = 2
+	numbers := []string{"12345678903", "79927398713", "4561261212345467", "49927398716", "1234567812345670"}
+
+	for _, number := range numbers {
+		if err := repo.SaveOrder(ctx, number, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[string]bool{}
+
+	for round := 0; round < 3; round++ {
+		orders, err := repo.GetPendingOrders(ctx, batch)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, order := range orders {
+			if seen[order.Number] {
+				t.Fatalf("Round %d: order %s returned again before the others were polled", round, order.Number)
+			}
+
+			seen[order.Number] = true
+
+			if err := repo.MarkPolled(ctx, order.Number); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if len(seen) != len(numbers) {
+		t.Fatalf("Expected every pending order to be polled, got %s", fmt.Sprint(seen))
+	}
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gophermart/internal/interfaces"
+	"gophermart/storage"
+	"time"
+)
+
+// outboxRelayLock is the advisory lock key held by the replica relaying the
+// outbox. A single relay keeps the events of each user in order.
+const outboxRelayLock = 0x6f7574626f78
+
+const outboxPruneBatchSize = 1000
+
+type OutboxRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+// Append writes a domain event. Called inside the transaction of the change it
+// describes, so the event exists if and only if the change was committed.
+//
+// Ids are taken when inserting, not when committing, so a transaction may
+// commit an event before another one commits a lower id. The event therefore
+// records its horizon: the first transaction id not yet assigned once the
+// event has its id. The transaction gets its own id before the event id, so
+// every transaction holding a lower event id lies below the horizon.
+func (obr *OutboxRepository) Append(ctx context.Context, userID int, eventType string, payload []byte) error {
+	return obr.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		q := obr.DBStorage.Querier(ctx)
+
+		if _, err := q.Exec(ctx, "SELECT txid_current()"); err != nil {
+			return err
+		}
+
+		var id int64
+
+		if err := q.QueryRow(ctx, "SELECT nextval(pg_get_serial_sequence('outbox', 'id'))").Scan(&id); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO outbox (id, user_id, event_type, payload, created_at, tx_horizon)
+			VALUES ($1, $2, $3, $4, $5, txid_snapshot_xmax(txid_current_snapshot()))`
+		_, err := q.Exec(ctx, query, id, userID, eventType, string(payload), time.Now())
+
+		return err
+	})
+}
+
+// WithRelayLock runs fn holding the relay lock as a session lock on a
+// dedicated connection, so that no transaction stays open while fn publishes.
+// It reports false without running fn when another replica holds the lock.
+func (obr *OutboxRepository) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := obr.DBStorage.Conn.Acquire(ctx)
+
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	acquired := false
+
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", outboxRelayLock).Scan(&acquired); err != nil || !acquired {
+		return false, err
+	}
+
+	fnErr := fn(ctx)
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", outboxRelayLock); err != nil {
+		// Closing the connection ends the session and so releases the lock.
+		conn.Conn().Close(ctx)
+
+		return true, errors.Join(fnErr, err)
+	}
+
+	return true, fnErr
+}
+
+// Unpublished returns the oldest events not published yet, in id order,
+// leaving out the events of the skipped users. skip must not be nil.
+//
+// The events of a user stop at the first one whose horizon is not below the
+// oldest running transaction: a lower id of that user may still commit.
+func (obr *OutboxRepository) Unpublished(ctx context.Context, limit int, skip []int) ([]interfaces.OutboxEvent, error) {
+	query := `WITH running AS (SELECT txid_snapshot_xmin(txid_current_snapshot()) AS xmin)
+		SELECT o.id, o.event_type, o.user_id, o.payload, o.created_at FROM outbox o, running
+		WHERE o.published_at IS NULL AND NOT (o.user_id = ANY($2)) AND NOT EXISTS (
+			SELECT 1 FROM outbox p WHERE p.user_id = o.user_id AND p.published_at IS NULL
+				AND p.id <= o.id AND p.tx_horizon > running.xmin
+		) ORDER BY o.id LIMIT $1`
+	rows, err := obr.DBStorage.Querier(ctx).Query(ctx, query, limit, skip)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []interfaces.OutboxEvent
+
+	for rows.Next() {
+		var event interfaces.OutboxEvent
+		var payload string
+
+		if err := rows.Scan(&event.ID, &event.Type, &event.UserID, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (obr *OutboxRepository) MarkPublished(ctx context.Context, ids []int64) error {
+	query := "UPDATE outbox SET published_at = $1 WHERE id = ANY($2)"
+	_, err := obr.DBStorage.Querier(ctx).Exec(ctx, query, time.Now(), ids)
+
+	return err
+}
+
+// DeletePublished deletes the events published before the given time, in
+// batches so that no single statement holds many row locks, and returns how
+// many it deleted.
+func (obr *OutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM outbox WHERE id IN (
+			SELECT id FROM outbox WHERE published_at < $1 LIMIT $2
+		)`
+	var deleted int64
+
+	for {
+		tag, err := obr.DBStorage.Querier(ctx).Exec(ctx, query, before, outboxPruneBatchSize)
+
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += tag.RowsAffected()
+
+		if tag.RowsAffected() < outboxPruneBatchSize {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"gophermart/storage/storagetest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutboxRepository_UnpublishedSkipsUsers(t *testing.T) {
+	pgs := storagetest.Open(t)
+	ctx := context.Background()
+	repo := OutboxRepository{DBStorage: pgs}
+	blocked := storagetest.CreateUser(t, pgs, "blocked")
+	other := storagetest.CreateUser(t, pgs, "other")
+
+	for _, userID := range []int{blocked, blocked, other} {
+		if err := repo.Append(ctx, userID, "order.updated", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		skip  []int
+		users []int
+	}{
+		{name: "nobody skipped", skip: []int{}, users: []int{blocked, blocked}},
+		{name: "blocked user skipped", skip: []int{blocked}, users: []int{other}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := repo.Unpublished(ctx, 2, tt.skip)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var users []int
+
+			for _, event := range events {
+				users = append(users, event.UserID)
+			}
+
+			if len(users) != len(tt.users) || (len(users) > 0 && users[0] != tt.users[0]) {
+				t.Fatalf("Expected events of users %v, got %v", tt.users, users)
+			}
+		})
+	}
+}
+
+func TestOutboxRepository_DeletePublished(t *testing.T) {
+	pgs := storagetest.Open(t)
+	ctx := context.Background()
+	repo := OutboxRepository{DBStorage: pgs}
+	userID := storagetest.CreateUser(t, pgs, "user")
+
+	for i := 0; i < 2; i++ {
+		if err := repo.Append(ctx, userID, "order.updated", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := repo.Unpublished(ctx, 10, []int{})
+
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d (%v)", len(events), err)
+	}
+
+	if err := repo.MarkPublished(ctx, []int64{events[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := repo.DeletePublished(ctx, time.Now().Add(time.Minute))
+
+	if err != nil || deleted != 1 {
+		t.Fatalf("Expected the published event to be deleted, got %d (%v)", deleted, err)
+	}
+
+	kept, err := repo.Unpublished(ctx, 10, []int{})
+
+	if err != nil || len(kept) != 1 || kept[0].ID != events[1].ID {
+		t.Fatalf("Expected the unpublished event to be kept, got %v (%v)", kept, err)
+	}
+}
+
+func TestOutboxRepository_WithRelayLock(t *testing.T) {
+	pgs := storagetest.Open(t)
+	ctx := context.Background()
+	repo := OutboxRepository{DBStorage: pgs}
+
+	acquired, err := repo.WithRelayLock(ctx, func(ctx context.Context) error {
+		nested, err := repo.WithRelayLock(ctx, func(ctx context.Context) error {
+			t.Error("Expected the lock to be held")
+			return nil
+		})
+
+		if err != nil || nested {
+			t.Errorf("Expected a second relay to be refused, got %v (%v)", nested, err)
+		}
+
+		return nil
+	})
+
+	if err != nil || !acquired {
+		t.Fatalf("Expected the lock to be acquired, got %v (%v)", acquired, err)
+	}
+
+	if acquired, err := repo.WithRelayLock(ctx, func(ctx context.Context) error { return nil }); err != nil || !acquired {
+		t.Fatalf("Expected the lock to be released, got %v (%v)", acquired, err)
+	}
+}
+
+func TestOutboxRepository_UnpublishedWaitsForLowerIds(t *testing.T) {
+	pgs := storagetest.Open(t)
+	ctx := context.Background()
+	repo := OutboxRepository{DBStorage: pgs}
+	userID := storagetest.CreateUser(t, pgs, "user")
+
+	appended := make(chan error)
+	commit := make(chan struct{})
+	committed := make(chan error)
+	release := sync.OnceFunc(func() { close(commit) })
+	defer release()
+
+	// The first transaction takes the lower id and commits last.
+	go func() {
+		committed <- pgs.WithTx(ctx, func(ctx context.Context) error {
+			err := repo.Append(ctx, userID, "order.processed", []byte(`{}`))
+			appended <- err
+			<-commit
+
+			return err
+		})
+	}()
+
+	if err := <-appended; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pgs.WithTx(ctx, func(ctx context.Context) error {
+		return repo.Append(ctx, userID, "balance.credited", []byte(`{}`))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := repo.Unpublished(ctx, 10, []int{})
+
+	if err != nil || len(events) != 0 {
+		t.Fatalf("Expected no events while a lower id is uncommitted, got %d (%v)", len(events), err)
+	}
+
+	release()
+
+	if err := <-committed; err != nil {
+		t.Fatal(err)
+	}
+
+	events, err = repo.Unpublished(ctx, 10, []int{})
+
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d (%v)", len(events), err)
+	}
+
+	if events[0].Type != "order.processed" || events[1].Type != "balance.credited" {
+		t.Fatalf("Expected events in id order, got %s then %s", events[0].Type, events[1].Type)
+	}
+}
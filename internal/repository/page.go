@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/pagination"
+	"strings"
+	"time"
+)
+
+// sortColumn maps a public sort field to the SQL expression it orders by and
+// the way a cursor value is turned back into a query argument.
+type sortColumn struct {
+	expr  string
+	parse func(string) (interface{}, error)
+}
+
+func timeCursorValue(s string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func decimalCursorValue(s string) (interface{}, error) {
+	return decimal.NewFromString(s)
+}
+
+func formatTimeCursor(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// pageQuery builds a keyset-paginated query. base must end with a WHERE clause
+// that already uses args; the date range applies to dateColumn and idColumn
+// breaks ties between equal sort keys. One extra row is requested so that
+// callers can tell whether a next page exists.
+func pageQuery(base string, args []interface{}, page pagination.Page, columns map[string]sortColumn, dateColumn string, idColumn string) (string, []interface{}, error) {
+	column, ok := columns[page.Field()]
+
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported sort %q", page.Sort)
+	}
+
+	var query strings.Builder
+	query.WriteString(base)
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !page.From.IsZero() {
+		fmt.Fprintf(&query, " AND %s >= %s", dateColumn, arg(page.From))
+	}
+
+	if !page.To.IsZero() {
+		fmt.Fprintf(&query, " AND %s < %s", dateColumn, arg(page.To))
+	}
+
+	direction, comparison := "ASC", ">"
+
+	if page.Descending() {
+		direction, comparison = "DESC", "<"
+	}
+
+	if page.Cursor != nil {
+		value, err := column.parse(page.Cursor.Value)
+
+		if err != nil {
+			return "", nil, pagination.ErrInvalidCursor
+		}
+
+		fmt.Fprintf(&query, " AND (%s, %s) %s (%s, %s)", column.expr, idColumn, comparison, arg(value), arg(page.Cursor.ID))
+	}
+
+	fmt.Fprintf(&query, " ORDER BY %s %s, %s %s LIMIT %s", column.expr, direction, idColumn, direction, arg(page.Limit+1))
+
+	return query.String(), args, nil
+}
+
+// nextCursor trims the extra row requested by pageQuery and returns the
+// cursor of the last kept row, or nil on the last page.
+func nextCursor(page pagination.Page, count int, last func(i int) (string, int64)) (int, *pagination.Cursor) {
+	if count <= page.Limit {
+		return count, nil
+	}
+
+	value, id := last(page.Limit - 1)
+
+	return page.Limit, &pagination.Cursor{Sort: page.Sort, Value: value, ID: id}
+}
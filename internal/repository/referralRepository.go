@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/storage"
+	"time"
+)
+
+const (
+	ReferralPending  = "PENDING"
+	ReferralRewarded = "REWARDED"
+	ReferralRejected = "REJECTED"
+)
+
+var ErrReferralCodeNotFound = errors.New("referral code not found")
+
+type ReferralRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+// GetReferralCode returns the code of a user, empty if none was assigned yet.
+func (rr *ReferralRepository) GetReferralCode(ctx context.Context, userID int) (string, error) {
+	var code string
+
+	query := "SELECT COALESCE(referral_code, '') FROM users WHERE id = $1"
+	err := rr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID).Scan(&code)
+
+	return code, err
+}
+
+// AssignReferralCode sets the code of a user that has none. It returns false
+// when the user already has a code or the code is taken.
+func (rr *ReferralRepository) AssignReferralCode(ctx context.Context, userID int, code string) (bool, error) {
+	query := `UPDATE users SET referral_code = $1
+		WHERE id = $2 AND referral_code IS NULL AND NOT EXISTS (SELECT 1 FROM users WHERE referral_code = $1)`
+	tag, err := rr.DBStorage.Querier(ctx).Exec(ctx, query, code, userID)
+
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (rr *ReferralRepository) GetReferrerID(ctx context.Context, code string) (int, error) {
+	var userID int
+
+	query := "SELECT id FROM users WHERE referral_code = $1"
+	err := rr.DBStorage.Querier(ctx).QueryRow(ctx, query, code).Scan(&userID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrReferralCodeNotFound
+	}
+
+	return userID, err
+}
+
+func (rr *ReferralRepository) CreateReferral(ctx context.Context, referrerID int, refereeID int) error {
+	query := "INSERT INTO referrals (referrer_id, referee_id, status, created_at) VALUES ($1, $2, $3, $4)"
+	_, err := rr.DBStorage.Querier(ctx).Exec(ctx, query, referrerID, refereeID, ReferralPending, time.Now())
+
+	return err
+}
+
+// LockPendingReferral returns the pending invitation of a user, if any, and
+// locks it until the transaction ends.
+func (rr *ReferralRepository) LockPendingReferral(ctx context.Context, refereeID int) (interfaces.Referral, bool, error) {
+	referral := interfaces.Referral{RefereeID: refereeID}
+
+	query := "SELECT id, referrer_id FROM referrals WHERE referee_id = $1 AND status = $2 FOR UPDATE"
+	err := rr.DBStorage.Querier(ctx).QueryRow(ctx, query, refereeID, ReferralPending).Scan(&referral.ID, &referral.ReferrerID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return referral, false, nil
+	}
+
+	return referral, err == nil, err
+}
+
+// CountRewarded returns how many invitations of a user have paid out.
+func (rr *ReferralRepository) CountRewarded(ctx context.Context, referrerID int) (int, error) {
+	var count int
+
+	query := "SELECT COUNT(*) FROM referrals WHERE referrer_id = $1 AND status = $2"
+	err := rr.DBStorage.Querier(ctx).QueryRow(ctx, query, referrerID, ReferralRewarded).Scan(&count)
+
+	return count, err
+}
+
+// HasProcessedOrder reports whether a user has an order of their own that was
+// processed.
+func (rr *ReferralRepository) HasProcessedOrder(ctx context.Context, userID int) (bool, error) {
+	var exists bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM orders WHERE user_id = $1 AND status = $2)"
+	err := rr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, PROCESSED).Scan(&exists)
+
+	return exists, err
+}
+
+func (rr *ReferralRepository) CloseReferral(ctx context.Context, id int64, status string, referrerBonus decimal.Decimal, refereeBonus decimal.Decimal, orderNumber string) error {
+	query := `UPDATE referrals SET status = $1, referrer_bonus = $2, referee_bonus = $3, order_number = $4, rewarded_at = $5
+		WHERE id = $6`
+	_, err := rr.DBStorage.Querier(ctx).Exec(ctx, query, status, referrerBonus, refereeBonus, orderNumber, time.Now(), id)
+
+	return err
+}
+
+func (rr *ReferralRepository) ListInvitees(ctx context.Context, referrerID int) ([]interfaces.Invitee, error) {
+	query := `SELECT u.username, r.status, r.referrer_bonus, r.created_at, r.rewarded_at
+		FROM referrals r JOIN users u ON u.id = r.referee_id
+		WHERE r.referrer_id = $1 ORDER BY r.created_at DESC, r.id DESC`
+	rows, err := rr.DBStorage.Querier(ctx).Query(ctx, query, referrerID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitees := []interfaces.Invitee{}
+
+	for rows.Next() {
+		var invitee interfaces.Invitee
+		var bonus decimal.Decimal
+
+		if err := rows.Scan(&invitee.Login, &invitee.Status, &bonus, &invitee.RegisteredAt, &invitee.RewardedAt); err != nil {
+			return nil, err
+		}
+
+		invitee.Bonus, _ = bonus.Float64()
+		invitees = append(invitees, invitee)
+	}
+
+	return invitees, rows.Err()
+}
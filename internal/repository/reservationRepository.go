@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/storage"
+	"time"
+)
+
+// Reservation statuses. Only HELD reservations that have not expired yet
+// count as reserved; the sweeper moves the expired ones to EXPIRED.
+const (
+	ReservationHeld     = "HELD"
+	ReservationCaptured = "CAPTURED"
+	ReservationReleased = "RELEASED"
+	ReservationExpired  = "EXPIRED"
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationExists   = errors.New("order already has a reservation")
+)
+
+const reservationColumns = "id, user_id, order_number, amount, captured, status, expires_at, created_at"
+
+type ReservationRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+// HeldTotal sums the live holds of a user at now.
+func (rr *ReservationRepository) HeldTotal(ctx context.Context, userID int, now time.Time) (decimal.Decimal, error) {
+	var total decimal.Decimal
+
+	query := "SELECT COALESCE(SUM(amount), 0) FROM reservations WHERE user_id = $1 AND status = $2 AND expires_at > $3"
+	err := rr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, ReservationHeld, now).Scan(&total)
+
+	return total, err
+}
+
+func (rr *ReservationRepository) CreateReservation(ctx context.Context, userID int, orderNumber string, amount decimal.Decimal, expiresAt time.Time) (interfaces.Reservation, error) {
+	query := `INSERT INTO reservations (user_id, order_number, amount, status, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (user_id, order_number) DO NOTHING RETURNING ` + reservationColumns
+	reservation, err := scanReservation(rr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, orderNumber, amount, ReservationHeld, expiresAt, time.Now()))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return reservation, ErrReservationExists
+	}
+
+	return reservation, err
+}
+
+// FindReservation returns a reservation by id. With forUpdate it is locked
+// until the end of the transaction.
+func (rr *ReservationRepository) FindReservation(ctx context.Context, id int64, forUpdate bool) (interfaces.Reservation, error) {
+	query := "SELECT " + reservationColumns + " FROM reservations WHERE id = $1"
+
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	return rr.findReservation(ctx, query, id)
+}
+
+// FindUserReservation returns the reservation a user made for an order.
+// Order numbers are only unique per user.
+func (rr *ReservationRepository) FindUserReservation(ctx context.Context, userID int, orderNumber string) (interfaces.Reservation, error) {
+	query := "SELECT " + reservationColumns + " FROM reservations WHERE user_id = $1 AND order_number = $2"
+
+	return rr.findReservation(ctx, query, userID, orderNumber)
+}
+
+func (rr *ReservationRepository) findReservation(ctx context.Context, query string, args ...interface{}) (interfaces.Reservation, error) {
+	reservation, err := scanReservation(rr.DBStorage.Querier(ctx).QueryRow(ctx, query, args...))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return reservation, ErrReservationNotFound
+	}
+
+	return reservation, err
+}
+
+func (rr *ReservationRepository) UpdateReservation(ctx context.Context, id int64, status string, captured decimal.Decimal) error {
+	query := "UPDATE reservations SET status = $1, captured = $2, updated_at = $3 WHERE id = $4"
+	_, err := rr.DBStorage.Querier(ctx).Exec(ctx, query, status, captured, time.Now(), id)
+
+	return err
+}
+
+// Reservations lists the reservations of a user, newest first.
+func (rr *ReservationRepository) Reservations(ctx context.Context, userID int) ([]interfaces.Reservation, error) {
+	query := "SELECT " + reservationColumns + " FROM reservations WHERE user_id = $1 ORDER BY created_at DESC, id DESC"
+
+	return rr.queryReservations(ctx, query, userID)
+}
+
+// HeldReservations returns the HELD reservations of a user, expired or not.
+func (rr *ReservationRepository) HeldReservations(ctx context.Context, userID int) ([]interfaces.Reservation, error) {
+	query := "SELECT " + reservationColumns + " FROM reservations WHERE user_id = $1 AND status = $2 ORDER BY id"
+
+	return rr.queryReservations(ctx, query, userID, ReservationHeld)
+}
+
+// DueReservations returns HELD reservations that expired by now.
+func (rr *ReservationRepository) DueReservations(ctx context.Context, now time.Time, limit int) ([]interfaces.Reservation, error) {
+	query := "SELECT " + reservationColumns + ` FROM reservations
+		WHERE status = $1 AND expires_at <= $2 ORDER BY expires_at, id LIMIT $3`
+
+	return rr.queryReservations(ctx, query, ReservationHeld, now, limit)
+}
+
+func (rr *ReservationRepository) queryReservations(ctx context.Context, query string, args ...interface{}) ([]interfaces.Reservation, error) {
+	rows, err := rr.DBStorage.Querier(ctx).Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []interfaces.Reservation
+
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, rows.Err()
+}
+
+func scanReservation(row pgx.Row) (interfaces.Reservation, error) {
+	var reservation interfaces.Reservation
+	var amount, captured decimal.Decimal
+
+	err := row.Scan(&reservation.ID, &reservation.UserID, &reservation.OrderNumber, &amount, &captured,
+		&reservation.Status, &reservation.ExpiresAt, &reservation.CreatedAt)
+
+	reservation.Sum, _ = amount.Float64()
+	reservation.Captured, _ = captured.Float64()
+
+	return reservation, err
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"gophermart/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestCreateReservation_ScopedToUser(t *testing.T) {
+	pgs := storagetest.Open(t)
+	ctx := context.Background()
+	repo := ReservationRepository{DBStorage: pgs}
+	owner := storagetest.CreateUser(t, pgs, "owner")
+	other := storagetest.CreateUser(t, pgs, "other")
+	expiresAt := time.Now().Add(time.Hour)
+
+	const order = "2377225624"
+
+	held, err := repo.CreateReservation(ctx, owner, order, decimal.NewFromInt(100), expiresAt)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  int
+		wantErr error
+	}{
+		{name: "same user", userID: owner, wantErr: ErrReservationExists},
+		{name: "another user", userID: other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservation, err := repo.CreateReservation(ctx, tt.userID, order, decimal.NewFromInt(1), expiresAt)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+
+			if err == nil && reservation.ID == held.ID {
+				t.Fatal("Expected a separate reservation")
+			}
+
+			found, err := repo.FindUserReservation(ctx, owner, order)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if found.ID != held.ID || found.Sum != 100 {
+				t.Fatalf("Expected the reservation of the owner, got %+v", found)
+			}
+		})
+	}
+}
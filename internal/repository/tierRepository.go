@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"gophermart/internal/interfaces"
+	"gophermart/storage"
+	"time"
+)
+
+type TierRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+// Stats returns the points accrued by the processed orders of a user since the
+// given time, and their number. Tier bonuses are not counted.
+func (tr *TierRepository) Stats(ctx context.Context, userID int, since time.Time) (interfaces.TierStats, error) {
+	stats := interfaces.TierStats{UserID: userID}
+
+	query := `SELECT COALESCE(SUM(accrual), 0), COUNT(*) FROM orders
+		WHERE user_id = $1 AND status = $2 AND updated_at > $3`
+	err := tr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, PROCESSED, since).Scan(&stats.Points, &stats.Orders)
+
+	return stats, err
+}
+
+// StatsPage returns the statistics of every user, including those without
+// orders, in user id order after afterUserID.
+func (tr *TierRepository) StatsPage(ctx context.Context, since time.Time, afterUserID int, limit int) ([]interfaces.TierStats, error) {
+	query := `SELECT u.id, COALESCE(SUM(o.accrual), 0), COUNT(o.id)
+		FROM users u
+		LEFT JOIN orders o ON o.user_id = u.id AND o.status = $1 AND o.updated_at > $2
+		WHERE u.id > $3
+		GROUP BY u.id ORDER BY u.id LIMIT $4`
+	rows, err := tr.DBStorage.Querier(ctx).Query(ctx, query, PROCESSED, since, afterUserID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var page []interfaces.TierStats
+
+	for rows.Next() {
+		var stats interfaces.TierStats
+
+		if err := rows.Scan(&stats.UserID, &stats.Points, &stats.Orders); err != nil {
+			return nil, err
+		}
+
+		page = append(page, stats)
+	}
+
+	return page, rows.Err()
+}
+
+// GetUserTier returns the stored tier of a user, or an empty name when it has
+// not been calculated yet.
+func (tr *TierRepository) GetUserTier(ctx context.Context, userID int) (string, *time.Time, error) {
+	var tier string
+	var updatedAt time.Time
+
+	query := "SELECT tier, updated_at FROM user_tiers WHERE user_id = $1"
+	err := tr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID).Scan(&tier, &updatedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, nil
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tier, &updatedAt, nil
+}
+
+// SaveTier stores the tier of a user and returns the previous one, empty for
+// a user seen for the first time.
+func (tr *TierRepository) SaveTier(ctx context.Context, stats interfaces.TierStats, tier string) (string, error) {
+	var previous *string
+
+	query := `WITH previous AS (SELECT tier FROM user_tiers WHERE user_id = $1)
+		INSERT INTO user_tiers (user_id, tier, points, orders, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET tier = EXCLUDED.tier, points = EXCLUDED.points, orders = EXCLUDED.orders, updated_at = EXCLUDED.updated_at
+		RETURNING (SELECT tier FROM previous)`
+	err := tr.DBStorage.Querier(ctx).QueryRow(ctx, query, stats.UserID, tier, stats.Points, stats.Orders, time.Now()).Scan(&previous)
+
+	if err != nil || previous == nil {
+		return "", err
+	}
+
+	return *previous, nil
+}
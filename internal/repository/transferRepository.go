@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/storage"
+	"time"
+)
+
+const (
+	TransferIn  = "in"
+	TransferOut = "out"
+)
+
+type TransferRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+// FindByKey returns the transfer a sender made with an idempotency key and
+// the id of its recipient.
+func (tr *TransferRepository) FindByKey(ctx context.Context, senderID int, key string) (interfaces.Transfer, int, bool, error) {
+	transfer := interfaces.Transfer{Direction: TransferOut}
+	var recipientID int
+	var amount decimal.Decimal
+
+	query := `SELECT t.id, t.recipient_id, u.username, t.amount, t.created_at
+		FROM transfers t JOIN users u ON u.id = t.recipient_id
+		WHERE t.sender_id = $1 AND t.idempotency_key = $2`
+	err := tr.DBStorage.Querier(ctx).QueryRow(ctx, query, senderID, key).Scan(
+		&transfer.ID, &recipientID, &transfer.Counterparty, &amount, &transfer.CreatedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return transfer, 0, false, nil
+	}
+
+	transfer.Amount, _ = amount.Float64()
+
+	return transfer, recipientID, err == nil, err
+}
+
+// SentSince returns the sum and the number of transfers a sender made since
+// the given time.
+func (tr *TransferRepository) SentSince(ctx context.Context, senderID int, since time.Time) (decimal.Decimal, int, error) {
+	var sum decimal.Decimal
+	var count int
+
+	query := "SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM transfers WHERE sender_id = $1 AND created_at >= $2"
+	err := tr.DBStorage.Querier(ctx).QueryRow(ctx, query, senderID, since).Scan(&sum, &count)
+
+	return sum, count, err
+}
+
+func (tr *TransferRepository) CreateTransfer(ctx context.Context, senderID int, recipientID int, amount decimal.Decimal, key string) (int64, time.Time, error) {
+	var id int64
+	createdAt := time.Now()
+
+	query := `INSERT INTO transfers (sender_id, recipient_id, amount, idempotency_key, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := tr.DBStorage.Querier(ctx).QueryRow(ctx, query, senderID, recipientID, amount, key, createdAt).Scan(&id)
+
+	return id, createdAt, err
+}
+
+// Transfers lists the transfers a user sent and received, newest first.
+func (tr *TransferRepository) Transfers(ctx context.Context, userID int) ([]interfaces.Transfer, error) {
+	query := `SELECT t.id, CASE WHEN t.sender_id = $1 THEN $2 ELSE $3 END, u.username, t.amount, t.created_at
+		FROM transfers t
+		JOIN users u ON u.id = CASE WHEN t.sender_id = $1 THEN t.recipient_id ELSE t.sender_id END
+		WHERE t.sender_id = $1 OR t.recipient_id = $1
+		ORDER BY t.created_at DESC, t.id DESC`
+	rows, err := tr.DBStorage.Querier(ctx).Query(ctx, query, userID, TransferOut, TransferIn)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []interfaces.Transfer
+
+	for rows.Next() {
+		var transfer interfaces.Transfer
+		var amount decimal.Decimal
+
+		if err := rows.Scan(&transfer.ID, &transfer.Direction, &transfer.Counterparty, &amount, &transfer.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		transfer.Amount, _ = amount.Float64()
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
@@ -1,28 +1,44 @@
 package repository
 
 import (
+	"context"
 	"github.com/shopspring/decimal"
 	"gophermart/internal/interfaces"
 	"gophermart/internal/models"
 	"gophermart/storage"
+	"time"
 )
 
 type UserBalanceRepository struct {
 	DBStorage *storage.PgStorage
 }
 
-func (ubr *UserBalanceRepository) UpdateUserBalance(accrual decimal.Decimal, userID int) error {
+func (ubr *UserBalanceRepository) UpdateUserBalance(ctx context.Context, accrual decimal.Decimal, userID int) error {
 	query := "UPDATE user_balance SET current = current + $1 WHERE user_id = $2"
-	_, err := ubr.DBStorage.Conn.Exec(ubr.DBStorage.Ctx, query, accrual, userID)
+	_, err := ubr.DBStorage.Querier(ctx).Exec(ctx, query, accrual, userID)
 
 	return err
 }
 
-func (ubr *UserBalanceRepository) GetUserBalance(userID int) (interfaces.UserBalance, error) {
+// LockBalance locks the balance row of a user until the end of the
+// transaction. Every change to the balance or the lots of a user takes it.
+func (ubr *UserBalanceRepository) LockBalance(ctx context.Context, userID int) (decimal.Decimal, error) {
+	var current decimal.Decimal
+
+	query := "SELECT current FROM user_balance WHERE user_id = $1 FOR UPDATE"
+	err := ubr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID).Scan(&current)
+
+	return current, err
+}
+
+// GetUserBalance also reports the points held by live reservations.
+func (ubr *UserBalanceRepository) GetUserBalance(ctx context.Context, userID int) (interfaces.UserBalance, error) {
 	var userBalance interfaces.UserBalance
 
-	query := "SELECT current, withdrawn FROM user_balance WHERE user_id = $1"
-	rows, err := ubr.DBStorage.Conn.Query(ubr.DBStorage.Ctx, query, userID)
+	query := `SELECT current, withdrawn, debt, COALESCE((SELECT SUM(amount) FROM reservations
+		WHERE user_id = $1 AND status = $2 AND expires_at > $3), 0)
+		FROM user_balance WHERE user_id = $1`
+	rows, err := ubr.DBStorage.Querier(ctx).Query(ctx, query, userID, ReservationHeld, time.Now())
 
 	if err != nil {
 		return userBalance, err
@@ -30,7 +46,7 @@ func (ubr *UserBalanceRepository) GetUserBalance(userID int) (interfaces.UserBal
 	defer rows.Close()
 
 	for rows.Next() {
-		if err := rows.Scan(&userBalance.Current, &userBalance.Withdrawn); err != nil {
+		if err := rows.Scan(&userBalance.Current, &userBalance.Withdrawn, &userBalance.Debt, &userBalance.Reserved); err != nil {
 			return userBalance, err
 		}
 	}
@@ -42,9 +58,29 @@ func (ubr *UserBalanceRepository) GetUserBalance(userID int) (interfaces.UserBal
 	return userBalance, nil
 }
 
-func (ubr *UserBalanceRepository) CreateUserBalance(user models.User) error {
+// Debt returns the points a user owes from reduced accruals. The caller holds
+// the balance lock.
+func (ubr *UserBalanceRepository) Debt(ctx context.Context, userID int) (decimal.Decimal, error) {
+	var debt decimal.Decimal
+
+	query := "SELECT debt FROM user_balance WHERE user_id = $1"
+	err := ubr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID).Scan(&debt)
+
+	return debt, err
+}
+
+// AddDebt changes the debt of a user by amount, which is negative for
+// repayments.
+func (ubr *UserBalanceRepository) AddDebt(ctx context.Context, userID int, amount decimal.Decimal) error {
+	query := "UPDATE user_balance SET debt = debt + $1 WHERE user_id = $2"
+	_, err := ubr.DBStorage.Querier(ctx).Exec(ctx, query, amount, userID)
+
+	return err
+}
+
+func (ubr *UserBalanceRepository) CreateUserBalance(ctx context.Context, user models.User) error {
 	query := "INSERT INTO user_balance (user_id, current) VALUES ($1, 0)"
-	_, err := ubr.DBStorage.Conn.Exec(ubr.DBStorage.Ctx, query, user.ID)
+	_, err := ubr.DBStorage.Querier(ctx).Exec(ctx, query, user.ID)
 
 	return err
 }
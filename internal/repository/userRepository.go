@@ -1,12 +1,17 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
 	"gophermart/internal/interfaces"
 	"gophermart/internal/models"
 	"gophermart/storage"
 	"time"
 )
 
+var ErrUserDeleted = errors.New("user not found or already deleted")
+
 type UserRepository struct {
 	DBStorage *storage.PgStorage
 }
@@ -15,12 +20,12 @@ func (ur *UserRepository) GetDBStorage() interfaces.DBStorageInterface {
 	return &storage.PgStorage{}
 }
 
-func (ur *UserRepository) CreateUser(user models.User) (int, error) {
+func (ur *UserRepository) CreateUser(ctx context.Context, user models.User) (int, error) {
 	currentTime := time.Now()
 	query := "INSERT INTO users (username, password, created_at, updated_at) VALUES ($1, $2, $3, $4) RETURNING id"
 
 	var userID int
-	err := ur.DBStorage.Conn.QueryRow(ur.DBStorage.Ctx, query, user.Username, user.Password, currentTime, currentTime).Scan(&userID)
+	err := ur.DBStorage.Querier(ctx).QueryRow(ctx, query, user.Username, user.Password, currentTime, currentTime).Scan(&userID)
 	if err != nil {
 		return 0, err
 	}
@@ -28,10 +33,10 @@ func (ur *UserRepository) CreateUser(user models.User) (int, error) {
 	return userID, nil
 }
 
-func (ur *UserRepository) GetUserID(username string) int {
+func (ur *UserRepository) GetUserID(ctx context.Context, username string) int {
 	var id int
 	query := "SELECT id FROM users WHERE username = $1"
-	err := ur.DBStorage.Conn.QueryRow(ur.DBStorage.Ctx, query, username).Scan(&id)
+	err := ur.DBStorage.Querier(ctx).QueryRow(ctx, query, username).Scan(&id)
 
 	if err != nil && err.Error() != "no rows in result set" {
 		return DatabaseError
@@ -44,11 +49,65 @@ func (ur *UserRepository) GetUserID(username string) int {
 	return id
 }
 
-func (ur *UserRepository) GetUserByUsername(username string) (models.User, error) {
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
 	var user models.User
-	err := ur.DBStorage.Conn.QueryRow(
-		ur.DBStorage.Ctx,
+	err := ur.DBStorage.Querier(ctx).QueryRow(
+		ctx,
 		"SELECT id, username, password FROM users WHERE username = $1", username).
 		Scan(&user.ID, &user.Username, &user.Password)
 	return user, err
 }
+
+func (ur *UserRepository) GetUsername(ctx context.Context, userID int) (string, error) {
+	var username string
+	err := ur.DBStorage.Querier(ctx).QueryRow(ctx, "SELECT username FROM users WHERE id = $1", userID).Scan(&username)
+	return username, err
+}
+
+func (ur *UserRepository) GetProfile(ctx context.Context, userID int) (interfaces.UserProfile, error) {
+	profile := interfaces.UserProfile{ID: userID}
+	query := "SELECT username, COALESCE(referral_code, ''), created_at FROM users WHERE id = $1 AND deleted_at IS NULL"
+	err := ur.DBStorage.Querier(ctx).QueryRow(ctx, query, userID).Scan(&profile.Login, &profile.ReferralCode, &profile.CreatedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return profile, ErrUserDeleted
+	}
+
+	return profile, err
+}
+
+// Anonymize replaces the username, clears the password and the referral code
+// and revokes every token issued so far. The row stays, so that the financial
+// records referencing it are kept.
+func (ur *UserRepository) Anonymize(ctx context.Context, userID int, username string) error {
+	now := time.Now()
+	query := `UPDATE users SET username = $1, password = '', referral_code = NULL,
+		deleted_at = $2, tokens_revoked_at = $2, updated_at = $2
+		WHERE id = $3 AND deleted_at IS NULL`
+	tag, err := ur.DBStorage.Querier(ctx).Exec(ctx, query, username, now, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrUserDeleted
+	}
+
+	return nil
+}
+
+// TokensRevoked reports whether a token of the user issued at issuedAt has
+// been revoked. Tokens of unknown users count as revoked.
+func (ur *UserRepository) TokensRevoked(ctx context.Context, userID int, issuedAt time.Time) (bool, error) {
+	var revoked bool
+
+	query := "SELECT tokens_revoked_at IS NOT NULL AND tokens_revoked_at >= $2 FROM users WHERE id = $1"
+	err := ur.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, issuedAt).Scan(&revoked)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return true, nil
+	}
+
+	return revoked, err
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/pagination"
+	"gophermart/storage"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryDead      = "DEAD"
+)
+
+const WebhookDeliverySortCreatedAt = "created_at"
+
+// WebhookDeliverySortFields lists the sort fields accepted by DeadLetters.
+var WebhookDeliverySortFields = []string{WebhookDeliverySortCreatedAt}
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+var webhookDeliverySortColumns = map[string]sortColumn{
+	WebhookDeliverySortCreatedAt: {expr: "d.created_at", parse: timeCursorValue},
+}
+
+type WebhookRepository struct {
+	DBStorage *storage.PgStorage
+}
+
+func (wr *WebhookRepository) CreateEndpoint(ctx context.Context, url string, secret string, eventTypes []string) (interfaces.WebhookEndpoint, error) {
+	endpoint := interfaces.WebhookEndpoint{URL: url, Secret: secret, EventTypes: eventTypes, CreatedAt: time.Now()}
+
+	query := "INSERT INTO webhook_endpoints (url, secret, event_types, active, created_at) VALUES ($1, $2, $3, TRUE, $4) RETURNING id"
+	err := wr.DBStorage.Querier(ctx).QueryRow(ctx, query, url, secret, strings.Join(eventTypes, ","), endpoint.CreatedAt).Scan(&endpoint.ID)
+
+	return endpoint, err
+}
+
+func (wr *WebhookRepository) ListEndpoints(ctx context.Context) ([]interfaces.WebhookEndpoint, error) {
+	var endpoints []interfaces.WebhookEndpoint
+
+	query := "SELECT id, url, event_types, created_at FROM webhook_endpoints WHERE active ORDER BY id"
+	rows, err := wr.DBStorage.Querier(ctx).Query(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var endpoint interfaces.WebhookEndpoint
+		var eventTypes string
+
+		if err := rows.Scan(&endpoint.ID, &endpoint.URL, &eventTypes, &endpoint.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		endpoint.EventTypes = strings.Split(eventTypes, ",")
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
+
+// DeactivateEndpoint keeps the row for the delivery history and moves its
+// pending deliveries to the dead-letter view.
+func (wr *WebhookRepository) DeactivateEndpoint(ctx context.Context, id int64) error {
+	return wr.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		tag, err := wr.DBStorage.Querier(ctx).Exec(ctx, "UPDATE webhook_endpoints SET active = FALSE WHERE id = $1 AND active", id)
+
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrWebhookNotFound
+		}
+
+		query := "UPDATE webhook_deliveries SET status = $1, last_error = $2 WHERE endpoint_id = $3 AND status = $4"
+		_, err = wr.DBStorage.Querier(ctx).Exec(ctx, query, WebhookDeliveryDead, "endpoint removed", id, WebhookDeliveryPending)
+
+		return err
+	})
+}
+
+// EnqueueDeliveries writes one delivery per active endpoint subscribed to the
+// event. Repeating it for the same outbox event adds nothing.
+func (wr *WebhookRepository) EnqueueDeliveries(ctx context.Context, eventID int64, eventType string, payload string) error {
+	query := `INSERT INTO webhook_deliveries (endpoint_id, outbox_event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		SELECT id, $1, $2, $3, $4, 0, $5, $5 FROM webhook_endpoints
+		WHERE active AND $2 = ANY(string_to_array(event_types, ','))
+		ON CONFLICT (endpoint_id, outbox_event_id) DO NOTHING`
+	_, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, eventID, eventType, payload, WebhookDeliveryPending, time.Now())
+
+	return err
+}
+
+func (wr *WebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]interfaces.WebhookDelivery, error) {
+	var deliveries []interfaces.WebhookDelivery
+
+	query := `WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= $2
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = $4
+		FROM due, webhook_endpoints e
+		WHERE d.id = due.id AND e.id = d.endpoint_id
+		RETURNING d.id, d.endpoint_id, e.url, e.secret, d.event_type, d.payload, d.attempts, d.created_at`
+	rows, err := wr.DBStorage.Querier(ctx).Query(ctx, query, WebhookDeliveryPending, now, limit, leaseUntil)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery interfaces.WebhookDelivery
+
+		if err := rows.Scan(&delivery.ID, &delivery.EndpointID, &delivery.URL, &delivery.Secret,
+			&delivery.EventType, &delivery.Payload, &delivery.Attempts, &delivery.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (wr *WebhookRepository) MarkDelivered(ctx context.Context, id int64) error {
+	query := "UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, delivered_at = $2, last_error = NULL WHERE id = $3"
+	_, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, WebhookDeliveryDelivered, time.Now(), id)
+
+	return err
+}
+
+func (wr *WebhookRepository) MarkFailed(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, dead bool, lastError string) error {
+	status := WebhookDeliveryPending
+
+	if dead {
+		status = WebhookDeliveryDead
+	}
+
+	query := "UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4 WHERE id = $5"
+	_, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, status, attempts, nextAttemptAt, lastError, id)
+
+	return err
+}
+
+func (wr *WebhookRepository) DeadLetters(ctx context.Context, page pagination.Page) ([]interfaces.WebhookDelivery, *pagination.Cursor, error) {
+	base := `SELECT d.id, d.endpoint_id, e.url, d.event_type, d.payload, d.attempts, COALESCE(d.last_error, ''), d.created_at
+		FROM webhook_deliveries d JOIN webhook_endpoints e ON e.id = d.endpoint_id WHERE d.status = $1`
+	query, args, err := pageQuery(base, []interface{}{WebhookDeliveryDead}, page, webhookDeliverySortColumns, "d.created_at", "d.id")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := wr.DBStorage.Querier(ctx).Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []interfaces.WebhookDelivery
+
+	for rows.Next() {
+		var delivery interfaces.WebhookDelivery
+
+		if err := rows.Scan(&delivery.ID, &delivery.EndpointID, &delivery.URL, &delivery.EventType,
+			&delivery.Payload, &delivery.Attempts, &delivery.LastError, &delivery.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	count, next := nextCursor(page, len(deliveries), func(i int) (string, int64) {
+		return formatTimeCursor(deliveries[i].CreatedAt), deliveries[i].ID
+	})
+
+	return deliveries[:count], next, nil
+}
+
+// RetryDelivery moves a dead delivery back to the queue with a fresh attempt
+// budget, provided its endpoint is still active.
+func (wr *WebhookRepository) RetryDelivery(ctx context.Context, id int64) error {
+	query := `UPDATE webhook_deliveries d SET status = $1, attempts = 0, next_attempt_at = $2
+		FROM webhook_endpoints e
+		WHERE d.id = $3 AND d.status = $4 AND e.id = d.endpoint_id AND e.active`
+	tag, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, WebhookDeliveryPending, time.Now(), id, WebhookDeliveryDead)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
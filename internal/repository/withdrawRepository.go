@@ -1,12 +1,48 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
 	"github.com/shopspring/decimal"
 	"gophermart/internal/interfaces"
+	"gophermart/internal/pagination"
 	"gophermart/storage"
 	"time"
 )
 
+// Withdrawal statuses. A withdrawal is PENDING until the shop confirms it and
+// REFUNDED once all of its points are returned; partial refunds keep the
+// status.
+const (
+	WithdrawalPending   = "PENDING"
+	WithdrawalConfirmed = "CONFIRMED"
+	WithdrawalRefunded  = "REFUNDED"
+)
+
+// Withdrawal audit actions.
+const (
+	WithdrawalActionCreated   = "CREATED"
+	WithdrawalActionConfirmed = "CONFIRMED"
+	WithdrawalActionRefunded  = "REFUNDED"
+)
+
+var ErrWithdrawalNotFound = errors.New("withdrawal not found")
+
+const (
+	WithdrawalSortProcessedAt = "processed_at"
+	WithdrawalSortSum         = "sum"
+)
+
+// WithdrawalSortFields lists the sort fields accepted by WithdrawalsPage.
+var WithdrawalSortFields = []string{WithdrawalSortProcessedAt, WithdrawalSortSum}
+
+var withdrawalSortColumns = map[string]sortColumn{
+	WithdrawalSortProcessedAt: {expr: "created_at", parse: timeCursorValue},
+	WithdrawalSortSum:         {expr: "sum", parse: decimalCursorValue},
+}
+
 type WithdrawRepository struct {
 	DBStorage *storage.PgStorage
 }
@@ -15,11 +51,11 @@ func (wr *WithdrawRepository) GetDBStorage() interfaces.DBStorageInterface {
 	return wr.DBStorage
 }
 
-func (wr *WithdrawRepository) Withdrawals(userID int) ([]interfaces.WithdrawInfo, error) {
+func (wr *WithdrawRepository) Withdrawals(ctx context.Context, userID int) ([]interfaces.WithdrawInfo, error) {
 	var withdrawalInfoArray []interfaces.WithdrawInfo
 
-	query := "SELECT order_number, sum, created_at FROM withdrawal WHERE user_id = $1 ORDER BY created_at DESC"
-	rows, err := wr.DBStorage.Conn.Query(wr.DBStorage.Ctx, query, userID)
+	query := "SELECT id, order_number, sum, status, refunded, created_at FROM withdrawal WHERE user_id = $1 ORDER BY created_at DESC"
+	rows, err := wr.DBStorage.Querier(ctx).Query(ctx, query, userID)
 
 	if err != nil {
 		return withdrawalInfoArray, err
@@ -28,7 +64,8 @@ func (wr *WithdrawRepository) Withdrawals(userID int) ([]interfaces.WithdrawInfo
 
 	for rows.Next() {
 		var withdrawalInfo interfaces.WithdrawInfo
-		if err := rows.Scan(&withdrawalInfo.OrderNumber, &withdrawalInfo.Sum, &withdrawalInfo.ProcessedAt); err != nil {
+		if err := rows.Scan(&withdrawalInfo.ID, &withdrawalInfo.OrderNumber, &withdrawalInfo.Sum, &withdrawalInfo.Status,
+			&withdrawalInfo.Refunded, &withdrawalInfo.ProcessedAt); err != nil {
 			return withdrawalInfoArray, err
 		}
 
@@ -42,30 +79,187 @@ func (wr *WithdrawRepository) Withdrawals(userID int) ([]interfaces.WithdrawInfo
 	return withdrawalInfoArray, nil
 }
 
-func (wr *WithdrawRepository) GetCurrentBalance(userID int) (decimal.Decimal, error) {
+func (wr *WithdrawRepository) WithdrawalsPage(ctx context.Context, userID int, page pagination.Page) ([]interfaces.WithdrawInfo, *pagination.Cursor, error) {
+	base := "SELECT id, order_number, sum, status, refunded, created_at FROM withdrawal WHERE user_id = $1"
+	query, args, err := pageQuery(base, []interface{}{userID}, page, withdrawalSortColumns, "created_at", "id")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := wr.DBStorage.Querier(ctx).Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var withdrawals []interfaces.WithdrawInfo
+	var ids []int64
+	var sums []decimal.Decimal
+
+	for rows.Next() {
+		var withdrawalInfo interfaces.WithdrawInfo
+		var sum, refunded decimal.Decimal
+
+		if err := rows.Scan(&withdrawalInfo.ID, &withdrawalInfo.OrderNumber, &sum, &withdrawalInfo.Status, &refunded, &withdrawalInfo.ProcessedAt); err != nil {
+			return nil, nil, err
+		}
+
+		value, _ := sum.Float64()
+		withdrawalInfo.Sum = float32(value)
+		value, _ = refunded.Float64()
+		withdrawalInfo.Refunded = float32(value)
+		withdrawals = append(withdrawals, withdrawalInfo)
+		ids = append(ids, withdrawalInfo.ID)
+		sums = append(sums, sum)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	count, next := nextCursor(page, len(withdrawals), func(i int) (string, int64) {
+		if page.Field() == WithdrawalSortSum {
+			return sums[i].String(), ids[i]
+		}
+
+		return formatTimeCursor(withdrawals[i].ProcessedAt), ids[i]
+	})
+
+	return withdrawals[:count], next, nil
+}
+
+// WithdrawalsTotal sums withdrawals in the date range of page, ignoring its
+// cursor and limit. Refunded points are not counted, as in the withdrawn
+// balance.
+func (wr *WithdrawRepository) WithdrawalsTotal(ctx context.Context, userID int, page pagination.Page) (int, decimal.Decimal, error) {
+	var count int
+	var sum decimal.Decimal
+
+	query := "SELECT COUNT(*), COALESCE(SUM(sum - refunded), 0) FROM withdrawal WHERE user_id = $1"
+	args := []interface{}{userID}
+
+	if !page.From.IsZero() {
+		args = append(args, page.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+
+	if !page.To.IsZero() {
+		args = append(args, page.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	err := wr.DBStorage.Querier(ctx).QueryRow(ctx, query, args...).Scan(&count, &sum)
+
+	return count, sum, err
+}
+
+func (wr *WithdrawRepository) GetCurrentBalance(ctx context.Context, userID int) (decimal.Decimal, error) {
 	var userBalance decimal.Decimal
 
-	query := "SELECT current FROM user_balance WHERE user_id = $1"
-	if err := wr.DBStorage.Conn.QueryRow(wr.DBStorage.Ctx, query, userID).Scan(&userBalance); err != nil {
+	query := "SELECT current FROM user_balance WHERE user_id = $1 FOR UPDATE"
+	if err := wr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID).Scan(&userBalance); err != nil {
 		return decimal.Decimal{}, err
 	}
 
 	return userBalance, nil
 }
 
-func (wr *WithdrawRepository) UpdateUserBalance(userID int, sum decimal.Decimal) error {
+func (wr *WithdrawRepository) UpdateUserBalance(ctx context.Context, userID int, sum decimal.Decimal) error {
 	query := "UPDATE user_balance SET current = current - $1, withdrawn = withdrawn + $1 WHERE user_id = $2"
-	if _, err := wr.DBStorage.Conn.Exec(wr.DBStorage.Ctx, query, sum, userID); err != nil {
+	if _, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, sum, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (wr *WithdrawRepository) SaveWithdrawal(userID int, orderNumber string, sum decimal.Decimal) error {
+// SaveWithdrawal records a withdrawal and returns its id. lotsExpireAt is the
+// earliest expiry of the lots the points were taken from, which refunds keep.
+func (wr *WithdrawRepository) SaveWithdrawal(ctx context.Context, userID int, orderNumber string, sum decimal.Decimal, status string, lotsExpireAt *time.Time) (int64, error) {
+	var id int64
 	currentTime := time.Now()
-	query := "INSERT INTO withdrawal (user_id, order_number, sum, created_at) VALUES ($1, $2, $3, $4)"
-	if _, err := wr.DBStorage.Conn.Exec(wr.DBStorage.Ctx, query, userID, orderNumber, sum, currentTime); err != nil {
-		return err
+
+	query := `INSERT INTO withdrawal (user_id, order_number, sum, status, lots_expire_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING id`
+	err := wr.DBStorage.Querier(ctx).QueryRow(ctx, query, userID, orderNumber, sum, status, lotsExpireAt, currentTime).Scan(&id)
+
+	return id, err
+}
+
+// FindWithdrawal returns a withdrawal by id. With forUpdate it is locked until
+// the end of the transaction.
+func (wr *WithdrawRepository) FindWithdrawal(ctx context.Context, id int64, forUpdate bool) (interfaces.Withdrawal, error) {
+	var withdrawal interfaces.Withdrawal
+	var sum, refunded decimal.Decimal
+
+	query := `SELECT id, user_id, order_number, sum, refunded, status, lots_expire_at, created_at FROM withdrawal
+		WHERE id = $1`
+
+	if forUpdate {
+		query += " FOR UPDATE"
 	}
-	return nil
+
+	err := wr.DBStorage.Querier(ctx).QueryRow(ctx, query, id).Scan(
+		&withdrawal.ID, &withdrawal.UserID, &withdrawal.OrderNumber, &sum, &refunded, &withdrawal.Status,
+		&withdrawal.LotsExpireAt, &withdrawal.CreatedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return withdrawal, ErrWithdrawalNotFound
+	}
+
+	withdrawal.Sum, _ = sum.Float64()
+	withdrawal.Refunded, _ = refunded.Float64()
+
+	return withdrawal, err
+}
+
+func (wr *WithdrawRepository) UpdateWithdrawalStatus(ctx context.Context, id int64, status string, refunded decimal.Decimal) error {
+	query := "UPDATE withdrawal SET status = $1, refunded = $2, updated_at = $3 WHERE id = $4"
+	_, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, status, refunded, time.Now(), id)
+
+	return err
+}
+
+// ReduceWithdrawn takes refunded points out of the withdrawn total; the caller
+// credits them back to current through the ledger.
+func (wr *WithdrawRepository) ReduceWithdrawn(ctx context.Context, userID int, amount decimal.Decimal) error {
+	query := "UPDATE user_balance SET withdrawn = withdrawn - $1 WHERE user_id = $2"
+	_, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, amount, userID)
+
+	return err
+}
+
+func (wr *WithdrawRepository) RecordWithdrawalEvent(ctx context.Context, withdrawalID int64, action string, amount decimal.Decimal, actor string, reason string) error {
+	query := `INSERT INTO withdrawal_events (withdrawal_id, action, amount, actor, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := wr.DBStorage.Querier(ctx).Exec(ctx, query, withdrawalID, action, amount, actor, reason, time.Now())
+
+	return err
+}
+
+func (wr *WithdrawRepository) WithdrawalHistory(ctx context.Context, withdrawalID int64) ([]interfaces.WithdrawalEvent, error) {
+	query := "SELECT action, amount, actor, reason, created_at FROM withdrawal_events WHERE withdrawal_id = $1 ORDER BY id"
+	rows, err := wr.DBStorage.Querier(ctx).Query(ctx, query, withdrawalID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []interfaces.WithdrawalEvent
+
+	for rows.Next() {
+		var event interfaces.WithdrawalEvent
+		var amount decimal.Decimal
+
+		if err := rows.Scan(&event.Action, &amount, &event.Actor, &event.Reason, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		event.Amount, _ = amount.Float64()
+		history = append(history, event)
+	}
+
+	return history, rows.Err()
 }
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/storage/storagetest"
+	"testing"
+)
+
+func TestFindWithdrawal_SameOrderNumber(t *testing.T) {
+	pgs := storagetest.Open(t)
+	ctx := context.Background()
+	repo := WithdrawRepository{DBStorage: pgs}
+	owner := storagetest.CreateUser(t, pgs, "owner")
+	other := storagetest.CreateUser(t, pgs, "other")
+
+	const order = "2377225624"
+
+	// Another user withdrawing against the order number first must not keep
+	// its owner from paying for it.
+	otherID, err := repo.SaveWithdrawal(ctx, other, order, decimal.RequireFromString("0.01"), WithdrawalPending, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ownerID, err := repo.SaveWithdrawal(ctx, owner, order, decimal.NewFromInt(500), WithdrawalPending, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		id     int64
+		userID int
+		sum    float64
+	}{
+		{name: "owner", id: ownerID, userID: owner, sum: 500},
+		{name: "other user", id: otherID, userID: other, sum: 0.01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withdrawal, err := repo.FindWithdrawal(ctx, tt.id, false)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if withdrawal.UserID != tt.userID || withdrawal.Sum != tt.sum {
+				t.Fatalf("Expected the withdrawal of user %d, got %+v", tt.userID, withdrawal)
+			}
+		})
+	}
+}
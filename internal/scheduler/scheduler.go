@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Fn       func(ctx context.Context) error
+}
+
+// Scheduler runs background jobs at fixed intervals, each in its own
+// goroutine. Runs of one job never overlap; jobs must tolerate running on
+// several replicas at once.
+type Scheduler struct {
+	jobs []Job
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New() *Scheduler {
+	s := &Scheduler{}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	return s
+}
+
+// Add registers a job; it must be called before Start.
+func (s *Scheduler) Add(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Fn: fn})
+}
+
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+
+		go func(job Job) {
+			defer s.wg.Done()
+			s.run(job)
+		}(job)
+	}
+}
+
+func (s *Scheduler) run(job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+
+		if err := job.Fn(s.ctx); err != nil && s.ctx.Err() == nil {
+			slog.Error("Scheduled job failed", "job", job.Name, "duration", time.Since(start), "error", err)
+			continue
+		}
+
+		slog.Debug("Scheduled job finished", "job", job.Name, "duration", time.Since(start))
+	}
+}
+
+// Shutdown cancels running jobs and waits for them to return.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RunsJobsUntilShutdown(t *testing.T) {
+	var ok, failing atomic.Int32
+
+	s := New()
+	s.Add("ok", 5*time.Millisecond, func(ctx context.Context) error {
+		ok.Add(1)
+		return nil
+	})
+	s.Add("failing", 5*time.Millisecond, func(ctx context.Context) error {
+		failing.Add(1)
+		return errors.New("boom")
+	})
+	s.Start()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok.Load() < 2 || failing.Load() < 2 {
+		t.Errorf("Expected both jobs to keep running, got %d and %d runs", ok.Load(), failing.Load())
+	}
+
+	runs := ok.Load()
+	time.Sleep(20 * time.Millisecond)
+
+	if ok.Load() != runs {
+		t.Error("Expected no runs after shutdown")
+	}
+}
+
+func TestScheduler_ShutdownDeadline(t *testing.T) {
+	s := New()
+	s.Add("stuck", time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	s.Start()
+
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+}
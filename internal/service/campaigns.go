@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/campaigns"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"log/slog"
+)
+
+type CampaignService struct {
+	CampaignRepository *repository.CampaignRepository
+	// TierNames are the tiers campaign conditions may refer to.
+	TierNames []string
+}
+
+// CampaignBonus is the bonus one campaign grants for an order.
+type CampaignBonus struct {
+	CampaignID int64
+	Name       string
+	Amount     decimal.Decimal
+}
+
+func (cs *CampaignService) CreateCampaign(ctx context.Context, campaign interfaces.Campaign) (interfaces.Campaign, error) {
+	if err := campaigns.Validate(campaign, cs.TierNames); err != nil {
+		return campaign, err
+	}
+
+	campaign, err := cs.CampaignRepository.CreateCampaign(ctx, campaign)
+
+	if err != nil {
+		return campaign, err
+	}
+
+	slog.InfoContext(ctx, "Campaign created", "campaign", campaign.ID, "name", campaign.Name,
+		"starts_at", campaign.StartsAt, "ends_at", campaign.EndsAt)
+
+	return campaign, nil
+}
+
+func (cs *CampaignService) ListCampaigns(ctx context.Context) ([]interfaces.Campaign, error) {
+	return cs.CampaignRepository.ListCampaigns(ctx)
+}
+
+func (cs *CampaignService) GetCampaign(ctx context.Context, id int64) (interfaces.Campaign, error) {
+	return cs.CampaignRepository.GetCampaign(ctx, id)
+}
+
+func (cs *CampaignService) UpdateCampaign(ctx context.Context, campaign interfaces.Campaign) (interfaces.Campaign, error) {
+	if err := campaigns.Validate(campaign, cs.TierNames); err != nil {
+		return campaign, err
+	}
+
+	campaign, err := cs.CampaignRepository.UpdateCampaign(ctx, campaign)
+
+	if err != nil {
+		return campaign, err
+	}
+
+	slog.InfoContext(ctx, "Campaign updated", "campaign", campaign.ID)
+
+	return campaign, nil
+}
+
+func (cs *CampaignService) DeleteCampaign(ctx context.Context, id int64) error {
+	if err := cs.CampaignRepository.DeactivateCampaign(ctx, id); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Campaign removed", "campaign", id)
+
+	return nil
+}
+
+// Bonuses evaluates the campaigns running when the order was processed.
+func (cs *CampaignService) Bonuses(ctx context.Context, order campaigns.Order) ([]CampaignBonus, error) {
+	active, err := cs.CampaignRepository.ActiveAt(ctx, order.ProcessedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var bonuses []CampaignBonus
+
+	for _, campaign := range active {
+		amount := campaigns.Bonus(campaign, order)
+
+		if amount.IsPositive() {
+			bonuses = append(bonuses, CampaignBonus{CampaignID: campaign.ID, Name: campaign.Name, Amount: amount})
+		}
+	}
+
+	return bonuses, nil
+}
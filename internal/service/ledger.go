@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/outbox"
+	"gophermart/internal/repository"
+	"gophermart/internal/webhooks"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+const expiryBatchSize = 100
+
+// Debt policies for reductions the balance cannot cover.
+const (
+	// DebtPolicyNegative debits the whole reduction, so current may go below
+	// zero.
+	DebtPolicyNegative = "negative"
+	// DebtPolicyDebt debits what current covers and records the rest as debt,
+	// which later credits repay first.
+	DebtPolicyDebt = "debt"
+)
+
+// Ledger moves points in and out of balances. Every movement updates
+// user_balance, the point lots and the ledger together, so the ledger of a
+// user always adds up to their current balance.
+type Ledger struct {
+	UserBalanceRepository *repository.UserBalanceRepository
+	LedgerRepository      *repository.LedgerRepository
+	OutboxRepository      *repository.OutboxRepository
+	// PointsTTL is the lifetime of accrued points; zero keeps them forever.
+	PointsTTL          time.Duration
+	ExpiringSoonWindow time.Duration
+	DebtPolicy         string
+}
+
+// Credit adds the points of a positive entry as a new lot.
+func (l *Ledger) Credit(ctx context.Context, userID int, entry interfaces.LedgerEntry) error {
+	var expiresAt *time.Time
+
+	if l.PointsTTL > 0 {
+		at := time.Now().Add(l.PointsTTL)
+		expiresAt = &at
+	}
+
+	return l.credit(ctx, userID, entry, expiresAt)
+}
+
+// CreditUntil adds the points of a positive entry as a lot expiring at
+// expiresAt, such as refunded points that keep the expiry they had.
+func (l *Ledger) CreditUntil(ctx context.Context, userID int, entry interfaces.LedgerEntry, expiresAt time.Time) error {
+	return l.credit(ctx, userID, entry, &expiresAt)
+}
+
+func (l *Ledger) credit(ctx context.Context, userID int, entry interfaces.LedgerEntry, expiresAt *time.Time) error {
+	return l.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		if err := l.UserBalanceRepository.UpdateUserBalance(ctx, entry.Amount, userID); err != nil {
+			return err
+		}
+
+		if err := l.LedgerRepository.AddLot(ctx, userID, entry.OrderNumber, entry.Amount, expiresAt); err != nil {
+			return err
+		}
+
+		if err := l.LedgerRepository.Record(ctx, userID, entry); err != nil {
+			return err
+		}
+
+		event := outbox.BalanceCredited{
+			Kind: entry.Kind, Amount: entry.Amount, Order: entry.OrderNumber, Campaign: entry.CampaignID, Transfer: entry.TransferID,
+		}
+
+		if err := appendEvent(ctx, l.OutboxRepository, userID, outbox.TypeBalanceCredited, event); err != nil {
+			return err
+		}
+
+		return l.repayDebt(ctx, userID, entry)
+	})
+}
+
+// Reduce takes back points credited earlier, such as a lowered accrual. The
+// part current does not cover is handled by DebtPolicy; Reduce returns the
+// part recorded as debt.
+func (l *Ledger) Reduce(ctx context.Context, userID int, entry interfaces.LedgerEntry) (decimal.Decimal, error) {
+	debt := decimal.Zero
+
+	err := l.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		current, err := l.UserBalanceRepository.LockBalance(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		expired, err := l.ExpireDue(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		current = current.Sub(expired)
+		amount := entry.Amount
+
+		if l.DebtPolicy == DebtPolicyDebt {
+			covered := decimal.Max(decimal.Min(amount, current), decimal.Zero)
+			debt = amount.Sub(covered)
+			amount = covered
+		}
+
+		if debt.IsPositive() {
+			if err := l.UserBalanceRepository.AddDebt(ctx, userID, debt); err != nil {
+				return err
+			}
+		}
+
+		if !amount.IsPositive() {
+			return nil
+		}
+
+		if err := l.UserBalanceRepository.UpdateUserBalance(ctx, amount.Neg(), userID); err != nil {
+			return err
+		}
+
+		entry.Amount = amount
+		_, err = l.Debit(ctx, userID, current, entry)
+
+		return err
+	})
+
+	return debt, err
+}
+
+// repayDebt takes what a credit allows towards the debt of the user.
+func (l *Ledger) repayDebt(ctx context.Context, userID int, credit interfaces.LedgerEntry) error {
+	if l.DebtPolicy != DebtPolicyDebt {
+		return nil
+	}
+
+	current, err := l.UserBalanceRepository.LockBalance(ctx, userID)
+
+	if err != nil {
+		return err
+	}
+
+	debt, err := l.UserBalanceRepository.Debt(ctx, userID)
+
+	if err != nil || !debt.IsPositive() {
+		return err
+	}
+
+	repay := decimal.Min(debt, credit.Amount, decimal.Max(current, decimal.Zero))
+
+	if !repay.IsPositive() {
+		return nil
+	}
+
+	if err := l.UserBalanceRepository.UpdateUserBalance(ctx, repay.Neg(), userID); err != nil {
+		return err
+	}
+
+	if err := l.UserBalanceRepository.AddDebt(ctx, userID, repay.Neg()); err != nil {
+		return err
+	}
+
+	entry := interfaces.LedgerEntry{Kind: repository.LedgerDebtRepayment, Amount: repay, OrderNumber: credit.OrderNumber}
+
+	if _, err := l.Debit(ctx, userID, current, entry); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Debt repaid", "user_id", userID, "amount", repay, "debt", debt.Sub(repay))
+
+	return nil
+}
+
+// Debit takes the points of an entry from the lots, oldest first, and records
+// it as a negative line. current is the balance before the debit; points
+// accrued before lots were tracked are the oldest and are spent first. The
+// caller holds the balance lock and has already updated user_balance.
+//
+// Debit returns the earliest expiry of the lots the points came from, nil when
+// none of them expire.
+func (l *Ledger) Debit(ctx context.Context, userID int, current decimal.Decimal, entry interfaces.LedgerEntry) (*time.Time, error) {
+	var expiresAt *time.Time
+
+	amount := entry.Amount
+	now := time.Now()
+	live, err := l.LedgerRepository.LiveTotal(ctx, userID, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if fromLots := amount.Sub(current.Sub(live)); fromLots.IsPositive() {
+		if expiresAt, err = l.LedgerRepository.ConsumeLots(ctx, userID, fromLots, now); err != nil {
+			return nil, err
+		}
+	}
+
+	entry.Amount = amount.Neg()
+
+	return expiresAt, l.LedgerRepository.Record(ctx, userID, entry)
+}
+
+// ExpireDue expires the due lots of a user whose balance lock the caller holds
+// and returns the points removed, so that a withdrawal never spends expired
+// points the job has not reached yet.
+func (l *Ledger) ExpireDue(ctx context.Context, userID int) (decimal.Decimal, error) {
+	total := decimal.Zero
+
+	for {
+		lots, err := l.LedgerRepository.DueLots(ctx, userID, time.Now(), expiryBatchSize)
+
+		if err != nil {
+			return total, err
+		}
+
+		for _, lot := range lots {
+			expired, err := l.expireLot(ctx, lot)
+
+			if err != nil {
+				return total, err
+			}
+
+			total = total.Add(expired)
+		}
+
+		if len(lots) < expiryBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// ExpirePoints is the scheduled expiry job. Each lot expires in its own
+// transaction under the balance lock of its user.
+func (l *Ledger) ExpirePoints(ctx context.Context) error {
+	for {
+		lots, err := l.LedgerRepository.DueLots(ctx, 0, time.Now(), expiryBatchSize)
+
+		if err != nil {
+			return err
+		}
+
+		for _, lot := range lots {
+			err := l.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+				if _, err := l.UserBalanceRepository.LockBalance(ctx, lot.UserID); err != nil {
+					return err
+				}
+
+				_, err := l.expireLot(ctx, lot)
+
+				return err
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(lots) < expiryBatchSize {
+			return nil
+		}
+	}
+}
+
+func (l *Ledger) expireLot(ctx context.Context, lot interfaces.PointLot) (decimal.Decimal, error) {
+	expired, err := l.LedgerRepository.ExpireLot(ctx, lot.ID)
+
+	if err != nil || expired.IsZero() {
+		return decimal.Zero, err
+	}
+
+	if err := l.UserBalanceRepository.UpdateUserBalance(ctx, expired.Neg(), lot.UserID); err != nil {
+		return decimal.Zero, err
+	}
+
+	entry := interfaces.LedgerEntry{Kind: repository.LedgerExpiry, Amount: expired.Neg(), OrderNumber: lot.OrderNumber}
+
+	if err := l.LedgerRepository.Record(ctx, lot.UserID, entry); err != nil {
+		return decimal.Zero, err
+	}
+
+	event := outbox.PointsExpired{Amount: expired, Order: lot.OrderNumber}
+
+	if err := appendEvent(ctx, l.OutboxRepository, lot.UserID, outbox.TypePointsExpired, event); err != nil {
+		return decimal.Zero, err
+	}
+
+	balance, err := publishBalance(ctx, l.UserBalanceRepository, lot.UserID)
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	value, _ := expired.Float64()
+
+	if err := appendEvent(ctx, l.OutboxRepository, lot.UserID, webhooks.EventBalanceAdjusted, webhooks.BalanceData{
+		UserID: lot.UserID, Delta: -float32(value), Reason: "expiry", Order: lot.OrderNumber,
+		Current: balance.Current, Withdrawn: balance.Withdrawn,
+	}); err != nil {
+		return decimal.Zero, err
+	}
+
+	slog.InfoContext(ctx, "Points expired", "user_id", lot.UserID, "order", lot.OrderNumber, "amount", expired, "expires_at", lot.ExpiresAt)
+
+	return expired, nil
+}
+
+// lockBalancesInOrder locks the balances of several users, lower user id
+// first. Every transaction that locks more than one balance goes through it,
+// so that they cannot deadlock each other.
+func lockBalancesInOrder(ctx context.Context, ubr *repository.UserBalanceRepository, userIDs ...int) (map[int]decimal.Decimal, error) {
+	ids := append([]int(nil), userIDs...)
+	sort.Ints(ids)
+
+	balances := map[int]decimal.Decimal{}
+
+	for _, userID := range ids {
+		if _, locked := balances[userID]; locked {
+			continue
+		}
+
+		current, err := ubr.LockBalance(ctx, userID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		balances[userID] = current
+	}
+
+	return balances, nil
+}
+
+// ExpiringSoon lists the points of a user expiring within ExpiringSoonWindow.
+func (l *Ledger) ExpiringSoon(ctx context.Context, userID int) ([]interfaces.ExpiringPoints, error) {
+	if l.PointsTTL <= 0 || l.ExpiringSoonWindow <= 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+
+	return l.LedgerRepository.ExpiringSoon(ctx, userID, now, now.Add(l.ExpiringSoonWindow))
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"gophermart/storage"
+	"gophermart/storage/storagetest"
+	"testing"
+)
+
+// newTestLedger returns a ledger on an empty test database.
+func newTestLedger(t *testing.T, debtPolicy string) (*Ledger, *storage.PgStorage) {
+	t.Helper()
+
+	pgs := storagetest.Open(t)
+
+	return &Ledger{
+		UserBalanceRepository: &repository.UserBalanceRepository{DBStorage: pgs},
+		LedgerRepository:      &repository.LedgerRepository{DBStorage: pgs},
+		OutboxRepository:      &repository.OutboxRepository{DBStorage: pgs},
+		DebtPolicy:            debtPolicy,
+	}, pgs
+}
+
+func balanceOf(t *testing.T, l *Ledger, userID int) interfaces.UserBalance {
+	t.Helper()
+
+	balance, err := l.UserBalanceRepository.GetUserBalance(context.Background(), userID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return balance
+}
+
+// queryDecimal returns the single value of a query such as a sum.
+func queryDecimal(t *testing.T, pgs *storage.PgStorage, query string, args ...interface{}) decimal.Decimal {
+	t.Helper()
+
+	var value decimal.Decimal
+
+	if err := pgs.Conn.QueryRow(context.Background(), query, args...).Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+func credit(t *testing.T, l *Ledger, userID int, kind string, amount int64, orderNumber string) {
+	t.Helper()
+
+	entry := interfaces.LedgerEntry{Kind: kind, Amount: decimal.NewFromInt(amount), OrderNumber: orderNumber}
+
+	if err := l.Credit(context.Background(), userID, entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLedger_Debit(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    int64
+		wantFirst string
+		wantLots  string
+	}{
+		{name: "points older than the lots", amount: 30, wantFirst: "100", wantLots: "140"},
+		{name: "older points, then the oldest lot", amount: 80, wantFirst: "70", wantLots: "110"},
+		{name: "older points and lots in order", amount: 170, wantFirst: "0", wantLots: "20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, pgs := newTestLedger(t, DebtPolicyNegative)
+			ctx := context.Background()
+			userID := storagetest.CreateUser(t, pgs, "user")
+
+			credit(t, l, userID, repository.LedgerAccrual, 100, "12345678903")
+			credit(t, l, userID, repository.LedgerAccrual, 40, "79927398713")
+
+			// Points accrued before lots were tracked are only in the balance.
+			if err := l.UserBalanceRepository.UpdateUserBalance(ctx, decimal.NewFromInt(50), userID); err != nil {
+				t.Fatal(err)
+			}
+
+			err := pgs.WithTx(ctx, func(ctx context.Context) error {
+				current, err := l.UserBalanceRepository.LockBalance(ctx, userID)
+
+				if err != nil {
+					return err
+				}
+
+				amount := decimal.NewFromInt(tt.amount)
+
+				if err := l.UserBalanceRepository.UpdateUserBalance(ctx, amount.Neg(), userID); err != nil {
+					return err
+				}
+
+				_, err = l.Debit(ctx, userID, current, interfaces.LedgerEntry{Kind: repository.LedgerWithdrawal, Amount: amount})
+
+				return err
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lots := queryDecimal(t, pgs, "SELECT SUM(remaining) FROM point_lots WHERE user_id = $1", userID)
+			first := queryDecimal(t, pgs, "SELECT remaining FROM point_lots WHERE user_id = $1 ORDER BY id LIMIT 1", userID)
+			debited := queryDecimal(t, pgs, "SELECT SUM(amount) FROM balance_ledger WHERE user_id = $1 AND kind = $2",
+				userID, repository.LedgerWithdrawal)
+
+			if first.String() != tt.wantFirst || lots.String() != tt.wantLots {
+				t.Fatalf("Expected %s points in the oldest lot and %s in all, got %s and %s", tt.wantFirst, tt.wantLots, first, lots)
+			}
+
+			if !debited.Equal(decimal.NewFromInt(-tt.amount)) {
+				t.Fatalf("Expected a ledger line of -%d, got %s", tt.amount, debited)
+			}
+		})
+	}
+}
+
+func TestLedger_Reduce(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantDebt    int64
+		wantCurrent float32
+	}{
+		{policy: DebtPolicyNegative, wantDebt: 0, wantCurrent: -50},
+		{policy: DebtPolicyDebt, wantDebt: 50, wantCurrent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			l, pgs := newTestLedger(t, tt.policy)
+			ctx := context.Background()
+			userID := storagetest.CreateUser(t, pgs, "user")
+
+			credit(t, l, userID, repository.LedgerAccrual, 100, "12345678903")
+
+			entry := interfaces.LedgerEntry{Kind: repository.LedgerRevision, Amount: decimal.NewFromInt(150), OrderNumber: "12345678903"}
+			debt, err := l.Reduce(ctx, userID, entry)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if balance := balanceOf(t, l, userID); !debt.Equal(decimal.NewFromInt(tt.wantDebt)) ||
+				balance.Debt != float32(tt.wantDebt) || balance.Current != tt.wantCurrent {
+				t.Fatalf("Expected current %v and debt %v, got %+v (returned debt %s)", tt.wantCurrent, tt.wantDebt, balance, debt)
+			}
+
+			// Under both policies the next credit ends at the same balance:
+			// either current recovers from below zero or the debt is repaid.
+			credit(t, l, userID, repository.LedgerAccrual, 80, "79927398713")
+
+			if balance := balanceOf(t, l, userID); balance.Current != 30 || balance.Debt != 0 {
+				t.Fatalf("Expected current 30 and no debt after the credit, got %+v", balance)
+			}
+
+			total := queryDecimal(t, pgs, "SELECT SUM(amount) FROM balance_ledger WHERE user_id = $1", userID)
+
+			if !total.Equal(decimal.NewFromInt(30)) {
+				t.Fatalf("Expected the ledger to add up to the balance, got %s", total)
+			}
+		})
+	}
+}
@@ -1,31 +1,535 @@
 package service
 
 import (
+	"context"
 	"github.com/shopspring/decimal"
+	"gophermart/internal/campaigns"
+	"gophermart/internal/events"
 	"gophermart/internal/interfaces"
+	"gophermart/internal/outbox"
+	"gophermart/internal/pagination"
 	"gophermart/internal/repository"
+	"gophermart/internal/tiers"
+	"gophermart/internal/webhooks"
+	"log/slog"
+	"sort"
+	"time"
 )
 
 type OrderService struct {
-	OrderRepository *repository.OrderRepository
+	OrderRepository       *repository.OrderRepository
+	UserBalanceRepository *repository.UserBalanceRepository
+	OutboxRepository      *repository.OutboxRepository
+	Ledger                *Ledger
+	Tiers                 *TierService
+	Campaigns             *CampaignService
+	Referrals             *ReferralService
+	// RevisionWindow is how long orders in a final status keep being polled
+	// for revised accruals, every RevisionInterval; zero disables it.
+	RevisionWindow   time.Duration
+	RevisionInterval time.Duration
 }
 
-func (or *OrderService) GetOrderID(orderNumber string, userID int) (int, error) {
-	return or.OrderRepository.GetOrderID(orderNumber, userID)
+func (or *OrderService) GetOrderID(ctx context.Context, orderNumber string, userID int) (int, error) {
+	return or.OrderRepository.GetOrderID(ctx, orderNumber, userID)
 }
 
-func (or *OrderService) SaveOrder(orderNumber string, userID int) error {
-	return or.OrderRepository.SaveOrder(orderNumber, userID)
+func (or *OrderService) SaveOrder(ctx context.Context, orderNumber string, userID int) error {
+	return or.OrderRepository.SaveOrder(ctx, orderNumber, userID)
 }
 
-func (or *OrderService) UpdateOrder(orderNumber string, accrual decimal.Decimal, status string) error {
-	return or.OrderRepository.UpdateOrder(orderNumber, accrual, status)
+func (or *OrderService) UpdateOrder(ctx context.Context, orderNumber string, accrual decimal.Decimal, status string) error {
+	err := or.OrderRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		_, userID, _, err := or.OrderRepository.LockOrder(ctx, orderNumber)
+
+		if err != nil {
+			return err
+		}
+
+		if err := or.OrderRepository.UpdateOrder(ctx, orderNumber, accrual, status); err != nil {
+			return err
+		}
+
+		event := outbox.OrderUpdated{Number: orderNumber, Status: status, Accrual: accrual}
+
+		return appendEvent(ctx, or.OutboxRepository, userID, outbox.TypeOrderUpdated, event)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Order updated", "order", orderNumber, "status", status, "accrual", accrual)
+
+	return nil
+}
+
+// SaveOrders uploads a batch of numbers in one transaction. The result holds,
+// for every number, 0 when it was saved or the GetOrderID result otherwise.
+func (or *OrderService) SaveOrders(ctx context.Context, orderNumbers []string, userID int) ([]int, error) {
+	results := make([]int, len(orderNumbers))
+
+	locked := append([]string(nil), orderNumbers...)
+	sort.Strings(locked)
+
+	err := or.OrderRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		// Locks are taken in sorted order so that overlapping batches cannot
+		// deadlock.
+		for i, orderNumber := range locked {
+			if i > 0 && locked[i-1] == orderNumber {
+				continue
+			}
+
+			if err := or.OrderRepository.LockOrderNumber(ctx, orderNumber); err != nil {
+				return err
+			}
+		}
+
+		for i, orderNumber := range orderNumbers {
+			result, err := or.OrderRepository.GetOrderID(ctx, orderNumber, userID)
+
+			if err != nil {
+				return err
+			}
+
+			if result == 0 {
+				if err := or.OrderRepository.SaveOrder(ctx, orderNumber, userID); err != nil {
+					return err
+				}
+			}
+
+			results[i] = result
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
 }
 
-func (or *OrderService) GetUserOrders(userID int) ([]interfaces.OrderData, error) {
-	return or.OrderRepository.GetUserOrders(userID)
+func (or *OrderService) GetUserOrders(ctx context.Context, userID int) ([]interfaces.OrderData, error) {
+	return or.OrderRepository.GetUserOrders(ctx, userID)
+}
+
+func (or *OrderService) GetUserOrdersPage(ctx context.Context, userID int, filter interfaces.OrderFilter, page pagination.Page) ([]interfaces.OrderData, *pagination.Cursor, error) {
+	return or.OrderRepository.GetUserOrdersPage(ctx, userID, filter, page)
+}
+
+func (or *OrderService) MarkPolled(ctx context.Context, orderNumber string) error {
+	return or.OrderRepository.MarkPolled(ctx, orderNumber)
+}
+
+func (or *OrderService) GetUserOrder(ctx context.Context, userID int, orderNumber string) (interfaces.OrderDetail, error) {
+	order, err := or.OrderRepository.GetUserOrder(ctx, userID, orderNumber)
+
+	if err != nil {
+		return order, err
+	}
+
+	if order.History, err = or.OrderRepository.GetOrderStatusHistory(ctx, orderNumber); err != nil {
+		return order, err
+	}
+
+	order.Revisions, err = or.OrderRepository.GetRevisions(ctx, orderNumber)
+
+	return order, err
 }
 
 func (or *OrderService) GetOrderRepository() interfaces.OrderRepositoryInterface {
 	return or.OrderRepository
 }
+
+// GetPendingOrders returns orders still being processed and, when there is
+// room left, final orders due for a revision check.
+func (or *OrderService) GetPendingOrders(ctx context.Context, limit int) ([]interfaces.PendingOrder, error) {
+	orders, err := or.OrderRepository.GetPendingOrders(ctx, limit)
+
+	if err != nil || or.RevisionWindow <= 0 || len(orders) >= limit {
+		return orders, err
+	}
+
+	now := time.Now()
+	revisable, err := or.OrderRepository.GetRevisableOrders(ctx, now.Add(-or.RevisionWindow), now.Add(-or.RevisionInterval), limit-len(orders))
+
+	return append(orders, revisable...), err
+}
+
+// ApplyAccrual stores the status reported by the accrual system and credits
+// the balance in one transaction. It returns the change of the order's
+// accrual. Orders that already reached a final status are only changed by a
+// revision, so repeated polls never credit twice.
+func (or *OrderService) ApplyAccrual(ctx context.Context, orderNumber string, status string, accrual decimal.Decimal) (decimal.Decimal, error) {
+	delta := decimal.Zero
+
+	err := or.OrderRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		currentStatus, userID, previous, err := or.OrderRepository.LockOrder(ctx, orderNumber)
+
+		if err != nil {
+			return err
+		}
+
+		if currentStatus == repository.PROCESSED || currentStatus == repository.INVALID {
+			delta, err = or.reviseAccrual(ctx, orderNumber, userID, currentStatus, previous, status, accrual)
+
+			return err
+		}
+
+		if currentStatus == status {
+			return nil
+		}
+
+		if status != repository.PROCESSED {
+			accrual = decimal.Zero
+		}
+
+		if err := or.UpdateOrder(ctx, orderNumber, accrual, status); err != nil {
+			return err
+		}
+
+		value, _ := accrual.Float64()
+		orderEvent := events.OrderData{Number: orderNumber, Status: status, Accrual: float32(value)}
+
+		if err := events.Publish(ctx, or.OrderRepository.DBStorage, events.TypeOrder, userID, orderEvent); err != nil {
+			return err
+		}
+
+		switch status {
+		case repository.PROCESSED:
+			err = appendEvent(ctx, or.OutboxRepository, userID, webhooks.EventOrderProcessed,
+				webhooks.OrderData{UserID: userID, Number: orderNumber, Accrual: float32(value)})
+		case repository.INVALID:
+			err = appendEvent(ctx, or.OutboxRepository, userID, webhooks.EventOrderInvalid,
+				webhooks.OrderData{UserID: userID, Number: orderNumber})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if status == repository.PROCESSED && accrual.IsPositive() {
+			delta = accrual
+			credited, err := or.creditAccrual(ctx, accrual, userID, orderNumber)
+
+			if err != nil {
+				return err
+			}
+
+			slog.InfoContext(ctx, "User balance credited", "order", orderNumber, "accrual", accrual, "credited", credited)
+
+			value, _ := credited.Float64()
+
+			return or.publishBalanceChange(ctx, userID, orderNumber, float32(value), "accrual")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return delta, nil
+}
+
+// reviseAccrual applies a new accrual reported for an order in a final status
+// as a delta: a higher accrual is credited, a lower one or INVALID is taken
+// back by Ledger.Reduce. The bonuses granted for the order follow the accrual
+// through reviseBonuses. An order that turns from INVALID to PROCESSED before
+// anything was credited for it is credited by creditAccrual instead, with the
+// bonuses a processed order earns. Every revision is recorded in
+// accrual_revisions.
+func (or *OrderService) reviseAccrual(ctx context.Context, orderNumber string, userID int, currentStatus string, previous decimal.Decimal, status string, accrual decimal.Decimal) (decimal.Decimal, error) {
+	if status != repository.PROCESSED && status != repository.INVALID {
+		return decimal.Zero, nil
+	}
+
+	if status != repository.PROCESSED {
+		accrual = decimal.Zero
+	}
+
+	if currentStatus != repository.PROCESSED {
+		previous = decimal.Zero
+	}
+
+	if status == currentStatus && accrual.Equal(previous) {
+		return decimal.Zero, nil
+	}
+
+	if err := or.UpdateOrder(ctx, orderNumber, accrual, status); err != nil {
+		return decimal.Zero, err
+	}
+
+	delta := accrual.Sub(previous)
+	bonusDelta, debt := decimal.Zero, decimal.Zero
+	first, err := or.firstCredit(ctx, userID, orderNumber, currentStatus, status, accrual)
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if first {
+		total, err := or.creditAccrual(ctx, accrual, userID, orderNumber)
+
+		if err != nil {
+			return decimal.Zero, err
+		}
+
+		bonusDelta = total.Sub(accrual)
+	} else {
+		entry := interfaces.LedgerEntry{Kind: repository.LedgerRevision, Amount: delta, OrderNumber: orderNumber}
+
+		if debt, err = or.applyChange(ctx, userID, entry); err != nil {
+			return decimal.Zero, err
+		}
+
+		var bonusDebt decimal.Decimal
+
+		if bonusDelta, bonusDebt, err = or.reviseBonuses(ctx, userID, orderNumber, previous, accrual); err != nil {
+			return decimal.Zero, err
+		}
+
+		debt = debt.Add(bonusDebt)
+	}
+
+	revision := interfaces.AccrualRevision{UserID: userID, OrderNumber: orderNumber, PreviousStatus: currentStatus, Status: status}
+	revision.PreviousAccrual, _ = previous.Float64()
+	revision.Accrual, _ = accrual.Float64()
+	revision.Delta, _ = delta.Float64()
+	revision.BonusDelta, _ = bonusDelta.Float64()
+	revision.Debt, _ = debt.Float64()
+
+	if err := or.OrderRepository.SaveRevision(ctx, revision); err != nil {
+		return decimal.Zero, err
+	}
+
+	event := outbox.AccrualRevised{
+		Order: orderNumber, Status: status, PreviousAccrual: previous, Accrual: accrual, BonusDelta: bonusDelta, Debt: debt,
+	}
+
+	if err := appendEvent(ctx, or.OutboxRepository, userID, outbox.TypeAccrualRevised, event); err != nil {
+		return decimal.Zero, err
+	}
+
+	orderEvent := events.OrderData{Number: orderNumber, Status: status, Accrual: float32(revision.Accrual)}
+
+	if err := events.Publish(ctx, or.OrderRepository.DBStorage, events.TypeOrder, userID, orderEvent); err != nil {
+		return decimal.Zero, err
+	}
+
+	if err := appendEvent(ctx, or.OutboxRepository, userID, webhooks.EventOrderRevised, webhooks.OrderData{
+		UserID: userID, Number: orderNumber, Accrual: float32(revision.Accrual), Status: status,
+		PreviousAccrual: float32(revision.PreviousAccrual),
+	}); err != nil {
+		return decimal.Zero, err
+	}
+
+	slog.InfoContext(ctx, "Accrual revised", "order", orderNumber, "previous_status", currentStatus, "status", status,
+		"previous_accrual", previous, "accrual", accrual, "bonus_delta", bonusDelta, "debt", debt)
+
+	change := delta.Add(bonusDelta)
+
+	if change.IsZero() {
+		return delta, nil
+	}
+
+	value, _ := change.Float64()
+
+	return delta, or.publishBalanceChange(ctx, userID, orderNumber, float32(value), "revision")
+}
+
+// firstCredit reports whether a revision is the first to credit an order: it
+// turns from INVALID to PROCESSED with an accrual, and nothing was credited
+// for the order before.
+func (or *OrderService) firstCredit(ctx context.Context, userID int, orderNumber string, currentStatus string, status string, accrual decimal.Decimal) (bool, error) {
+	if currentStatus != repository.INVALID || status != repository.PROCESSED || !accrual.IsPositive() {
+		return false, nil
+	}
+
+	grants, err := or.Ledger.LedgerRepository.OrderGrants(ctx, userID, orderNumber)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, grant := range grants {
+		if grant.Kind == repository.LedgerAccrual {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// reviseBonuses scales the bonuses granted for an order with its accrual.
+// A bonus is worth grant * accrual / granted accrual, where grant and granted
+// accrual were credited when the order was processed, so INVALID takes it
+// back in full and a return to the granted accrual restores it exactly. It
+// returns the change of the balance and the part of it recorded as debt.
+func (or *OrderService) reviseBonuses(ctx context.Context, userID int, orderNumber string, previous decimal.Decimal, accrual decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	grants, err := or.Ledger.LedgerRepository.OrderGrants(ctx, userID, orderNumber)
+
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	granted := decimal.Zero
+
+	for _, grant := range grants {
+		if grant.Kind == repository.LedgerAccrual {
+			granted = grant.Amount
+		}
+	}
+
+	if !granted.IsPositive() {
+		return decimal.Zero, decimal.Zero, nil
+	}
+
+	total, debt := decimal.Zero, decimal.Zero
+
+	for _, grant := range grants {
+		if grant.Kind == repository.LedgerAccrual {
+			continue
+		}
+
+		change := grant.Amount.Mul(accrual).Div(granted).Round(2).Sub(grant.Amount.Mul(previous).Div(granted).Round(2))
+
+		if change.IsZero() {
+			continue
+		}
+
+		entry := interfaces.LedgerEntry{
+			Kind: repository.LedgerBonusRevision, Amount: change, OrderNumber: orderNumber, CampaignID: grant.CampaignID,
+		}
+		uncovered, err := or.applyChange(ctx, userID, entry)
+
+		if err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+
+		slog.InfoContext(ctx, "Bonus revised", "order", orderNumber, "kind", grant.Kind, "campaign", grant.CampaignID, "change", change)
+
+		total = total.Add(change)
+		debt = debt.Add(uncovered)
+	}
+
+	return total, debt, nil
+}
+
+// applyChange credits an entry with a positive amount and reduces the balance
+// by one with a negative amount. It returns the part of a reduction recorded
+// as debt.
+func (or *OrderService) applyChange(ctx context.Context, userID int, entry interfaces.LedgerEntry) (decimal.Decimal, error) {
+	switch {
+	case entry.Amount.IsPositive():
+		return decimal.Zero, or.Ledger.Credit(ctx, userID, entry)
+	case entry.Amount.IsNegative():
+		entry.Amount = entry.Amount.Neg()
+
+		return or.Ledger.Reduce(ctx, userID, entry)
+	}
+
+	return decimal.Zero, nil
+}
+
+func (or *OrderService) publishBalanceChange(ctx context.Context, userID int, orderNumber string, delta float32, reason string) error {
+	balance, err := publishBalance(ctx, or.UserBalanceRepository, userID)
+
+	if err != nil {
+		return err
+	}
+
+	return appendEvent(ctx, or.OutboxRepository, userID, webhooks.EventBalanceAdjusted, webhooks.BalanceData{
+		UserID: userID, Delta: delta, Reason: reason, Order: orderNumber,
+		Current: balance.Current, Withdrawn: balance.Withdrawn,
+	})
+}
+
+// creditAccrual credits the accrual of an order and, as separate ledger lines,
+// the bonuses it earns. It returns the total credited.
+func (or *OrderService) creditAccrual(ctx context.Context, accrual decimal.Decimal, userID int, orderNumber string) (decimal.Decimal, error) {
+	if or.Referrals != nil {
+		if err := or.Referrals.LockBalances(ctx, userID); err != nil {
+			return decimal.Zero, err
+		}
+	}
+
+	entry := interfaces.LedgerEntry{Kind: repository.LedgerAccrual, Amount: accrual, OrderNumber: orderNumber}
+
+	if err := or.Ledger.Credit(ctx, userID, entry); err != nil {
+		return decimal.Zero, err
+	}
+
+	total := accrual
+	var tier tiers.Tier
+
+	if or.Tiers != nil {
+		var err error
+
+		if tier, err = or.Tiers.Tier(ctx, userID); err != nil {
+			return decimal.Zero, err
+		}
+
+		if bonus := tier.Bonus(accrual); bonus.IsPositive() {
+			entry := interfaces.LedgerEntry{Kind: repository.LedgerTierBonus, Amount: bonus, OrderNumber: orderNumber}
+
+			if err := or.Ledger.Credit(ctx, userID, entry); err != nil {
+				return decimal.Zero, err
+			}
+
+			total = total.Add(bonus)
+		}
+	}
+
+	if or.Campaigns == nil && or.Referrals == nil {
+		return total, nil
+	}
+
+	first, err := or.OrderRepository.IsFirstOrder(ctx, userID, orderNumber)
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if or.Referrals != nil && first {
+		bonus, err := or.Referrals.Reward(ctx, userID, orderNumber, accrual)
+
+		if err != nil {
+			return decimal.Zero, err
+		}
+
+		total = total.Add(bonus)
+	}
+
+	if or.Campaigns == nil {
+		return total, nil
+	}
+
+	bonuses, err := or.Campaigns.Bonuses(ctx, campaigns.Order{
+		Accrual: accrual, FirstOrder: first, Tier: tier.Name, ProcessedAt: time.Now(),
+	})
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	for _, bonus := range bonuses {
+		campaignID := bonus.CampaignID
+		entry := interfaces.LedgerEntry{
+			Kind: repository.LedgerCampaignBonus, Amount: bonus.Amount, OrderNumber: orderNumber, CampaignID: &campaignID,
+		}
+
+		if err := or.Ledger.Credit(ctx, userID, entry); err != nil {
+			return decimal.Zero, err
+		}
+
+		slog.InfoContext(ctx, "Campaign bonus credited", "order", orderNumber, "campaign", bonus.CampaignID, "amount", bonus.Amount)
+
+		total = total.Add(bonus.Amount)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/repository"
+	"gophermart/internal/tiers"
+	"gophermart/storage/storagetest"
+	"testing"
+)
+
+func TestOrderService_ReviseAccrual(t *testing.T) {
+	l, pgs := newTestLedger(t, DebtPolicyDebt)
+	ctx := context.Background()
+	userID := storagetest.CreateUser(t, pgs, "user")
+	or := &OrderService{
+		OrderRepository:       &repository.OrderRepository{DBStorage: pgs},
+		UserBalanceRepository: l.UserBalanceRepository,
+		OutboxRepository:      l.OutboxRepository,
+		Ledger:                l,
+	}
+
+	const order = "12345678903"
+
+	if err := or.SaveOrder(ctx, order, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := or.ApplyAccrual(ctx, order, repository.PROCESSED, decimal.NewFromInt(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A bonus of a tenth of the accrual, granted with it, follows revisions.
+	credit(t, l, userID, repository.LedgerTierBonus, 10, order)
+
+	// Spending most of the 110 points leaves too little to cover reductions.
+	if result, err := newTestWithdrawService(l, pgs).Withdraw(ctx, userID, "2377225624", decimal.NewFromInt(80)); err != nil || result != 0 {
+		t.Fatalf("Withdraw() = %d, %v, want a committed withdrawal", result, err)
+	}
+
+	// The steps run in order against the same order.
+	tests := []struct {
+		name        string
+		status      string
+		accrual     int64
+		wantDelta   int64
+		wantCurrent float32
+		wantDebt    float32
+	}{
+		{name: "lowered", status: repository.PROCESSED, accrual: 50, wantDelta: -50, wantCurrent: 0, wantDebt: 25},
+		{name: "invalid", status: repository.INVALID, accrual: 30, wantDelta: -50, wantCurrent: 0, wantDebt: 80},
+		{name: "processed again", status: repository.PROCESSED, accrual: 100, wantDelta: 100, wantCurrent: 30, wantDebt: 0},
+		{name: "unchanged", status: repository.PROCESSED, accrual: 100, wantDelta: 0, wantCurrent: 30, wantDebt: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, err := or.ApplyAccrual(ctx, order, tt.status, decimal.NewFromInt(tt.accrual))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !delta.Equal(decimal.NewFromInt(tt.wantDelta)) {
+				t.Fatalf("Expected delta %d, got %s", tt.wantDelta, delta)
+			}
+
+			if balance := balanceOf(t, l, userID); balance.Current != tt.wantCurrent || balance.Debt != tt.wantDebt {
+				t.Fatalf("Expected current %v and debt %v, got %+v", tt.wantCurrent, tt.wantDebt, balance)
+			}
+		})
+	}
+
+	revisions, err := or.OrderRepository.GetRevisions(ctx, order)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantBonusDeltas := []float64{-5, -5, 10}
+
+	if len(revisions) != len(wantBonusDeltas) {
+		t.Fatalf("Expected %d revisions, got %+v", len(wantBonusDeltas), revisions)
+	}
+
+	for i, revision := range revisions {
+		if revision.BonusDelta != wantBonusDeltas[i] {
+			t.Errorf("Expected revision %d to change bonuses by %v, got %v", i, wantBonusDeltas[i], revision.BonusDelta)
+		}
+	}
+}
+
+func TestOrderService_ReviseInvalidToProcessed(t *testing.T) {
+	l, pgs := newTestLedger(t, DebtPolicyDebt)
+	ctx := context.Background()
+	userID := storagetest.CreateUser(t, pgs, "user")
+	policy, err := tiers.Parse([]string{"base:0:0:1.1"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	or := &OrderService{
+		OrderRepository:       &repository.OrderRepository{DBStorage: pgs},
+		UserBalanceRepository: l.UserBalanceRepository,
+		OutboxRepository:      l.OutboxRepository,
+		Ledger:                l,
+		Tiers:                 &TierService{TierRepository: &repository.TierRepository{DBStorage: pgs}, Policy: policy},
+	}
+
+	const order = "12345678903"
+
+	if err := or.SaveOrder(ctx, order, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The steps run in order against the same order.
+	tests := []struct {
+		name        string
+		status      string
+		accrual     int64
+		wantDelta   int64
+		wantCurrent float32
+	}{
+		{name: "invalid", status: repository.INVALID, accrual: 0, wantDelta: 0, wantCurrent: 0},
+		{name: "processed with the tier bonus", status: repository.PROCESSED, accrual: 100, wantDelta: 100, wantCurrent: 110},
+		{name: "lowered with the bonus", status: repository.PROCESSED, accrual: 50, wantDelta: -50, wantCurrent: 55},
+		{name: "invalid again", status: repository.INVALID, accrual: 0, wantDelta: -50, wantCurrent: 0},
+		{name: "restored, not credited twice", status: repository.PROCESSED, accrual: 100, wantDelta: 100, wantCurrent: 110},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, err := or.ApplyAccrual(ctx, order, tt.status, decimal.NewFromInt(tt.accrual))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !delta.Equal(decimal.NewFromInt(tt.wantDelta)) {
+				t.Fatalf("Expected delta %d, got %s", tt.wantDelta, delta)
+			}
+
+			if balance := balanceOf(t, l, userID); balance.Current != tt.wantCurrent {
+				t.Fatalf("Expected current %v, got %+v", tt.wantCurrent, balance)
+			}
+		})
+	}
+
+	accrued := queryDecimal(t, pgs, "SELECT COALESCE(SUM(amount), 0) FROM balance_ledger WHERE user_id = $1 AND kind = $2",
+		userID, repository.LedgerAccrual)
+
+	if !accrued.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("Expected one ACCRUAL line of 100, got %s", accrued)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"gophermart/internal/repository"
+	"log/slog"
+	"time"
+)
+
+// appendEvent writes a domain event to the outbox of the current transaction.
+// It is a no-op when the outbox is not wired, as in tests.
+func appendEvent(ctx context.Context, obr *repository.OutboxRepository, userID int, eventType string, data interface{}) error {
+	if obr == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	return obr.Append(ctx, userID, eventType, payload)
+}
+
+// OutboxPruner deletes published outbox events older than the retention.
+type OutboxPruner struct {
+	OutboxRepository *repository.OutboxRepository
+	Retention        time.Duration
+}
+
+func (op *OutboxPruner) Prune(ctx context.Context) error {
+	deleted, err := op.OutboxRepository.DeletePublished(ctx, time.Now().Add(-op.Retention))
+
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Outbox pruned", "deleted", deleted, "retention", op.Retention)
+	}
+
+	return err
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"log/slog"
+)
+
+// deletedUsernamePrefix starts the random usernames of deleted accounts.
+const deletedUsernamePrefix = "deleted-"
+
+// PrivacyService exports and deletes personal data. Deletion anonymizes the
+// account rather than removing it: orders, withdrawals, the ledger and the
+// rest of the financial history are retained under the user's ID, which no
+// longer leads to a person.
+//
+// Points held for the shop are released with the account, so that the shop
+// cannot capture them any more. PENDING withdrawals stay with the shop, which
+// confirms or refunds them by id as usual: the order may already be paid and
+// shipped. A refund goes to the balance of the anonymized account and is
+// forfeited with the rest of it.
+type PrivacyService struct {
+	UserRepository        *repository.UserRepository
+	OrderRepository       *repository.OrderRepository
+	WithdrawRepository    *repository.WithdrawRepository
+	LedgerRepository      *repository.LedgerRepository
+	UserBalanceRepository *repository.UserBalanceRepository
+	Reservations          *ReservationService
+}
+
+func (ps *PrivacyService) Export(ctx context.Context, userID int) (interfaces.DataExport, error) {
+	var export interfaces.DataExport
+
+	err := ps.UserRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if export.Profile, err = ps.UserRepository.GetProfile(ctx, userID); err != nil {
+			return err
+		}
+
+		if export.Profile.Balance, err = ps.UserBalanceRepository.GetUserBalance(ctx, userID); err != nil {
+			return err
+		}
+
+		if export.Orders, err = ps.OrderRepository.GetUserOrders(ctx, userID); err != nil {
+			return err
+		}
+
+		if export.Withdrawals, err = ps.WithdrawRepository.Withdrawals(ctx, userID); err != nil {
+			return err
+		}
+
+		export.Ledger, err = ps.LedgerRepository.Entries(ctx, userID)
+
+		return err
+	})
+
+	return export, err
+}
+
+func (ps *PrivacyService) DeleteAccount(ctx context.Context, userID int) error {
+	suffix := make([]byte, 8)
+
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	err := ps.UserRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		if err := ps.UserRepository.Anonymize(ctx, userID, deletedUsernamePrefix+hex.EncodeToString(suffix)); err != nil {
+			return err
+		}
+
+		// Reservations are made under the balance lock, so none is made
+		// past the list below.
+		if _, err := ps.UserBalanceRepository.LockBalance(ctx, userID); err != nil {
+			return err
+		}
+
+		held, err := ps.Reservations.ReservationRepository.HeldReservations(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		for _, reservation := range held {
+			if _, err := ps.Reservations.release(ctx, reservation.ID, "account deletion"); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Account deleted", "user_id", userID)
+
+	return nil
+}
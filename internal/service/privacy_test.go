@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"gophermart/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestPrivacyService_DeleteAccount(t *testing.T) {
+	l, pgs := newTestLedger(t, DebtPolicyNegative)
+	ctx := context.Background()
+	userID := storagetest.CreateUser(t, pgs, "user")
+	ws := newTestWithdrawService(l, pgs)
+	rs := &ReservationService{
+		ReservationRepository: ws.ReservationRepository,
+		UserBalanceRepository: l.UserBalanceRepository,
+		OutboxRepository:      l.OutboxRepository,
+		Withdrawals:           ws,
+		Ledger:                l,
+		DefaultTTL:            time.Hour,
+		MaxTTL:                time.Hour,
+	}
+	ps := &PrivacyService{
+		UserRepository:        &repository.UserRepository{DBStorage: pgs},
+		OrderRepository:       &repository.OrderRepository{DBStorage: pgs},
+		WithdrawRepository:    ws.WithdrawRepository,
+		LedgerRepository:      l.LedgerRepository,
+		UserBalanceRepository: l.UserBalanceRepository,
+		Reservations:          rs,
+	}
+
+	credit(t, l, userID, repository.LedgerAccrual, 500, "12345678903")
+
+	if _, err := ws.Withdraw(ctx, userID, "2377225624", decimal.NewFromInt(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	reservation, err := rs.Reserve(ctx, userID, interfaces.ReservationRequest{Order: "49927398716", Sum: decimal.NewFromInt(150)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ps.DeleteAccount(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	released, err := rs.ReservationRepository.FindReservation(ctx, reservation.ID, false)
+
+	if err != nil || released.Status != repository.ReservationReleased {
+		t.Fatalf("Expected the reservation to be released, got %s (%v)", released.Status, err)
+	}
+
+	withdrawals, err := ws.WithdrawRepository.Withdrawals(ctx, userID)
+
+	if err != nil || len(withdrawals) != 1 || withdrawals[0].Status != repository.WithdrawalPending {
+		t.Fatalf("Expected the withdrawal to stay pending, got %v (%v)", withdrawals, err)
+	}
+
+	if err := ps.DeleteAccount(ctx, userID); !errors.Is(err, repository.ErrUserDeleted) {
+		t.Fatalf("Expected ErrUserDeleted when deleting again, got %v", err)
+	}
+}
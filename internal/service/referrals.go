@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"log/slog"
+	"strings"
+)
+
+const (
+	referralCodeLength   = 8
+	referralCodeAttempts = 5
+	// Without 0/O and 1/I, so that codes survive being read out loud.
+	referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var ErrInvalidReferralCode = errors.New("invalid referral code")
+
+// ReferralService pays a bonus to both users when an invited user's first
+// order is processed. Only the direct referrer is paid, and only if they have
+// a processed order of their own and are below MaxInvites, so that chains of
+// fresh accounts cannot farm bonuses from each other.
+type ReferralService struct {
+	ReferralRepository *repository.ReferralRepository
+	Ledger             *Ledger
+	ReferrerBonus      decimal.Decimal
+	RefereeBonus       decimal.Decimal
+	// MinAccrual is the accrual the first order must earn, 0 for any.
+	MinAccrual decimal.Decimal
+	// MaxInvites caps the paid invitations per referrer, 0 for no cap.
+	MaxInvites int
+}
+
+// GetReferrals assigns a code on first use, which also covers users that
+// registered before the program existed.
+func (rs *ReferralService) GetReferrals(ctx context.Context, userID int) (interfaces.Referrals, error) {
+	code, err := rs.code(ctx, userID)
+
+	if err != nil {
+		return interfaces.Referrals{}, err
+	}
+
+	invitees, err := rs.ReferralRepository.ListInvitees(ctx, userID)
+
+	if err != nil {
+		return interfaces.Referrals{}, err
+	}
+
+	earned := decimal.Zero
+
+	for _, invitee := range invitees {
+		earned = earned.Add(decimal.NewFromFloat(invitee.Bonus))
+	}
+
+	total, _ := earned.Float64()
+
+	return interfaces.Referrals{Code: code, Earned: total, Invitees: invitees}, nil
+}
+
+func (rs *ReferralService) code(ctx context.Context, userID int) (string, error) {
+	for i := 0; i < referralCodeAttempts; i++ {
+		code, err := rs.ReferralRepository.GetReferralCode(ctx, userID)
+
+		if err != nil || code != "" {
+			return code, err
+		}
+
+		if code, err = newReferralCode(); err != nil {
+			return "", err
+		}
+
+		if _, err := rs.ReferralRepository.AssignReferralCode(ctx, userID, code); err != nil {
+			return "", err
+		}
+	}
+
+	return "", errors.New("failed to assign a unique referral code")
+}
+
+// Link records that a new user registered with a referral code.
+func (rs *ReferralService) Link(ctx context.Context, code string, refereeID int) error {
+	referrerID, err := rs.ReferrerID(ctx, code)
+
+	if err != nil {
+		return err
+	}
+
+	if referrerID == refereeID {
+		return ErrInvalidReferralCode
+	}
+
+	if err := rs.ReferralRepository.CreateReferral(ctx, referrerID, refereeID); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Referral registered", "referrer_id", referrerID, "referee_id", refereeID)
+
+	return nil
+}
+
+func (rs *ReferralService) ReferrerID(ctx context.Context, code string) (int, error) {
+	referrerID, err := rs.ReferralRepository.GetReferrerID(ctx, strings.ToUpper(strings.TrimSpace(code)))
+
+	if errors.Is(err, repository.ErrReferralCodeNotFound) {
+		return 0, ErrInvalidReferralCode
+	}
+
+	return referrerID, err
+}
+
+// LockBalances locks the balances Reward may credit: the referee's and, while
+// their invitation is pending, the referrer's, lower user id first. The
+// accrual transaction calls it before touching the referee's balance, so that
+// it takes the locks in the same order as transfers.
+func (rs *ReferralService) LockBalances(ctx context.Context, refereeID int) error {
+	referral, found, err := rs.ReferralRepository.LockPendingReferral(ctx, refereeID)
+
+	if err != nil {
+		return err
+	}
+
+	userIDs := []int{refereeID}
+
+	if found {
+		userIDs = append(userIDs, referral.ReferrerID)
+	}
+
+	_, err = lockBalancesInOrder(ctx, rs.Ledger.UserBalanceRepository, userIDs...)
+
+	return err
+}
+
+// Reward settles the invitation of a user whose first order was just
+// processed. It runs in the accrual transaction, after LockBalances. It
+// returns the bonus credited to the referee.
+func (rs *ReferralService) Reward(ctx context.Context, refereeID int, orderNumber string, accrual decimal.Decimal) (decimal.Decimal, error) {
+	referral, found, err := rs.ReferralRepository.LockPendingReferral(ctx, refereeID)
+
+	if err != nil || !found {
+		return decimal.Zero, err
+	}
+
+	reason, err := rs.ineligible(ctx, referral, accrual)
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if reason != "" {
+		slog.InfoContext(ctx, "Referral rejected", "referrer_id", referral.ReferrerID, "referee_id", refereeID, "reason", reason)
+
+		return decimal.Zero, rs.ReferralRepository.CloseReferral(ctx, referral.ID, repository.ReferralRejected, decimal.Zero, decimal.Zero, orderNumber)
+	}
+
+	if rs.RefereeBonus.IsPositive() {
+		entry := interfaces.LedgerEntry{Kind: repository.LedgerReferralBonus, Amount: rs.RefereeBonus, OrderNumber: orderNumber}
+
+		if err := rs.Ledger.Credit(ctx, refereeID, entry); err != nil {
+			return decimal.Zero, err
+		}
+	}
+
+	if rs.ReferrerBonus.IsPositive() {
+		entry := interfaces.LedgerEntry{Kind: repository.LedgerReferralBonus, Amount: rs.ReferrerBonus}
+
+		if err := rs.Ledger.Credit(ctx, referral.ReferrerID, entry); err != nil {
+			return decimal.Zero, err
+		}
+
+		if _, err := publishBalance(ctx, rs.Ledger.UserBalanceRepository, referral.ReferrerID); err != nil {
+			return decimal.Zero, err
+		}
+	}
+
+	err = rs.ReferralRepository.CloseReferral(ctx, referral.ID, repository.ReferralRewarded, rs.ReferrerBonus, rs.RefereeBonus, orderNumber)
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	slog.InfoContext(ctx, "Referral rewarded", "referrer_id", referral.ReferrerID, "referee_id", refereeID, "order", orderNumber)
+
+	return rs.RefereeBonus, nil
+}
+
+func (rs *ReferralService) ineligible(ctx context.Context, referral interfaces.Referral, accrual decimal.Decimal) (string, error) {
+	if accrual.LessThan(rs.MinAccrual) {
+		return "accrual below minimum", nil
+	}
+
+	active, err := rs.ReferralRepository.HasProcessedOrder(ctx, referral.ReferrerID)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !active {
+		return "referrer has no processed orders", nil
+	}
+
+	if rs.MaxInvites == 0 {
+		return "", nil
+	}
+
+	rewarded, err := rs.ReferralRepository.CountRewarded(ctx, referral.ReferrerID)
+
+	if err != nil {
+		return "", err
+	}
+
+	if rewarded >= rs.MaxInvites {
+		return "referrer reached the invitation limit", nil
+	}
+
+	return "", nil
+}
+
+func newReferralCode() (string, error) {
+	b := make([]byte, referralCodeLength)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = referralCodeAlphabet[int(b[i])%len(referralCodeAlphabet)]
+	}
+
+	return string(b), nil
+}
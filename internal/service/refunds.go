@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/events"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/metrics"
+	"gophermart/internal/repository"
+	"gophermart/internal/webhooks"
+	"log/slog"
+)
+
+// withdrawalActorUser is the audit actor of withdrawals made by their owner.
+const withdrawalActorUser = "user"
+
+var (
+	ErrInvalidRefund   = errors.New("invalid refund")
+	ErrWithdrawalState = errors.New("withdrawal is not in a state that allows this")
+)
+
+// ConfirmWithdrawal marks a pending withdrawal as final on the shop's side.
+// It can still be refunded afterwards.
+func (ws *WithdrawService) ConfirmWithdrawal(ctx context.Context, id int64, actor string) (interfaces.Withdrawal, error) {
+	wr := ws.WithdrawRepository
+	var withdrawal interfaces.Withdrawal
+
+	err := wr.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if withdrawal, err = wr.FindWithdrawal(ctx, id, true); err != nil {
+			return err
+		}
+
+		if withdrawal.Status != repository.WithdrawalPending {
+			return fmt.Errorf("%w: withdrawal is %s", ErrWithdrawalState, withdrawal.Status)
+		}
+
+		withdrawal.Status = repository.WithdrawalConfirmed
+		refunded := decimal.NewFromFloat(withdrawal.Refunded)
+
+		if err := wr.UpdateWithdrawalStatus(ctx, withdrawal.ID, withdrawal.Status, refunded); err != nil {
+			return err
+		}
+
+		if err := wr.RecordWithdrawalEvent(ctx, withdrawal.ID, repository.WithdrawalActionConfirmed, decimal.Zero, actor, ""); err != nil {
+			return err
+		}
+
+		return ws.publishWithdrawal(ctx, withdrawal)
+	})
+
+	if err != nil {
+		return withdrawal, err
+	}
+
+	slog.InfoContext(ctx, "Withdrawal confirmed", "withdrawal", id, "order", withdrawal.OrderNumber, "actor", actor)
+
+	return withdrawal, nil
+}
+
+// RefundWithdrawal returns points of a withdrawal to the user: current grows
+// and withdrawn shrinks by the same amount in one transaction. The balance
+// is locked before the withdrawal row, in the same order as in Withdraw.
+//
+// Refunded points expire with the earliest lot the withdrawal took points
+// from, so a refund does not renew their lifetime. Withdrawals that did not
+// record it get the lifetime of a new credit.
+func (ws *WithdrawService) RefundWithdrawal(ctx context.Context, id int64, amount decimal.Decimal, reason string, actor string) (interfaces.Withdrawal, error) {
+	if amount.IsNegative() || !amount.Equal(amount.Round(2)) {
+		return interfaces.Withdrawal{}, fmt.Errorf("%w: amount must not be negative and have at most two decimal places", ErrInvalidRefund)
+	}
+
+	wr := ws.WithdrawRepository
+	var withdrawal interfaces.Withdrawal
+
+	err := wr.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		found, err := wr.FindWithdrawal(ctx, id, false)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := ws.UserBalanceRepository.LockBalance(ctx, found.UserID); err != nil {
+			return err
+		}
+
+		if withdrawal, err = wr.FindWithdrawal(ctx, id, true); err != nil {
+			return err
+		}
+
+		if withdrawal.Status == repository.WithdrawalRefunded {
+			return fmt.Errorf("%w: withdrawal is already refunded", ErrWithdrawalState)
+		}
+
+		sum := decimal.NewFromFloat(withdrawal.Sum)
+		refunded := decimal.NewFromFloat(withdrawal.Refunded)
+		refundable := sum.Sub(refunded)
+		refund := amount
+
+		if refund.IsZero() {
+			refund = refundable
+		}
+
+		if refund.GreaterThan(refundable) {
+			return fmt.Errorf("%w: at most %s points can be refunded", ErrInvalidRefund, refundable.StringFixed(2))
+		}
+
+		refunded = refunded.Add(refund)
+
+		if refunded.Equal(sum) {
+			withdrawal.Status = repository.WithdrawalRefunded
+		}
+
+		withdrawal.Refunded, _ = refunded.Float64()
+
+		if err := wr.UpdateWithdrawalStatus(ctx, withdrawal.ID, withdrawal.Status, refunded); err != nil {
+			return err
+		}
+
+		if err := wr.ReduceWithdrawn(ctx, withdrawal.UserID, refund); err != nil {
+			return err
+		}
+
+		entry := interfaces.LedgerEntry{Kind: repository.LedgerRefund, Amount: refund, OrderNumber: withdrawal.OrderNumber}
+
+		if withdrawal.LotsExpireAt != nil {
+			err = ws.Ledger.CreditUntil(ctx, withdrawal.UserID, entry, *withdrawal.LotsExpireAt)
+		} else {
+			err = ws.Ledger.Credit(ctx, withdrawal.UserID, entry)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := wr.RecordWithdrawalEvent(ctx, withdrawal.ID, repository.WithdrawalActionRefunded, refund, actor, reason); err != nil {
+			return err
+		}
+
+		if err := ws.publishWithdrawal(ctx, withdrawal); err != nil {
+			return err
+		}
+
+		balance, err := publishBalance(ctx, ws.UserBalanceRepository, withdrawal.UserID)
+
+		if err != nil {
+			return err
+		}
+
+		delta, _ := refund.Float64()
+		wr.DBStorage.AfterCommit(ctx, func() { metrics.PointsRefundedTotal.Add(delta) })
+
+		return appendEvent(ctx, ws.OutboxRepository, withdrawal.UserID, webhooks.EventBalanceAdjusted, webhooks.BalanceData{
+			UserID: withdrawal.UserID, Delta: float32(delta), Reason: "refund", Order: withdrawal.OrderNumber,
+			Current: balance.Current, Withdrawn: balance.Withdrawn,
+		})
+	})
+
+	if err != nil {
+		return withdrawal, err
+	}
+
+	slog.InfoContext(ctx, "Withdrawal refunded", "withdrawal", id, "order", withdrawal.OrderNumber, "refunded", withdrawal.Refunded,
+		"status", withdrawal.Status, "actor", actor)
+
+	return withdrawal, nil
+}
+
+func (ws *WithdrawService) WithdrawalHistory(ctx context.Context, id int64) (interfaces.Withdrawal, []interfaces.WithdrawalEvent, error) {
+	withdrawal, err := ws.WithdrawRepository.FindWithdrawal(ctx, id, false)
+
+	if err != nil {
+		return withdrawal, nil, err
+	}
+
+	history, err := ws.WithdrawRepository.WithdrawalHistory(ctx, withdrawal.ID)
+
+	return withdrawal, history, err
+}
+
+// publishWithdrawal emits the new state of a withdrawal to the user's event
+// stream and the outbox, which also delivers it to webhooks.
+func (ws *WithdrawService) publishWithdrawal(ctx context.Context, withdrawal interfaces.Withdrawal) error {
+	data := events.WithdrawalData{
+		Order: withdrawal.OrderNumber, Sum: float32(withdrawal.Sum), Status: withdrawal.Status, Refunded: float32(withdrawal.Refunded),
+	}
+
+	if err := events.Publish(ctx, ws.WithdrawRepository.DBStorage, events.TypeWithdrawal, withdrawal.UserID, data); err != nil {
+		return err
+	}
+
+	return appendEvent(ctx, ws.OutboxRepository, withdrawal.UserID, webhooks.EventWithdrawalUpdated, webhooks.WithdrawalData{
+		ID: withdrawal.ID, UserID: withdrawal.UserID, Order: withdrawal.OrderNumber, Sum: float32(withdrawal.Sum),
+		Status: withdrawal.Status, Refunded: float32(withdrawal.Refunded),
+	})
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/repository"
+	"gophermart/storage"
+	"gophermart/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func newTestWithdrawService(l *Ledger, pgs *storage.PgStorage) *WithdrawService {
+	return &WithdrawService{
+		WithdrawRepository:    &repository.WithdrawRepository{DBStorage: pgs},
+		UserBalanceRepository: l.UserBalanceRepository,
+		OutboxRepository:      l.OutboxRepository,
+		ReservationRepository: &repository.ReservationRepository{DBStorage: pgs},
+		Ledger:                l,
+	}
+}
+
+func TestWithdrawService_RefundWithdrawal(t *testing.T) {
+	l, pgs := newTestLedger(t, DebtPolicyNegative)
+	ctx := context.Background()
+	userID := storagetest.CreateUser(t, pgs, "user")
+	ws := newTestWithdrawService(l, pgs)
+
+	const order = "2377225624"
+
+	credit(t, l, userID, repository.LedgerAccrual, 500, "12345678903")
+
+	if result, err := ws.Withdraw(ctx, userID, order, decimal.NewFromInt(200)); err != nil || result != 0 {
+		t.Fatalf("Withdraw() = %d, %v, want a committed withdrawal", result, err)
+	}
+
+	withdrawals, err := ws.WithdrawRepository.Withdrawals(ctx, userID)
+
+	if err != nil || len(withdrawals) != 1 {
+		t.Fatalf("Expected one withdrawal, got %v (%v)", withdrawals, err)
+	}
+
+	id := withdrawals[0].ID
+
+	// The steps run in order against the same withdrawal.
+	tests := []struct {
+		name          string
+		amount        int64
+		wantErr       error
+		wantStatus    string
+		wantRefunded  float64
+		wantCurrent   float32
+		wantWithdrawn float32
+	}{
+		{name: "partial", amount: 50, wantStatus: repository.WithdrawalPending, wantRefunded: 50, wantCurrent: 350, wantWithdrawn: 150},
+		{name: "more than is left", amount: 200, wantErr: ErrInvalidRefund, wantStatus: repository.WithdrawalPending, wantRefunded: 50, wantCurrent: 350, wantWithdrawn: 150},
+		{name: "the rest", amount: 0, wantStatus: repository.WithdrawalRefunded, wantRefunded: 200, wantCurrent: 500, wantWithdrawn: 0},
+		{name: "already refunded", amount: 0, wantErr: ErrWithdrawalState, wantStatus: repository.WithdrawalRefunded, wantRefunded: 200, wantCurrent: 500, wantWithdrawn: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ws.RefundWithdrawal(ctx, id, decimal.NewFromInt(tt.amount), "test", "admin")
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			withdrawal, err := ws.WithdrawRepository.FindWithdrawal(ctx, id, false)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if withdrawal.Status != tt.wantStatus || withdrawal.Refunded != tt.wantRefunded {
+				t.Fatalf("Expected status %s with %v refunded, got %s with %v", tt.wantStatus, tt.wantRefunded, withdrawal.Status, withdrawal.Refunded)
+			}
+
+			if balance := balanceOf(t, l, userID); balance.Current != tt.wantCurrent || balance.Withdrawn != tt.wantWithdrawn {
+				t.Fatalf("Expected current %v and withdrawn %v, got %+v", tt.wantCurrent, tt.wantWithdrawn, balance)
+			}
+		})
+	}
+}
+
+func TestWithdrawService_RefundKeepsLotExpiry(t *testing.T) {
+	l, pgs := newTestLedger(t, DebtPolicyNegative)
+	l.PointsTTL = 24 * time.Hour
+	ctx := context.Background()
+	userID := storagetest.CreateUser(t, pgs, "user")
+	ws := newTestWithdrawService(l, pgs)
+
+	credit(t, l, userID, repository.LedgerAccrual, 500, "12345678903")
+
+	var expiresAt time.Time
+
+	// Sooner than a new lot would expire, so a renewed lifetime shows.
+	query := "UPDATE point_lots SET expires_at = expires_at - interval '23 hours' WHERE user_id = $1 RETURNING expires_at"
+
+	if err := pgs.Conn.QueryRow(ctx, query, userID).Scan(&expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ws.Withdraw(ctx, userID, "2377225624", decimal.NewFromInt(200)); err != nil {
+		t.Fatal(err)
+	}
+
+	withdrawals, err := ws.WithdrawRepository.Withdrawals(ctx, userID)
+
+	if err != nil || len(withdrawals) != 1 {
+		t.Fatalf("Expected one withdrawal, got %v (%v)", withdrawals, err)
+	}
+
+	if _, err := ws.RefundWithdrawal(ctx, withdrawals[0].ID, decimal.Zero, "test", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	var refunded time.Time
+
+	query = "SELECT expires_at FROM point_lots WHERE user_id = $1 ORDER BY id DESC LIMIT 1"
+
+	if err := pgs.Conn.QueryRow(ctx, query, userID).Scan(&refunded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !refunded.Equal(expiresAt) {
+		t.Fatalf("Expected the refunded lot to expire at %v, got %v", expiresAt, refunded)
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/outbox"
+	"gophermart/internal/repository"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrInvalidReservation = errors.New("invalid reservation")
+	ErrReservationState   = errors.New("reservation is no longer held")
+)
+
+// ReservationService holds points for checkouts. Held points stay in current
+// but cannot be withdrawn or transferred until the hold is captured, released
+// or expires. Every change locks the balance of the user before the
+// reservation, in the same order as withdrawals.
+type ReservationService struct {
+	ReservationRepository *repository.ReservationRepository
+	UserBalanceRepository *repository.UserBalanceRepository
+	OutboxRepository      *repository.OutboxRepository
+	Withdrawals           *WithdrawService
+	Ledger                *Ledger
+	DefaultTTL            time.Duration
+	MaxTTL                time.Duration
+}
+
+func (rs *ReservationService) Reserve(ctx context.Context, userID int, request interfaces.ReservationRequest) (interfaces.Reservation, error) {
+	ttl := time.Duration(request.TTL) * time.Second
+
+	if ttl == 0 {
+		ttl = rs.DefaultTTL
+	}
+
+	if request.Order == "" {
+		return interfaces.Reservation{}, fmt.Errorf("%w: order is required", ErrInvalidReservation)
+	}
+
+	if !request.Sum.IsPositive() || !request.Sum.Equal(request.Sum.Round(2)) {
+		return interfaces.Reservation{}, fmt.Errorf("%w: sum must be positive with at most two decimal places", ErrInvalidReservation)
+	}
+
+	if ttl <= 0 || ttl > rs.MaxTTL {
+		return interfaces.Reservation{}, fmt.Errorf("%w: ttl must be between 1 and %d seconds", ErrInvalidReservation, int(rs.MaxTTL.Seconds()))
+	}
+
+	var reservation interfaces.Reservation
+	var rejected error
+
+	err := rs.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		available, err := rs.available(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		if available.LessThan(request.Sum) {
+			// Committed anyway, so that the expiry is kept.
+			rejected = ErrNotEnoughPoints
+
+			return nil
+		}
+
+		reservation, err = rs.ReservationRepository.CreateReservation(ctx, userID, request.Order, request.Sum, time.Now().Add(ttl))
+
+		if err != nil {
+			return err
+		}
+
+		return rs.publish(ctx, reservation)
+	})
+
+	if err != nil {
+		return interfaces.Reservation{}, err
+	}
+
+	if rejected != nil {
+		return interfaces.Reservation{}, rejected
+	}
+
+	slog.InfoContext(ctx, "Points reserved", "order", request.Order, "sum", request.Sum, "expires_at", reservation.ExpiresAt)
+
+	return reservation, nil
+}
+
+// Capture withdraws the captured points as a confirmed withdrawal of the
+// reserved order.
+func (rs *ReservationService) Capture(ctx context.Context, id int64, amount decimal.Decimal, actor string) (interfaces.Reservation, error) {
+	if amount.IsNegative() || !amount.Equal(amount.Round(2)) {
+		return interfaces.Reservation{}, fmt.Errorf("%w: amount must not be negative and have at most two decimal places", ErrInvalidReservation)
+	}
+
+	var reservation interfaces.Reservation
+	var rejected error
+
+	err := rs.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if reservation, err = rs.lockHeld(ctx, id); err != nil {
+			return err
+		}
+
+		if !reservation.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("%w: reservation expired at %s", ErrReservationState, reservation.ExpiresAt.Format(time.RFC3339))
+		}
+
+		sum := decimal.NewFromFloat(reservation.Sum)
+		capture := amount
+
+		if capture.IsZero() {
+			capture = sum
+		}
+
+		if capture.GreaterThan(sum) {
+			return fmt.Errorf("%w: at most %s points are reserved", ErrInvalidReservation, sum.StringFixed(2))
+		}
+
+		current, err := rs.current(ctx, reservation.UserID)
+
+		if err != nil {
+			return err
+		}
+
+		available, err := rs.subtractHeld(ctx, reservation.UserID, current)
+
+		if err != nil {
+			return err
+		}
+
+		// The reservation itself is still counted as held.
+		if available.Add(sum).LessThan(capture) {
+			rejected = ErrNotEnoughPoints
+
+			return nil
+		}
+
+		reservation.Status = repository.ReservationCaptured
+		reservation.Captured, _ = capture.Float64()
+
+		if err := rs.ReservationRepository.UpdateReservation(ctx, reservation.ID, reservation.Status, capture); err != nil {
+			return err
+		}
+
+		if err := rs.publish(ctx, reservation); err != nil {
+			return err
+		}
+
+		return rs.Withdrawals.debit(ctx, reservation.UserID, reservation.OrderNumber, capture, current, repository.WithdrawalConfirmed, actor)
+	})
+
+	if err != nil {
+		return interfaces.Reservation{}, err
+	}
+
+	if rejected != nil {
+		return interfaces.Reservation{}, rejected
+	}
+
+	slog.InfoContext(ctx, "Reservation captured", "reservation", id, "order", reservation.OrderNumber,
+		"captured", reservation.Captured, "actor", actor)
+
+	return reservation, nil
+}
+
+func (rs *ReservationService) Release(ctx context.Context, id int64, actor string) (interfaces.Reservation, error) {
+	return rs.release(ctx, id, actor)
+}
+
+// ReleaseOwn releases the hold the user made for an order.
+func (rs *ReservationService) ReleaseOwn(ctx context.Context, userID int, orderNumber string) (interfaces.Reservation, error) {
+	found, err := rs.ReservationRepository.FindUserReservation(ctx, userID, orderNumber)
+
+	if err != nil {
+		return interfaces.Reservation{}, err
+	}
+
+	return rs.release(ctx, found.ID, withdrawalActorUser)
+}
+
+func (rs *ReservationService) Reservations(ctx context.Context, userID int) ([]interfaces.Reservation, error) {
+	return rs.ReservationRepository.Reservations(ctx, userID)
+}
+
+// ExpireReservations is the scheduled sweeper of abandoned holds. Their
+// points are already available once they expire; the sweeper records it and
+// notifies the user.
+func (rs *ReservationService) ExpireReservations(ctx context.Context) error {
+	for {
+		due, err := rs.ReservationRepository.DueReservations(ctx, time.Now(), expiryBatchSize)
+
+		if err != nil {
+			return err
+		}
+
+		for _, reservation := range due {
+			err := rs.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+				locked, err := rs.lockHeld(ctx, reservation.ID)
+
+				if errors.Is(err, ErrReservationState) {
+					return nil
+				}
+
+				if err != nil {
+					return err
+				}
+
+				locked.Status = repository.ReservationExpired
+
+				if err := rs.ReservationRepository.UpdateReservation(ctx, locked.ID, locked.Status, decimal.Zero); err != nil {
+					return err
+				}
+
+				return rs.publish(ctx, locked)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			slog.InfoContext(ctx, "Reservation expired", "order", reservation.OrderNumber, "user_id", reservation.UserID)
+		}
+
+		if len(due) < expiryBatchSize {
+			return nil
+		}
+	}
+}
+
+func (rs *ReservationService) release(ctx context.Context, id int64, actor string) (interfaces.Reservation, error) {
+	var reservation interfaces.Reservation
+
+	err := rs.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if reservation, err = rs.lockHeld(ctx, id); err != nil {
+			return err
+		}
+
+		reservation.Status = repository.ReservationReleased
+
+		if err := rs.ReservationRepository.UpdateReservation(ctx, reservation.ID, reservation.Status, decimal.Zero); err != nil {
+			return err
+		}
+
+		return rs.publish(ctx, reservation)
+	})
+
+	if err != nil {
+		return interfaces.Reservation{}, err
+	}
+
+	slog.InfoContext(ctx, "Reservation released", "reservation", id, "order", reservation.OrderNumber, "actor", actor)
+
+	return reservation, nil
+}
+
+// lockHeld locks the balance of the reservation's user and then the
+// reservation, which must still be HELD.
+func (rs *ReservationService) lockHeld(ctx context.Context, id int64) (interfaces.Reservation, error) {
+	found, err := rs.ReservationRepository.FindReservation(ctx, id, false)
+
+	if err != nil {
+		return found, err
+	}
+
+	if _, err := rs.UserBalanceRepository.LockBalance(ctx, found.UserID); err != nil {
+		return found, err
+	}
+
+	reservation, err := rs.ReservationRepository.FindReservation(ctx, id, true)
+
+	if err != nil {
+		return reservation, err
+	}
+
+	if reservation.Status != repository.ReservationHeld {
+		return reservation, fmt.Errorf("%w: reservation is %s", ErrReservationState, reservation.Status)
+	}
+
+	return reservation, nil
+}
+
+// current locks the balance and returns it without the points that are due
+// to expire.
+func (rs *ReservationService) current(ctx context.Context, userID int) (decimal.Decimal, error) {
+	current, err := rs.UserBalanceRepository.LockBalance(ctx, userID)
+
+	if err != nil {
+		return current, err
+	}
+
+	expired, err := rs.Ledger.ExpireDue(ctx, userID)
+
+	return current.Sub(expired), err
+}
+
+// available is the current balance of a user less the points already held.
+func (rs *ReservationService) available(ctx context.Context, userID int) (decimal.Decimal, error) {
+	current, err := rs.current(ctx, userID)
+
+	if err != nil {
+		return current, err
+	}
+
+	return rs.subtractHeld(ctx, userID, current)
+}
+
+func (rs *ReservationService) subtractHeld(ctx context.Context, userID int, current decimal.Decimal) (decimal.Decimal, error) {
+	held, err := rs.ReservationRepository.HeldTotal(ctx, userID, time.Now())
+
+	return current.Sub(held), err
+}
+
+// publish emits the state of a reservation and the balance it leaves, whose
+// reserved part has changed.
+func (rs *ReservationService) publish(ctx context.Context, reservation interfaces.Reservation) error {
+	event := outbox.ReservationUpdated{
+		Order: reservation.OrderNumber, Status: reservation.Status,
+		Sum: decimal.NewFromFloat(reservation.Sum), Captured: decimal.NewFromFloat(reservation.Captured),
+	}
+
+	if err := appendEvent(ctx, rs.OutboxRepository, reservation.UserID, outbox.TypeReservationUpdated, event); err != nil {
+		return err
+	}
+
+	_, err := publishBalance(ctx, rs.UserBalanceRepository, reservation.UserID)
+
+	return err
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/repository"
+	"time"
+)
+
+// StatementService builds account statements from the ledger. The opening
+// balance is derived from the current one, and the closing balance from the
+// opening one and the lines shown, so a statement always adds up.
+type StatementService struct {
+	LedgerRepository *repository.LedgerRepository
+	UserRepository   *repository.UserRepository
+}
+
+func (ss *StatementService) Statement(ctx context.Context, userID int, from time.Time, to time.Time, w interfaces.StatementWriter) error {
+	username, err := ss.UserRepository.GetUsername(ctx, userID)
+
+	if err != nil {
+		return err
+	}
+
+	opening, err := ss.LedgerRepository.BalanceAt(ctx, userID, from)
+
+	if err != nil {
+		return err
+	}
+
+	if err := w.Begin(interfaces.StatementPeriod{Username: username, From: from, To: to, Opening: opening}); err != nil {
+		return err
+	}
+
+	totals := interfaces.StatementTotals{Credits: decimal.Zero, Debits: decimal.Zero, Closing: opening}
+
+	err = ss.LedgerRepository.StatementLines(ctx, userID, from, to, func(line interfaces.StatementLine) error {
+		if line.Amount != nil {
+			totals.Closing = totals.Closing.Add(*line.Amount)
+
+			if line.Amount.IsPositive() {
+				totals.Credits = totals.Credits.Add(*line.Amount)
+			} else {
+				totals.Debits = totals.Debits.Sub(*line.Amount)
+			}
+		}
+
+		line.Balance = totals.Closing
+
+		return w.Line(line)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return w.End(totals)
+}
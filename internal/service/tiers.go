@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/outbox"
+	"gophermart/internal/repository"
+	"gophermart/internal/tiers"
+	"log/slog"
+	"time"
+)
+
+const tierBatchSize = 500
+
+type TierService struct {
+	TierRepository   *repository.TierRepository
+	OutboxRepository *repository.OutboxRepository
+	Policy           *tiers.Policy
+	// Window is the rolling period the statistics are counted over.
+	Window time.Duration
+}
+
+// GetTierStatus reports the tier the user is credited at, which changes when
+// the recalculation job runs, and the progress of live statistics toward the
+// next tier.
+func (ts *TierService) GetTierStatus(ctx context.Context, userID int) (interfaces.TierStatus, error) {
+	since := time.Now().Add(-ts.Window)
+	name, updatedAt, err := ts.TierRepository.GetUserTier(ctx, userID)
+
+	if err != nil {
+		return interfaces.TierStatus{}, err
+	}
+
+	stats, err := ts.TierRepository.Stats(ctx, userID, since)
+
+	if err != nil {
+		return interfaces.TierStatus{}, err
+	}
+
+	index := ts.Policy.Lookup(name)
+	tier := ts.Policy.Tiers[index]
+	points, _ := stats.Points.Float64()
+	multiplier, _ := tier.Multiplier.Float64()
+
+	status := interfaces.TierStatus{
+		Tier:       tier.Name,
+		Multiplier: multiplier,
+		Points:     points,
+		Orders:     stats.Orders,
+		Since:      since,
+		UpdatedAt:  updatedAt,
+	}
+
+	if index+1 < len(ts.Policy.Tiers) {
+		next := ts.Policy.Tiers[index+1]
+		minPoints, _ := next.MinPoints.Float64()
+		pointsLeft, _ := decimal.Max(next.MinPoints.Sub(stats.Points), decimal.Zero).Float64()
+		nextMultiplier, _ := next.Multiplier.Float64()
+
+		status.Next = &interfaces.TierProgress{
+			Tier:       next.Name,
+			Multiplier: nextMultiplier,
+			MinPoints:  minPoints,
+			MinOrders:  next.MinOrders,
+			PointsLeft: pointsLeft,
+			OrdersLeft: max(next.MinOrders-stats.Orders, 0),
+			Progress:   ts.Policy.Progress(index+1, stats.Points, stats.Orders),
+		}
+	}
+
+	return status, nil
+}
+
+// Tier returns the tier a user is credited at.
+func (ts *TierService) Tier(ctx context.Context, userID int) (tiers.Tier, error) {
+	name, _, err := ts.TierRepository.GetUserTier(ctx, userID)
+
+	if err != nil {
+		return tiers.Tier{}, err
+	}
+
+	return ts.Policy.Tiers[ts.Policy.Lookup(name)], nil
+}
+
+// Recalculate is the scheduled job assigning every user the tier of their
+// rolling statistics. Downgrades apply as well as upgrades.
+func (ts *TierService) Recalculate(ctx context.Context) error {
+	since := time.Now().Add(-ts.Window)
+	after := 0
+	changed := 0
+
+	for {
+		page, err := ts.TierRepository.StatsPage(ctx, since, after, tierBatchSize)
+
+		if err != nil {
+			return err
+		}
+
+		for _, stats := range page {
+			tier := ts.Policy.Tiers[ts.Policy.Evaluate(stats.Points, stats.Orders)].Name
+
+			err := ts.TierRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+				previous, err := ts.TierRepository.SaveTier(ctx, stats, tier)
+
+				if err != nil || previous == tier {
+					return err
+				}
+
+				changed++
+				slog.InfoContext(ctx, "User tier changed", "user_id", stats.UserID, "from", previous, "to", tier)
+
+				return appendEvent(ctx, ts.OutboxRepository, stats.UserID, outbox.TypeTierChanged, outbox.TierChanged{From: previous, To: tier})
+			})
+
+			if err != nil {
+				return err
+			}
+
+			after = stats.UserID
+		}
+
+		if len(page) < tierBatchSize {
+			break
+		}
+	}
+
+	slog.InfoContext(ctx, "Tiers recalculated", "changed", changed)
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/outbox"
+	"gophermart/internal/repository"
+	"gophermart/internal/webhooks"
+	"log/slog"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	ErrInvalidTransfer     = errors.New("invalid transfer")
+	ErrRecipientNotFound   = errors.New("recipient not found")
+	ErrTransferLimit       = errors.New("daily transfer limit exceeded")
+	ErrIdempotencyConflict = errors.New("idempotency key reused with different parameters")
+	ErrNotEnoughPoints     = errors.New("not enough points")
+)
+
+type TransferService struct {
+	TransferRepository    *repository.TransferRepository
+	UserRepository        *repository.UserRepository
+	UserBalanceRepository *repository.UserBalanceRepository
+	OutboxRepository      *repository.OutboxRepository
+	ReservationRepository *repository.ReservationRepository
+	Ledger                *Ledger
+	// DailyLimit caps the points a user sends per UTC day, 0 for no cap.
+	DailyLimit decimal.Decimal
+	// DailyCount caps the transfers a user makes per UTC day, 0 for no cap.
+	DailyCount int
+}
+
+// Transfer moves points to another user in one transaction. Both balance rows
+// are locked in the order of user ids, so that opposite transfers between two
+// users wait for each other instead of deadlocking. The sender's lock also
+// serializes their requests, which makes the idempotency check race-free.
+func (ts *TransferService) Transfer(ctx context.Context, senderID int, request interfaces.TransferRequest) (interfaces.Transfer, bool, error) {
+	if err := validateTransfer(request); err != nil {
+		return interfaces.Transfer{}, false, err
+	}
+
+	recipientID := ts.UserRepository.GetUserID(ctx, request.To)
+
+	switch {
+	case recipientID == repository.DatabaseError:
+		return interfaces.Transfer{}, false, errors.New("failed to look up recipient")
+	case recipientID == repository.UserNotFound:
+		return interfaces.Transfer{}, false, ErrRecipientNotFound
+	case recipientID == senderID:
+		return interfaces.Transfer{}, false, fmt.Errorf("%w: cannot transfer to yourself", ErrInvalidTransfer)
+	}
+
+	var transfer interfaces.Transfer
+	var replayed bool
+	var rejected error
+
+	err := ts.UserBalanceRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		current, err := ts.lockBalances(ctx, senderID, recipientID)
+
+		if err != nil {
+			return err
+		}
+
+		existing, existingRecipient, found, err := ts.TransferRepository.FindByKey(ctx, senderID, request.IdempotencyKey)
+
+		if err != nil {
+			return err
+		}
+
+		if found {
+			if existingRecipient != recipientID || !decimal.NewFromFloat(existing.Amount).Equal(request.Amount) {
+				return ErrIdempotencyConflict
+			}
+
+			transfer, replayed = existing, true
+
+			return nil
+		}
+
+		if err := ts.checkDailyLimits(ctx, senderID, request.Amount); err != nil {
+			return err
+		}
+
+		expired, err := ts.Ledger.ExpireDue(ctx, senderID)
+
+		if err != nil {
+			return err
+		}
+
+		current = current.Sub(expired)
+		held, err := ts.ReservationRepository.HeldTotal(ctx, senderID, time.Now())
+
+		if err != nil {
+			return err
+		}
+
+		if current.Sub(held).LessThan(request.Amount) {
+			// Committed anyway, so that the expiry is kept.
+			rejected = ErrNotEnoughPoints
+
+			return nil
+		}
+
+		if err := ts.UserBalanceRepository.UpdateUserBalance(ctx, request.Amount.Neg(), senderID); err != nil {
+			return err
+		}
+
+		id, createdAt, err := ts.TransferRepository.CreateTransfer(ctx, senderID, recipientID, request.Amount, request.IdempotencyKey)
+
+		if err != nil {
+			return err
+		}
+
+		out := interfaces.LedgerEntry{Kind: repository.LedgerTransferOut, Amount: request.Amount, TransferID: &id}
+
+		if _, err := ts.Ledger.Debit(ctx, senderID, current, out); err != nil {
+			return err
+		}
+
+		in := interfaces.LedgerEntry{Kind: repository.LedgerTransferIn, Amount: request.Amount, TransferID: &id}
+
+		if err := ts.Ledger.Credit(ctx, recipientID, in); err != nil {
+			return err
+		}
+
+		event := outbox.TransferSent{ID: id, Recipient: recipientID, Amount: request.Amount}
+
+		if err := appendEvent(ctx, ts.OutboxRepository, senderID, outbox.TypeTransferSent, event); err != nil {
+			return err
+		}
+
+		value, _ := request.Amount.Float64()
+		transfer = interfaces.Transfer{
+			ID: id, Direction: repository.TransferOut, Counterparty: request.To, Amount: value, CreatedAt: createdAt,
+		}
+
+		if err := ts.publishTransfer(ctx, senderID, -float32(value)); err != nil {
+			return err
+		}
+
+		return ts.publishTransfer(ctx, recipientID, float32(value))
+	})
+
+	if err != nil {
+		return interfaces.Transfer{}, false, err
+	}
+
+	if rejected != nil {
+		return interfaces.Transfer{}, false, rejected
+	}
+
+	if !replayed {
+		slog.InfoContext(ctx, "Transfer committed", "transfer", transfer.ID, "recipient_id", recipientID, "amount", request.Amount)
+	}
+
+	return transfer, replayed, nil
+}
+
+func (ts *TransferService) Transfers(ctx context.Context, userID int) ([]interfaces.Transfer, error) {
+	return ts.TransferRepository.Transfers(ctx, userID)
+}
+
+// lockBalances locks both balances and returns the sender's.
+func (ts *TransferService) lockBalances(ctx context.Context, senderID int, recipientID int) (decimal.Decimal, error) {
+	balances, err := lockBalancesInOrder(ctx, ts.UserBalanceRepository, senderID, recipientID)
+
+	return balances[senderID], err
+}
+
+func (ts *TransferService) checkDailyLimits(ctx context.Context, senderID int, amount decimal.Decimal) error {
+	if !ts.DailyLimit.IsPositive() && ts.DailyCount == 0 {
+		return nil
+	}
+
+	sent, count, err := ts.TransferRepository.SentSince(ctx, senderID, time.Now().UTC().Truncate(24*time.Hour))
+
+	if err != nil {
+		return err
+	}
+
+	if ts.DailyCount > 0 && count >= ts.DailyCount {
+		return fmt.Errorf("%w: at most %d transfers per day", ErrTransferLimit, ts.DailyCount)
+	}
+
+	if ts.DailyLimit.IsPositive() && sent.Add(amount).GreaterThan(ts.DailyLimit) {
+		return fmt.Errorf("%w: %s of %s points left today", ErrTransferLimit,
+			decimal.Max(ts.DailyLimit.Sub(sent), decimal.Zero).StringFixed(2), ts.DailyLimit.StringFixed(2))
+	}
+
+	return nil
+}
+
+func (ts *TransferService) publishTransfer(ctx context.Context, userID int, delta float32) error {
+	balance, err := publishBalance(ctx, ts.UserBalanceRepository, userID)
+
+	if err != nil {
+		return err
+	}
+
+	return appendEvent(ctx, ts.OutboxRepository, userID, webhooks.EventBalanceAdjusted, webhooks.BalanceData{
+		UserID: userID, Delta: delta, Reason: "transfer", Current: balance.Current, Withdrawn: balance.Withdrawn,
+	})
+}
+
+func validateTransfer(request interfaces.TransferRequest) error {
+	if request.IdempotencyKey == "" || len(request.IdempotencyKey) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: an Idempotency-Key header of up to %d characters is required", ErrInvalidTransfer, maxIdempotencyKeyLength)
+	}
+
+	if request.To == "" {
+		return fmt.Errorf("%w: recipient login is required", ErrInvalidTransfer)
+	}
+
+	if !request.Amount.IsPositive() || !request.Amount.Equal(request.Amount.Round(2)) {
+		return fmt.Errorf("%w: amount must be positive with at most two decimal places", ErrInvalidTransfer)
+	}
+
+	return nil
+}
@@ -1,21 +1,24 @@
 package service
 
 import (
+	"context"
 	"golang.org/x/crypto/bcrypt"
 	"gophermart/internal/interfaces"
 	"gophermart/internal/models"
 	"gophermart/internal/repository"
+	"log/slog"
 )
 
 type UserService struct {
 	UserRepository *repository.UserRepository
+	Referrals      *ReferralService
 }
 
-func (us *UserService) GetUserID(username string) int {
-	return us.UserRepository.GetUserID(username)
+func (us *UserService) GetUserID(ctx context.Context, username string) int {
+	return us.UserRepository.GetUserID(ctx, username)
 }
 
-func (us *UserService) RegisterUser(user models.User) (models.User, error) {
+func (us *UserService) RegisterUser(ctx context.Context, user models.User) (models.User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 
 	if err != nil {
@@ -24,23 +27,48 @@ func (us *UserService) RegisterUser(user models.User) (models.User, error) {
 
 	user.Password = string(hashedPassword)
 
-	user.ID, err = us.UserRepository.CreateUser(user)
+	if user.ReferralCode != "" {
+		if us.Referrals == nil {
+			return user, ErrInvalidReferralCode
+		}
+
+		// Checked first so that a mistyped code does not leave an account behind.
+		if _, err := us.Referrals.ReferrerID(ctx, user.ReferralCode); err != nil {
+			return user, err
+		}
+	}
+
+	err = us.UserRepository.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		if user.ID, err = us.UserRepository.CreateUser(ctx, user); err != nil {
+			return err
+		}
+
+		if user.ReferralCode == "" {
+			return nil
+		}
+
+		return us.Referrals.Link(ctx, user.ReferralCode, user.ID)
+	})
 
 	if err != nil {
 		return user, err
 	}
 
+	slog.InfoContext(ctx, "User registered", "login", user.Username, "registered_user_id", user.ID)
+
 	return user, nil
 }
 
-func (us *UserService) AuthenticateUser(username, password string) (models.User, error) {
-	user, err := us.UserRepository.GetUserByUsername(username)
+func (us *UserService) AuthenticateUser(ctx context.Context, username, password string) (models.User, error) {
+	user, err := us.UserRepository.GetUserByUsername(ctx, username)
 
 	if err != nil {
+		slog.InfoContext(ctx, "Authentication failed: user lookup", "login", username, "error", err)
 		return models.User{}, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		slog.InfoContext(ctx, "Authentication failed: wrong password", "login", username)
 		return models.User{}, err
 	}
 
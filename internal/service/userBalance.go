@@ -1,24 +1,56 @@
 package service
 
 import (
+	"context"
 	"github.com/shopspring/decimal"
+	"gophermart/internal/events"
 	"gophermart/internal/interfaces"
 	"gophermart/internal/models"
 	"gophermart/internal/repository"
+	"log/slog"
 )
 
 type UserBalanceService struct {
 	UserBalanceRepository *repository.UserBalanceRepository
+	Ledger                *Ledger
 }
 
-func (ubs *UserBalanceService) UpdateUserBalance(accrual decimal.Decimal, userID int) error {
-	return ubs.UserBalanceRepository.UpdateUserBalance(accrual, userID)
+func (ubs *UserBalanceService) UpdateUserBalance(ctx context.Context, accrual decimal.Decimal, userID int) error {
+	if err := ubs.Ledger.Credit(ctx, userID, interfaces.LedgerEntry{Kind: repository.LedgerAccrual, Amount: accrual}); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "User balance credited", "accrual", accrual)
+
+	return nil
 }
 
-func (ubs *UserBalanceService) GetUserBalance(userID int) (interfaces.UserBalance, error) {
-	return ubs.UserBalanceRepository.GetUserBalance(userID)
+func (ubs *UserBalanceService) GetUserBalance(ctx context.Context, userID int) (interfaces.UserBalance, error) {
+	balance, err := ubs.UserBalanceRepository.GetUserBalance(ctx, userID)
+
+	if err != nil {
+		return balance, err
+	}
+
+	balance.ExpiringSoon, err = ubs.Ledger.ExpiringSoon(ctx, userID)
+
+	return balance, err
 }
 
-func (ubs *UserBalanceService) CreateUserBalance(user models.User) error {
-	return ubs.UserBalanceRepository.CreateUserBalance(user)
+func (ubs *UserBalanceService) CreateUserBalance(ctx context.Context, user models.User) error {
+	return ubs.UserBalanceRepository.CreateUserBalance(ctx, user)
+}
+
+// publishBalance notifies the user of their balance as seen by the current
+// transaction and returns it.
+func publishBalance(ctx context.Context, ubr *repository.UserBalanceRepository, userID int) (interfaces.UserBalance, error) {
+	balance, err := ubr.GetUserBalance(ctx, userID)
+
+	if err != nil {
+		return balance, err
+	}
+
+	return balance, events.Publish(ctx, ubr.DBStorage, events.TypeBalance, userID, events.BalanceData{
+		Current: balance.Current, Withdrawn: balance.Withdrawn, Reserved: balance.Reserved,
+	})
 }
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/internal/interfaces"
+	"gophermart/internal/pagination"
+	"gophermart/internal/repository"
+	"gophermart/internal/webhooks"
+	"log/slog"
+	"net/url"
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+type WebhookService struct {
+	WebhookRepository *repository.WebhookRepository
+}
+
+func (ws *WebhookService) CreateEndpoint(ctx context.Context, endpointURL string, eventTypes []string) (interfaces.WebhookEndpoint, error) {
+	if u, err := url.Parse(endpointURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return interfaces.WebhookEndpoint{}, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+
+	if len(eventTypes) == 0 {
+		return interfaces.WebhookEndpoint{}, fmt.Errorf("%w: at least one event type is required", ErrInvalidWebhook)
+	}
+
+	for _, eventType := range eventTypes {
+		if !webhooks.IsEventType(eventType) {
+			return interfaces.WebhookEndpoint{}, fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+		}
+	}
+
+	secret, err := webhooks.NewSecret()
+
+	if err != nil {
+		return interfaces.WebhookEndpoint{}, err
+	}
+
+	endpoint, err := ws.WebhookRepository.CreateEndpoint(ctx, endpointURL, secret, eventTypes)
+
+	if err != nil {
+		return endpoint, err
+	}
+
+	slog.InfoContext(ctx, "Webhook endpoint registered", "webhook", endpoint.ID, "url", endpointURL, "events", eventTypes)
+
+	return endpoint, nil
+}
+
+func (ws *WebhookService) ListEndpoints(ctx context.Context) ([]interfaces.WebhookEndpoint, error) {
+	return ws.WebhookRepository.ListEndpoints(ctx)
+}
+
+func (ws *WebhookService) DeleteEndpoint(ctx context.Context, id int64) error {
+	if err := ws.WebhookRepository.DeactivateEndpoint(ctx, id); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Webhook endpoint removed", "webhook", id)
+
+	return nil
+}
+
+func (ws *WebhookService) DeadLetters(ctx context.Context, page pagination.Page) ([]interfaces.WebhookDelivery, *pagination.Cursor, error) {
+	return ws.WebhookRepository.DeadLetters(ctx, page)
+}
+
+func (ws *WebhookService) RetryDelivery(ctx context.Context, id int64) error {
+	return ws.WebhookRepository.RetryDelivery(ctx, id)
+}
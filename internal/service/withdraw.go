@@ -1,57 +1,149 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"github.com/jackc/pgx/v4"
 	"github.com/shopspring/decimal"
+	"gophermart/internal/events"
 	"gophermart/internal/interfaces"
+	"gophermart/internal/metrics"
+	"gophermart/internal/pagination"
 	"gophermart/internal/repository"
+	"gophermart/internal/webhooks"
+	"log/slog"
+	"time"
 )
 
 type WithdrawService struct {
-	WithdrawRepository *repository.WithdrawRepository
+	WithdrawRepository    *repository.WithdrawRepository
+	UserBalanceRepository *repository.UserBalanceRepository
+	OutboxRepository      *repository.OutboxRepository
+	ReservationRepository *repository.ReservationRepository
+	Ledger                *Ledger
 }
 
-func (ws *WithdrawService) Withdraw(userID int, orderNumber string, sum decimal.Decimal) (int, error) {
+// Withdraw locks the balance row for the whole transaction, so concurrent
+// withdrawals of one user cannot both pass the balance check. Points held by
+// reservations cannot be withdrawn.
+func (ws *WithdrawService) Withdraw(ctx context.Context, userID int, orderNumber string, sum decimal.Decimal) (int, error) {
 	wr := ws.WithdrawRepository
-	tx, err := wr.DBStorage.Conn.BeginTx(wr.DBStorage.Ctx, pgx.TxOptions{})
+	result := 0
+
+	err := wr.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		userBalance, err := wr.GetCurrentBalance(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		expired, err := ws.Ledger.ExpireDue(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		userBalance = userBalance.Sub(expired)
+		held, err := ws.ReservationRepository.HeldTotal(ctx, userID, time.Now())
+
+		if err != nil {
+			return err
+		}
+
+		if userBalance.Sub(held).LessThan(sum) {
+			slog.InfoContext(ctx, "Withdrawal rejected: not enough points", "order", orderNumber, "sum", sum,
+				"current", userBalance, "reserved", held)
+			result = repository.NotEnoughFound
+
+			return nil
+		}
+
+		return ws.debit(ctx, userID, orderNumber, sum, userBalance, repository.WithdrawalPending, withdrawalActorUser)
+	})
+
 	if err != nil {
-		return repository.NotEnoughFound, fmt.Errorf("ошибка при начале транзакции: %v", err)
+		return repository.WithdrawTransactionError, fmt.Errorf("withdrawal failed: %w", err)
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			_ = tx.Rollback(wr.DBStorage.Ctx)
-		}
-	}()
 
-	userBalance, err := wr.GetCurrentBalance(userID)
+	if result == 0 {
+		slog.InfoContext(ctx, "Withdrawal committed", "order", orderNumber, "sum", sum)
+	}
+
+	return result, nil
+}
+
+// debit records a withdrawal of sum points with the given status. current is
+// the balance before it; the caller holds the balance lock and has checked
+// that the points are there.
+func (ws *WithdrawService) debit(ctx context.Context, userID int, orderNumber string, sum decimal.Decimal, current decimal.Decimal, status string, actor string) error {
+	wr := ws.WithdrawRepository
+
+	if err := wr.UpdateUserBalance(ctx, userID, sum); err != nil {
+		return err
+	}
+
+	entry := interfaces.LedgerEntry{Kind: repository.LedgerWithdrawal, Amount: sum, OrderNumber: orderNumber}
+	lotsExpireAt, err := ws.Ledger.Debit(ctx, userID, current, entry)
+
 	if err != nil {
-		_ = tx.Rollback(wr.DBStorage.Ctx)
-		return repository.WithdrawTransactionError, err
+		return err
 	}
 
-	if userBalance.LessThan(sum) {
-		_ = tx.Rollback(wr.DBStorage.Ctx)
-		return repository.NotEnoughFound, nil
+	withdrawalID, err := wr.SaveWithdrawal(ctx, userID, orderNumber, sum, status, lotsExpireAt)
+
+	if err != nil {
+		return err
 	}
 
-	if err := wr.UpdateUserBalance(userID, sum); err != nil {
-		_ = tx.Rollback(wr.DBStorage.Ctx)
-		return repository.WithdrawTransactionError, err
+	if err := wr.RecordWithdrawalEvent(ctx, withdrawalID, repository.WithdrawalActionCreated, sum, actor, ""); err != nil {
+		return err
 	}
 
-	if err := wr.SaveWithdrawal(userID, orderNumber, sum); err != nil {
-		_ = tx.Rollback(wr.DBStorage.Ctx)
-		return repository.WithdrawTransactionError, err
+	value, _ := sum.Float64()
+	wr.DBStorage.AfterCommit(ctx, func() { metrics.PointsWithdrawnTotal.Add(value) })
+
+	withdrawal := events.WithdrawalData{Order: orderNumber, Sum: float32(value), Status: status}
+
+	if err := events.Publish(ctx, wr.DBStorage, events.TypeWithdrawal, userID, withdrawal); err != nil {
+		return err
 	}
 
-	if err := tx.Commit(wr.DBStorage.Ctx); err != nil {
-		return repository.WithdrawTransactionError, err
+	webhookData := webhooks.WithdrawalData{ID: withdrawalID, UserID: userID, Order: orderNumber, Sum: float32(value), Status: status}
+
+	if err := appendEvent(ctx, ws.OutboxRepository, userID, webhooks.EventWithdrawalCreated, webhookData); err != nil {
+		return err
 	}
 
-	return 0, nil
+	balance, err := publishBalance(ctx, ws.UserBalanceRepository, userID)
+
+	if err != nil {
+		return err
+	}
+
+	return appendEvent(ctx, ws.OutboxRepository, userID, webhooks.EventBalanceAdjusted, webhooks.BalanceData{
+		UserID: userID, Delta: -float32(value), Reason: "withdrawal", Order: orderNumber,
+		Current: balance.Current, Withdrawn: balance.Withdrawn,
+	})
+}
+
+func (ws *WithdrawService) Withdrawals(ctx context.Context, userID int) ([]interfaces.WithdrawInfo, error) {
+	return ws.WithdrawRepository.Withdrawals(ctx, userID)
 }
 
-func (ws *WithdrawService) Withdrawals(userID int) ([]interfaces.WithdrawInfo, error) {
-	return ws.WithdrawRepository.Withdrawals(userID)
+func (ws *WithdrawService) WithdrawalsPage(ctx context.Context, userID int, page pagination.Page) (interfaces.WithdrawalsPage, error) {
+	var result interfaces.WithdrawalsPage
+	wr := ws.WithdrawRepository
+
+	err := wr.DBStorage.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if result.Withdrawals, result.Next, err = wr.WithdrawalsPage(ctx, userID, page); err != nil {
+			return err
+		}
+
+		result.TotalCount, result.TotalSum, err = wr.WithdrawalsTotal(ctx, userID, page)
+
+		return err
+	})
+
+	return result, err
 }
@@ -0,0 +1,67 @@
+package statement
+
+import (
+	"encoding/csv"
+	"gophermart/internal/interfaces"
+	"io"
+	"time"
+)
+
+// Kinds of the summary rows around the lines of a CSV statement.
+const (
+	KindOpening = "OPENING_BALANCE"
+	KindCredits = "TOTAL_CREDITS"
+	KindDebits  = "TOTAL_DEBITS"
+	KindClosing = "CLOSING_BALANCE"
+)
+
+var csvHeader = []string{"date", "type", "order", "status", "amount", "balance"}
+
+// CSVWriter renders a statement as RFC 4180 CSV: CRLF line endings, a header
+// row and fields quoted where needed. The opening balance, the totals and the
+// closing balance are rows of their own, so that every row has the same
+// columns.
+type CSVWriter struct {
+	w   *csv.Writer
+	end time.Time
+}
+
+func NewCSV(w io.Writer) *CSVWriter {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	return &CSVWriter{w: cw}
+}
+
+func (cw *CSVWriter) Begin(period interfaces.StatementPeriod) error {
+	cw.end = period.To
+
+	if err := cw.w.Write(csvHeader); err != nil {
+		return err
+	}
+
+	return cw.w.Write([]string{formatTime(period.From), KindOpening, "", "", "", formatAmount(period.Opening)})
+}
+
+func (cw *CSVWriter) Line(line interfaces.StatementLine) error {
+	amount := ""
+
+	if line.Amount != nil {
+		amount = formatAmount(*line.Amount)
+	}
+
+	return cw.w.Write([]string{
+		formatTime(line.Time), line.Kind, line.OrderNumber, line.Status, amount, formatAmount(line.Balance),
+	})
+}
+
+func (cw *CSVWriter) End(totals interfaces.StatementTotals) error {
+	rows := [][]string{
+		{formatTime(cw.end), KindCredits, "", "", formatAmount(totals.Credits), ""},
+		{formatTime(cw.end), KindDebits, "", "", formatAmount(totals.Debits.Neg()), ""},
+		{formatTime(cw.end), KindClosing, "", "", "", formatAmount(totals.Closing)},
+	}
+
+	// WriteAll flushes.
+	return cw.w.WriteAll(rows)
+}
@@ -0,0 +1,279 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"gophermart/internal/interfaces"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// A4 portrait in points.
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+	margin     = 40.0
+	lineHeight = 14.0
+	fontSize   = 9.0
+)
+
+// Objects written before the pages. The page tree is only known at the end
+// and is written last under its reserved number.
+const (
+	catalogObject = iota + 1
+	pagesObject
+	regularFontObject
+	boldFontObject
+)
+
+type column struct {
+	title string
+	x     float64
+	width float64
+	right bool
+}
+
+var pdfColumns = []column{
+	{title: "Date", x: margin, width: 85},
+	{title: "Type", x: 130, width: 115},
+	{title: "Order", x: 250, width: 115},
+	{title: "Status", x: 370, width: 60},
+	{title: "Amount", x: 480, width: 50, right: true},
+	{title: "Balance", x: pageWidth - margin, width: 70, right: true},
+}
+
+// PDFWriter renders a statement as a PDF document with the standard
+// Helvetica fonts, which need no embedding. Only the page being filled is
+// buffered; finished pages are written out with their offsets kept for the
+// cross-reference table. Characters outside WinAnsiEncoding print as "?".
+type PDFWriter struct {
+	w       io.Writer
+	written int64
+	err     error
+	offsets []int64
+	pages   []int
+	page    bytes.Buffer
+	y       float64
+	period  interfaces.StatementPeriod
+}
+
+func NewPDF(w io.Writer) *PDFWriter {
+	return &PDFWriter{w: w}
+}
+
+func (pw *PDFWriter) Begin(period interfaces.StatementPeriod) error {
+	pw.period = period
+	pw.offsets = make([]int64, boldFontObject)
+
+	pw.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	pw.object(catalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+	pw.object(regularFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	pw.object(boldFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	pw.startPage()
+	pw.text("F2", 16, margin, pw.y, "Account statement")
+	pw.y -= 2 * lineHeight
+	pw.text("F1", fontSize, margin, pw.y, "User: "+period.Username)
+	pw.y -= lineHeight
+	pw.text("F1", fontSize, margin, pw.y, fmt.Sprintf("Period: %s - %s", formatTime(period.From), formatTime(period.To)))
+	pw.y -= lineHeight
+	pw.text("F1", fontSize, margin, pw.y, "Opening balance: "+formatAmount(period.Opening))
+	pw.y -= 2 * lineHeight
+	pw.tableHeader()
+
+	return pw.err
+}
+
+func (pw *PDFWriter) Line(line interfaces.StatementLine) error {
+	if pw.y < margin+lineHeight {
+		pw.finishPage()
+		pw.startPage()
+		pw.tableHeader()
+	}
+
+	amount := ""
+
+	if line.Amount != nil {
+		amount = formatAmount(*line.Amount)
+	}
+
+	cells := []string{
+		line.Time.Format("2006-01-02 15:04"), line.Kind, line.OrderNumber, line.Status, amount, formatAmount(line.Balance),
+	}
+
+	pw.row("F1", cells)
+
+	return pw.err
+}
+
+func (pw *PDFWriter) End(totals interfaces.StatementTotals) error {
+	if pw.y < margin+4*lineHeight {
+		pw.finishPage()
+		pw.startPage()
+	}
+
+	pw.y -= lineHeight
+	pw.text("F1", fontSize, margin, pw.y, "Credits: "+formatAmount(totals.Credits))
+	pw.y -= lineHeight
+	pw.text("F1", fontSize, margin, pw.y, "Debits: "+formatAmount(totals.Debits.Neg()))
+	pw.y -= lineHeight
+	pw.text("F2", fontSize, margin, pw.y, "Closing balance: "+formatAmount(totals.Closing))
+	pw.finishPage()
+
+	kids := make([]string, len(pw.pages))
+
+	for i, page := range pw.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+
+	pw.object(pagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pw.pages)))
+
+	info := pw.newObject()
+	pw.object(info, "<< /Title "+pdfString("Account statement")+" /Producer (gophermart) >>")
+
+	xref := pw.written
+	pw.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1))
+
+	for _, offset := range pw.offsets {
+		pw.write(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+
+	pw.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(pw.offsets)+1, catalogObject, info, xref))
+
+	return pw.err
+}
+
+func (pw *PDFWriter) startPage() {
+	pw.page.Reset()
+	pw.y = pageHeight - margin - 16
+}
+
+// finishPage writes the buffered page with its number in the footer.
+func (pw *PDFWriter) finishPage() {
+	footer := fmt.Sprintf("Page %d", len(pw.pages)+1)
+	pw.text("F1", 8, pageWidth-margin-textWidth(footer, 8), margin/2, footer)
+
+	content := pw.newObject()
+	pw.object(content, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", pw.page.Len(), pw.page.Bytes()))
+
+	page := pw.newObject()
+	pw.object(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] "+
+		"/Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+		pagesObject, pageWidth, pageHeight, regularFontObject, boldFontObject, content))
+
+	pw.pages = append(pw.pages, page)
+}
+
+func (pw *PDFWriter) tableHeader() {
+	titles := make([]string, len(pdfColumns))
+
+	for i, c := range pdfColumns {
+		titles[i] = c.title
+	}
+
+	pw.row("F2", titles)
+	fmt.Fprintf(&pw.page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, pw.y+lineHeight-3, pageWidth-margin, pw.y+lineHeight-3)
+}
+
+func (pw *PDFWriter) row(font string, cells []string) {
+	for i, c := range pdfColumns {
+		cell := fit(cells[i], c.width)
+		x := c.x
+
+		if c.right {
+			x -= textWidth(cell, fontSize)
+		}
+
+		pw.text(font, fontSize, x, pw.y, cell)
+	}
+
+	pw.y -= lineHeight
+}
+
+func (pw *PDFWriter) text(font string, size float64, x float64, y float64, s string) {
+	fmt.Fprintf(&pw.page, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+func (pw *PDFWriter) newObject() int {
+	pw.offsets = append(pw.offsets, 0)
+
+	return len(pw.offsets)
+}
+
+func (pw *PDFWriter) object(number int, body string) {
+	pw.offsets[number-1] = pw.written
+	pw.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", number, body))
+}
+
+// write keeps the first error; later writes are skipped.
+func (pw *PDFWriter) write(s string) {
+	if pw.err != nil {
+		return
+	}
+
+	n, err := io.WriteString(pw.w, s)
+	pw.written += int64(n)
+	pw.err = err
+}
+
+// pdfString encodes s as a literal string in WinAnsiEncoding, which matches
+// Latin-1 for the characters it keeps.
+func pdfString(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('(')
+
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	b.WriteByte(')')
+
+	return b.String()
+}
+
+// Helvetica advance widths per 1000 units of the characters amounts are made
+// of; other characters are taken as wide as a digit.
+var helveticaWidths = map[rune]float64{' ': 278, '.': 278, ',': 278, '-': 333, ':': 278}
+
+func textWidth(s string, size float64) float64 {
+	width := 0.0
+
+	for _, r := range s {
+		w, ok := helveticaWidths[r]
+
+		if !ok {
+			w = 556
+		}
+
+		width += w
+	}
+
+	return width * size / 1000
+}
+
+// fit shortens s with an ellipsis until it fits into width at fontSize.
+func fit(s string, width float64) string {
+	if textWidth(s, fontSize) <= width {
+		return s
+	}
+
+	for s != "" && textWidth(s+"...", fontSize) > width {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+
+	return s + "..."
+}
@@ -0,0 +1,23 @@
+// Package statement renders account statements. The renderers implement
+// interfaces.StatementWriter and write to the underlying writer as lines come
+// in, so a statement is never held in memory as a whole.
+package statement
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// Formats of a statement as requested in the format query parameter.
+const (
+	FormatCSV = "csv"
+	FormatPDF = "pdf"
+)
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+func formatAmount(amount decimal.Decimal) string {
+	return amount.StringFixed(2)
+}
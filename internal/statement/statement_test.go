@@ -0,0 +1,115 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gophermart/internal/interfaces"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func render(t *testing.T, w interfaces.StatementWriter, lines []interfaces.StatementLine) {
+	t.Helper()
+
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	period := interfaces.StatementPeriod{Username: "Zoë (test)", From: from, To: from.AddDate(0, 1, 0), Opening: decimal.NewFromInt(100)}
+
+	if err := w.Begin(period); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range lines {
+		if err := w.Line(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	totals := interfaces.StatementTotals{Credits: decimal.NewFromInt(50), Debits: decimal.NewFromInt(20), Closing: decimal.NewFromInt(130)}
+
+	if err := w.End(totals); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func amount(value int64) *decimal.Decimal {
+	d := decimal.NewFromInt(value)
+	return &d
+}
+
+func TestCSV(t *testing.T) {
+	at := time.Date(2026, 9, 2, 10, 0, 0, 0, time.UTC)
+	lines := []interfaces.StatementLine{
+		{Time: at, Kind: "ORDER", OrderNumber: "12345678903", Status: "PROCESSED", Balance: decimal.NewFromInt(100)},
+		{Time: at, Kind: "ACCRUAL", OrderNumber: "12345678903", Amount: amount(50), Balance: decimal.NewFromInt(150)},
+		{Time: at, Kind: "WITHDRAWAL", OrderNumber: "2377225624,\"x\"", Amount: amount(-20), Balance: decimal.NewFromInt(130)},
+	}
+
+	var buf bytes.Buffer
+	render(t, NewCSV(&buf), lines)
+
+	want := "date,type,order,status,amount,balance\r\n" +
+		"2026-09-01T00:00:00Z,OPENING_BALANCE,,,,100.00\r\n" +
+		"2026-09-02T10:00:00Z,ORDER,12345678903,PROCESSED,,100.00\r\n" +
+		"2026-09-02T10:00:00Z,ACCRUAL,12345678903,,50.00,150.00\r\n" +
+		"2026-09-02T10:00:00Z,WITHDRAWAL,\"2377225624,\"\"x\"\"\",,-20.00,130.00\r\n" +
+		"2026-10-01T00:00:00Z,TOTAL_CREDITS,,,50.00,\r\n" +
+		"2026-10-01T00:00:00Z,TOTAL_DEBITS,,,-20.00,\r\n" +
+		"2026-10-01T00:00:00Z,CLOSING_BALANCE,,,,130.00\r\n"
+
+	if buf.String() != want {
+		t.Fatalf("Expected\n%q\ngot\n%q", want, buf.String())
+	}
+}
+
+func TestPDF(t *testing.T) {
+	at := time.Date(2026, 9, 2, 10, 0, 0, 0, time.UTC)
+	var lines []interfaces.StatementLine
+
+	// Enough lines for three pages.
+	for i := 0; i < 120; i++ {
+		lines = append(lines, interfaces.StatementLine{Time: at, Kind: "ACCRUAL", OrderNumber: strconv.Itoa(i), Amount: amount(1), Balance: decimal.NewFromInt(int64(100 + i))})
+	}
+
+	var buf bytes.Buffer
+	render(t, NewPDF(&buf), lines)
+
+	doc := buf.String()
+
+	if !strings.HasPrefix(doc, "%PDF-1.4\n") || !strings.HasSuffix(doc, "%%EOF\n") {
+		t.Fatal("Expected a PDF header and trailer")
+	}
+
+	if !strings.Contains(doc, "/Count 3 >>") {
+		t.Fatal("Expected three pages")
+	}
+
+	if !strings.Contains(doc, `(User: Zo\353 \(test\))`) {
+		t.Fatal("Expected the username in WinAnsiEncoding with escaped parentheses")
+	}
+
+	// Every cross-reference entry must point at its object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(doc)
+
+	if startxref == nil {
+		t.Fatal("Expected startxref")
+	}
+
+	xref, _ := strconv.Atoi(startxref[1])
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllStringSubmatch(doc[xref:], -1)
+
+	if len(entries) == 0 {
+		t.Fatal("Expected cross-reference entries")
+	}
+
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(doc[offset:], want) {
+			t.Fatalf("Expected object %d at offset %d", i+1, offset)
+		}
+	}
+}
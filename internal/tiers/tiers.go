@@ -0,0 +1,149 @@
+package tiers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strconv"
+	"strings"
+)
+
+// Tier is reached by accruing MinPoints or completing MinOrders within the
+// rolling window, whichever comes first.
+type Tier struct {
+	Name       string
+	MinPoints  decimal.Decimal
+	MinOrders  int
+	Multiplier decimal.Decimal
+}
+
+// Policy holds the tiers in ascending order; the first one is the base tier
+// every user starts in.
+type Policy struct {
+	Tiers []Tier
+}
+
+// Parse reads tiers written as "name:min_points:min_orders:multiplier", for
+// example "silver:1000:10:1.1".
+func Parse(specs []string) (*Policy, error) {
+	if len(specs) == 0 {
+		return nil, errors.New("at least one tier is required")
+	}
+
+	policy := &Policy{}
+	names := map[string]bool{}
+
+	for i, spec := range specs {
+		parts := strings.Split(spec, ":")
+
+		if len(parts) != 4 || parts[0] == "" {
+			return nil, fmt.Errorf("tier %q must be name:min_points:min_orders:multiplier", spec)
+		}
+
+		minPoints, err := decimal.NewFromString(parts[1])
+
+		if err != nil || minPoints.IsNegative() {
+			return nil, fmt.Errorf("tier %q: invalid min_points", spec)
+		}
+
+		minOrders, err := strconv.Atoi(parts[2])
+
+		if err != nil || minOrders < 0 {
+			return nil, fmt.Errorf("tier %q: invalid min_orders", spec)
+		}
+
+		multiplier, err := decimal.NewFromString(parts[3])
+
+		if err != nil || multiplier.LessThan(decimal.NewFromInt(1)) {
+			return nil, fmt.Errorf("tier %q: multiplier must be at least 1", spec)
+		}
+
+		tier := Tier{Name: parts[0], MinPoints: minPoints, MinOrders: minOrders, Multiplier: multiplier}
+
+		if names[tier.Name] {
+			return nil, fmt.Errorf("tier %q is defined twice", tier.Name)
+		}
+
+		names[tier.Name] = true
+
+		if i == 0 && (tier.MinPoints.IsPositive() || tier.MinOrders > 0) {
+			return nil, fmt.Errorf("base tier %q must not have thresholds", tier.Name)
+		}
+
+		if i > 0 {
+			previous := policy.Tiers[i-1]
+
+			if tier.MinPoints.LessThanOrEqual(previous.MinPoints) || tier.MinOrders <= previous.MinOrders {
+				return nil, fmt.Errorf("tier %q must have higher thresholds than %q", tier.Name, previous.Name)
+			}
+		}
+
+		policy.Tiers = append(policy.Tiers, tier)
+	}
+
+	return policy, nil
+}
+
+// Evaluate returns the index of the highest tier the statistics qualify for.
+func (p *Policy) Evaluate(points decimal.Decimal, orders int) int {
+	reached := 0
+
+	for i, tier := range p.Tiers {
+		if points.GreaterThanOrEqual(tier.MinPoints) || orders >= tier.MinOrders {
+			reached = i
+		}
+	}
+
+	return reached
+}
+
+// Lookup returns the index of a tier by name, or the base tier for names that
+// are no longer configured.
+func (p *Policy) Lookup(name string) int {
+	for i, tier := range p.Tiers {
+		if tier.Name == name {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// Bonus returns the points the tier adds on top of an accrual, rounded to
+// hundredths.
+func (t Tier) Bonus(accrual decimal.Decimal) decimal.Decimal {
+	return accrual.Mul(t.Multiplier.Sub(decimal.NewFromInt(1))).Round(2)
+}
+
+func (p *Policy) Names() []string {
+	names := make([]string, len(p.Tiers))
+
+	for i, tier := range p.Tiers {
+		names[i] = tier.Name
+	}
+
+	return names
+}
+
+// Progress returns how far the statistics are toward a tier, from 0 to 1, by
+// whichever threshold is closer.
+func (p *Policy) Progress(index int, points decimal.Decimal, orders int) float64 {
+	tier := p.Tiers[index]
+	progress := 0.0
+
+	if tier.MinPoints.IsPositive() {
+		progress, _ = points.Div(tier.MinPoints).Float64()
+	}
+
+	if tier.MinOrders > 0 {
+		if byOrders := float64(orders) / float64(tier.MinOrders); byOrders > progress {
+			progress = byOrders
+		}
+	}
+
+	if progress > 1 {
+		return 1
+	}
+
+	return progress
+}
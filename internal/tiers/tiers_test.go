@@ -0,0 +1,71 @@
+package tiers
+
+import (
+	"github.com/shopspring/decimal"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	policy, err := Parse([]string{"bronze:0:0:1", "silver:1000:10:1.1", "gold:5000:50:1.25"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policy.Tiers) != 3 || policy.Tiers[2].Name != "gold" || !policy.Tiers[2].Multiplier.Equal(decimal.RequireFromString("1.25")) {
+		t.Errorf("Unexpected tiers %+v", policy.Tiers)
+	}
+
+	invalid := [][]string{
+		nil,
+		{"bronze:0:0"},
+		{"bronze:100:0:1"},
+		{"bronze:0:0:0.5"},
+		{"bronze:0:0:1", "silver:1000:10:1.1", "gold:900:50:1.25"},
+		{"bronze:0:0:1", "bronze:1000:10:1.1"},
+	}
+
+	for _, specs := range invalid {
+		if _, err := Parse(specs); err == nil {
+			t.Errorf("Parse(%v) should fail", specs)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy, err := Parse([]string{"bronze:0:0:1", "silver:1000:10:1.1", "gold:5000:50:1.25"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		points string
+		orders int
+		want   string
+	}{
+		{points: "0", orders: 0, want: "bronze"},
+		{points: "999.99", orders: 9, want: "bronze"},
+		{points: "1000", orders: 0, want: "silver"},
+		{points: "10", orders: 10, want: "silver"},
+		{points: "10", orders: 50, want: "gold"},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Tiers[policy.Evaluate(decimal.RequireFromString(tt.points), tt.orders)].Name; got != tt.want {
+			t.Errorf("Evaluate(%s, %d) = %s, want %s", tt.points, tt.orders, got, tt.want)
+		}
+	}
+
+	if got := policy.Tiers[policy.Lookup("gold")].Bonus(decimal.RequireFromString("333.33")); !got.Equal(decimal.RequireFromString("83.33")) {
+		t.Errorf("Bonus() = %s, want 83.33", got)
+	}
+
+	if got := policy.Lookup("platinum"); got != 0 {
+		t.Errorf("Lookup() of an unknown tier = %d, want the base tier", got)
+	}
+
+	if got := policy.Progress(1, decimal.NewFromInt(250), 5); got != 0.5 {
+		t.Errorf("Progress() = %v, want 0.5 by orders", got)
+	}
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PgxTracer turns pgx v4 query log events into spans. pgx v4 has no tracer
+// hook, so spans are recorded after the fact using the reported duration.
+type PgxTracer struct {
+	Next pgx.Logger
+}
+
+func (t *PgxTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if t.Next != nil {
+		t.Next.Log(ctx, level, msg, data)
+	}
+
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+
+	sql, ok := data["sql"].(string)
+
+	if !ok {
+		return
+	}
+
+	end := time.Now()
+	start := end
+
+	if duration, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-duration)
+	}
+
+	_, span := Tracer().Start(ctx, "pg "+msg,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBQueryText(sql)),
+	)
+
+	if err, ok := data["err"]; ok && level == pgx.LogLevelError {
+		span.SetStatus(codes.Error, fmt.Sprint(err))
+	}
+
+	span.End(trace.WithTimestamp(end))
+}
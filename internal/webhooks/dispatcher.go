@@ -0,0 +1,189 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gophermart/internal/interfaces"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	dispatchBatchSize = 20
+	maxErrorLength    = 512
+)
+
+type Store interface {
+	// ClaimDeliveries returns due deliveries and moves their next attempt to
+	// leaseUntil, so that other replicas skip them while they are in flight.
+	// A replica that dies mid-delivery leaves them to be retried after it.
+	ClaimDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]interfaces.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, dead bool, lastError string) error
+}
+
+// Dispatcher sends the deliveries written by Sink. Delivery is at least once: a
+// receiver may see the same payload id again after a timeout or a restart.
+type Dispatcher struct {
+	store        Store
+	client       *http.Client
+	pollInterval time.Duration
+	timeout      time.Duration
+	maxAttempts  int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDispatcher(store Store, pollInterval time.Duration, timeout time.Duration, maxAttempts int) *Dispatcher {
+	d := &Dispatcher{
+		store:        store,
+		client:       &http.Client{Timeout: timeout},
+		pollInterval: pollInterval,
+		timeout:      timeout,
+		maxAttempts:  maxAttempts,
+		done:         make(chan struct{}),
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	return d
+}
+
+func (d *Dispatcher) Run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if d.dispatch() == dispatchBatchSize {
+			// A full batch means more deliveries are probably due.
+			continue
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown aborts in-flight requests; their deliveries are retried once the
+// lease expires.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.cancel()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) dispatch() int {
+	if d.ctx.Err() != nil {
+		return 0
+	}
+
+	now := time.Now()
+	deliveries, err := d.store.ClaimDeliveries(d.ctx, now, now.Add(2*d.timeout), dispatchBatchSize)
+
+	if err != nil {
+		if d.ctx.Err() == nil {
+			slog.Error("Failed to claim webhook deliveries", "error", err)
+		}
+
+		return 0
+	}
+
+	var wg sync.WaitGroup
+
+	for _, delivery := range deliveries {
+		wg.Add(1)
+
+		go func(delivery interfaces.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(delivery)
+		}(delivery)
+	}
+
+	wg.Wait()
+
+	return len(deliveries)
+}
+
+func (d *Dispatcher) deliver(delivery interfaces.WebhookDelivery) {
+	err := d.send(delivery)
+
+	if err != nil && d.ctx.Err() != nil {
+		// Aborted by shutdown: not the endpoint's fault, retried after the lease.
+		return
+	}
+
+	// Results are stored even when shutdown starts right now: the request has
+	// been answered and should not be sent again.
+	ctx := context.WithoutCancel(d.ctx)
+
+	if err == nil {
+		if err := d.store.MarkDelivered(ctx, delivery.ID); err != nil {
+			slog.Error("Failed to mark webhook delivered", "delivery", delivery.ID, "error", err)
+		}
+
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	dead := attempts >= d.maxAttempts
+	lastError := err.Error()
+
+	if len(lastError) > maxErrorLength {
+		lastError = lastError[:maxErrorLength]
+	}
+
+	slog.Warn("Webhook delivery failed",
+		"delivery", delivery.ID, "url", delivery.URL, "attempts", attempts, "dead", dead, "error", err)
+
+	if err := d.store.MarkFailed(ctx, delivery.ID, attempts, time.Now().Add(Backoff(attempts)), dead, lastError); err != nil {
+		slog.Error("Failed to record webhook failure", "delivery", delivery.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) send(delivery interfaces.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
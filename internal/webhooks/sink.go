@@ -0,0 +1,33 @@
+package webhooks
+
+import (
+	"context"
+	"gophermart/internal/interfaces"
+)
+
+type SinkStore interface {
+	// EnqueueDeliveries writes one delivery per active endpoint subscribed to
+	// the event, at most once per event and endpoint.
+	EnqueueDeliveries(ctx context.Context, eventID int64, eventType string, payload string) error
+}
+
+// Sink is the outbox sink of webhooks. It turns events of the webhook types
+// into deliveries, which the Dispatcher then sends and retries per endpoint,
+// and skips the other events.
+type Sink struct {
+	Store SinkStore
+}
+
+func (s *Sink) Publish(ctx context.Context, event interfaces.OutboxEvent) error {
+	if !IsEventType(event.Type) {
+		return nil
+	}
+
+	body, err := NewPayload(event)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Store.EnqueueDeliveries(ctx, event.ID, event.Type, body)
+}
@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"gophermart/internal/interfaces"
+	"strconv"
+	"time"
+)
+
+const (
+	EventOrderProcessed    = "order.processed"
+	EventOrderInvalid      = "order.invalid"
+	EventWithdrawalCreated = "withdrawal.created"
+	EventBalanceAdjusted   = "balance.adjusted"
+	EventWithdrawalUpdated = "withdrawal.updated"
+	EventOrderRevised      = "order.revised"
+)
+
+// EventTypes lists the events endpoints can subscribe to.
+var EventTypes = []string{
+	EventOrderProcessed, EventOrderInvalid, EventWithdrawalCreated, EventBalanceAdjusted, EventWithdrawalUpdated,
+	EventOrderRevised,
+}
+
+// IsEventType reports whether endpoints can subscribe to the event type.
+func IsEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+const (
+	EventHeader     = "X-Gophermart-Event"
+	DeliveryHeader  = "X-Gophermart-Delivery"
+	TimestampHeader = "X-Gophermart-Timestamp"
+	SignatureHeader = "X-Gophermart-Signature"
+)
+
+type OrderData struct {
+	UserID  int     `json:"user_id"`
+	Number  string  `json:"number"`
+	Accrual float32 `json:"accrual,omitempty"`
+	// Status and PreviousAccrual are set for order.revised.
+	Status          string  `json:"status,omitempty"`
+	PreviousAccrual float32 `json:"previous_accrual,omitempty"`
+}
+
+type WithdrawalData struct {
+	// ID addresses the withdrawal in the shop API.
+	ID       int64   `json:"id"`
+	UserID   int     `json:"user_id"`
+	Order    string  `json:"order"`
+	Sum      float32 `json:"sum"`
+	Status   string  `json:"status,omitempty"`
+	Refunded float32 `json:"refunded,omitempty"`
+}
+
+type BalanceData struct {
+	UserID    int     `json:"user_id"`
+	Delta     float32 `json:"delta"`
+	Reason    string  `json:"reason"`
+	Order     string  `json:"order,omitempty"`
+	Current   float32 `json:"current"`
+	Withdrawn float32 `json:"withdrawn"`
+}
+
+type payload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewPayload builds the request body of an outbox event. The id is the outbox
+// event id, the same for all retries and endpoints so that receivers can
+// deduplicate.
+func NewPayload(event interfaces.OutboxEvent) (string, error) {
+	body, err := json.Marshal(payload{
+		ID: strconv.FormatInt(event.ID, 10), Type: event.Type, CreatedAt: event.CreatedAt.UTC(), Data: event.Payload,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// Sign returns the signature header value: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the endpoint secret. Including the
+// timestamp lets receivers reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Backoff returns the delay before the next attempt after the given number of
+// failed attempts: 10s doubling up to one hour.
+func Backoff(attempts int) time.Duration {
+	const (
+		base    = 10 * time.Second
+		maximum = time.Hour
+	)
+
+	delay := base
+
+	for i := 1; i < attempts; i++ {
+		if delay *= 2; delay >= maximum {
+			return maximum
+		}
+	}
+
+	return delay
+}
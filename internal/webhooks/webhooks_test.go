@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"gophermart/internal/interfaces"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"
+	got := Sign("secret", 1700000000, []byte(`{"a":1}`))
+
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 5, want: 160 * time.Second},
+		{attempts: 20, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+type failure struct {
+	attempts int
+	dead     bool
+}
+
+type fakeStore struct {
+	mu         sync.Mutex
+	deliveries []interfaces.WebhookDelivery
+	delivered  []int64
+	failed     map[int64]failure
+}
+
+func (s *fakeStore) ClaimDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]interfaces.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := s.deliveries
+	s.deliveries = nil
+
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkDelivered(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delivered = append(s.delivered, id)
+
+	return nil
+}
+
+func (s *fakeStore) MarkFailed(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, dead bool, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed[id] = failure{attempts: attempts, dead: dead}
+
+	return nil
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	const secret = "secret"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+
+		if r.Header.Get(SignatureHeader) != Sign(secret, timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.Header.Get(EventHeader) != EventOrderProcessed || r.Header.Get(DeliveryHeader) != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	data, _ := json.Marshal(OrderData{UserID: 1, Number: "12345678903", Accrual: 500})
+	payload, err := NewPayload(interfaces.OutboxEvent{ID: 1, Type: EventOrderProcessed, UserID: 1, Payload: data, CreatedAt: time.Now()})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &fakeStore{
+		deliveries: []interfaces.WebhookDelivery{
+			{ID: 1, URL: server.URL + "/ok", Secret: secret, EventType: EventOrderProcessed, Payload: payload},
+			{ID: 2, URL: server.URL + "/broken", Secret: secret, EventType: EventOrderProcessed, Payload: payload},
+			{ID: 3, URL: server.URL + "/broken", Secret: secret, EventType: EventOrderProcessed, Payload: payload, Attempts: 2},
+		},
+		failed: map[int64]failure{},
+	}
+
+	d := NewDispatcher(store, time.Hour, time.Second, 3)
+
+	if n := d.dispatch(); n != 3 {
+		t.Fatalf("Expected 3 deliveries to be claimed, got %d", n)
+	}
+
+	if len(store.delivered) != 1 || store.delivered[0] != 1 {
+		t.Errorf("Expected delivery 1 to be delivered, got %v", store.delivered)
+	}
+
+	if got := store.failed[2]; got != (failure{attempts: 1, dead: false}) {
+		t.Errorf("Expected delivery 2 to be retried, got %+v", got)
+	}
+
+	if got := store.failed[3]; got != (failure{attempts: 3, dead: true}) {
+		t.Errorf("Expected delivery 3 to be dead, got %+v", got)
+	}
+}
+
+type fakeSinkStore struct {
+	enqueued map[int64]string
+}
+
+func (s *fakeSinkStore) EnqueueDeliveries(ctx context.Context, eventID int64, eventType string, payload string) error {
+	s.enqueued[eventID] = payload
+
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	store := &fakeSinkStore{enqueued: map[int64]string{}}
+	sink := &Sink{Store: store}
+	createdAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		event   interfaces.OutboxEvent
+		want    string
+		enqueue bool
+	}{
+		{
+			name:    "webhook event",
+			event:   interfaces.OutboxEvent{ID: 7, Type: EventOrderInvalid, UserID: 1, Payload: []byte(`{"user_id":1,"number":"12345678903"}`), CreatedAt: createdAt},
+			want:    `{"id":"7","type":"order.invalid","created_at":"2026-10-19T12:00:00Z","data":{"user_id":1,"number":"12345678903"}}`,
+			enqueue: true,
+		},
+		{
+			name:  "other event",
+			event: interfaces.OutboxEvent{ID: 8, Type: "tier.changed", UserID: 1, Payload: []byte(`{"to":"gold"}`), CreatedAt: createdAt},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sink.Publish(context.Background(), tt.event); err != nil {
+				t.Fatal(err)
+			}
+
+			payload, ok := store.enqueued[tt.event.ID]
+
+			if ok != tt.enqueue || payload != tt.want {
+				t.Fatalf("Expected payload %q, got %q", tt.want, payload)
+			}
+		})
+	}
+}
@@ -1,13 +1,94 @@
 package storage
 
-import "time"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
+// AutoMigrate creates or updates the schema from the models, in dependency
+// order.
+func AutoMigrate(db *gorm.DB) error {
+	steps := [][]interface{}{
+		{&Order{}},
+		{&User{}},
+		{&UserBalance{}},
+		{&Withdrawal{}, &WithdrawalEvent{}},
+		{&OrderStatusHistory{}},
+		{&WebhookEndpoint{}, &WebhookDelivery{}},
+		{&OutboxEvent{}},
+		{&Campaign{}, &Transfer{}},
+		{&PointLot{}, &LedgerEntry{}},
+		{&UserTier{}},
+		{&Referral{}},
+		{&Reservation{}, &AccrualRevision{}},
+	}
+
+	for _, models := range steps {
+		if err := db.AutoMigrate(models...); err != nil {
+			return err
+		}
+	}
+
+	// AutoMigrate only adds indexes, so the ones replaced by stricter or
+	// differently scoped indexes are dropped here.
+	obsolete := []struct {
+		model interface{}
+		name  string
+	}{
+		{&Withdrawal{}, "idx_withdrawal_order_number_unique"},
+		{&Reservation{}, "idx_reservations_order_number"},
+	}
+
+	for _, index := range obsolete {
+		if !db.Migrator().HasIndex(index.model, index.name) {
+			continue
+		}
+
+		if err := db.Migrator().DropIndex(index.model, index.name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func MigratedTables() []string {
+	return []string{
+		User{}.TableName(),
+		Order{}.TableName(),
+		UserBalance{}.TableName(),
+		Withdrawal{}.TableName(),
+		OrderStatusHistory{}.TableName(),
+		WebhookEndpoint{}.TableName(),
+		WebhookDelivery{}.TableName(),
+		OutboxEvent{}.TableName(),
+		PointLot{}.TableName(),
+		LedgerEntry{}.TableName(),
+		UserTier{}.TableName(),
+		Campaign{}.TableName(),
+		Referral{}.TableName(),
+		Transfer{}.TableName(),
+		WithdrawalEvent{}.TableName(),
+		Reservation{}.TableName(),
+		AccrualRevision{}.TableName(),
+	}
+}
+
+// User rows are never deleted while records refer to them: deleted accounts
+// are anonymized, and every association to User restricts deletes so that
+// financial records cannot be removed with the user. AutoMigrate keeps
+// constraints that already exist; their default NO ACTION refuses such deletes
+// as well.
 type User struct {
-	ID        uint      `gorm:"primaryKey"`
-	Username  string    `gorm:"unique;not null"`
-	Password  string    `gorm:"not null"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID           uint      `gorm:"primaryKey"`
+	Username     string    `gorm:"unique;not null"`
+	Password     string    `gorm:"not null"`
+	ReferralCode *string   `gorm:"size:16;uniqueIndex"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+	// DeletedAt marks an anonymized account; it is not a gorm soft delete.
+	DeletedAt       *time.Time `gorm:"column:deleted_at"`
+	TokensRevokedAt *time.Time `gorm:"column:tokens_revoked_at"`
 }
 
 func (User) TableName() string {
@@ -15,14 +96,15 @@ func (User) TableName() string {
 }
 
 type Order struct {
-	ID        uint      `gorm:"primaryKey"`
-	Number    string    `gorm:"not null"`
-	UserID    uint      `gorm:"not null"`
-	Status    string    `gorm:"not null"`
-	Accrual   float64   `gorm:"default:0"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
-	User      User      `gorm:"foreignKey:UserID"`
+	ID        uint       `gorm:"primaryKey"`
+	Number    string     `gorm:"not null"`
+	UserID    uint       `gorm:"not null;index:idx_orders_user_created,priority:1"`
+	Status    string     `gorm:"not null"`
+	Accrual   float64    `gorm:"default:0"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index:idx_orders_user_created,priority:2"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+	PolledAt  *time.Time `gorm:"column:polled_at"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
 }
 
 func (Order) TableName() string {
@@ -34,7 +116,8 @@ type UserBalance struct {
 	UserID    uint    `gorm:"not null"`
 	Current   float64 `gorm:"default:0"`
 	Withdrawn float64 `gorm:"default:0"`
-	User      User    `gorm:"foreignKey:UserID"`
+	Debt      float64 `gorm:"not null;default:0"`
+	User      User    `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
 }
 
 func (UserBalance) TableName() string {
@@ -43,13 +126,253 @@ func (UserBalance) TableName() string {
 
 type Withdrawal struct {
 	ID          uint      `gorm:"primaryKey"`
-	UserID      uint      `gorm:"not null"`
-	OrderNumber string    `gorm:"not null"`
+	UserID      uint      `gorm:"not null;index:idx_withdrawal_user_created,priority:1"`
+	OrderNumber string    `gorm:"not null;index"`
 	Sum         float64   `gorm:"default:0"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	User        User      `gorm:"foreignKey:UserID"`
+	Status      string    `gorm:"not null;default:CONFIRMED"`
+	Refunded    float64   `gorm:"not null;default:0"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index:idx_withdrawal_user_created,priority:2"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
+
+	// LotsExpireAt is the earliest expiry of the lots the points were taken
+	// from; refunded points keep it.
+	LotsExpireAt *time.Time `gorm:"column:lots_expire_at"`
 }
 
 func (Withdrawal) TableName() string {
 	return "withdrawal"
 }
+
+type OrderStatusHistory struct {
+	ID          uint      `gorm:"primaryKey"`
+	OrderNumber string    `gorm:"not null;index"`
+	Status      string    `gorm:"not null"`
+	Accrual     float64   `gorm:"default:0"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+type WebhookEndpoint struct {
+	ID         uint      `gorm:"primaryKey"`
+	URL        string    `gorm:"not null"`
+	Secret     string    `gorm:"not null"`
+	EventTypes string    `gorm:"not null"`
+	Active     bool      `gorm:"not null;default:true"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookDelivery is one row per outbox event and subscribed endpoint, written
+// by the webhook sink of the outbox relay.
+type WebhookDelivery struct {
+	ID            uint            `gorm:"primaryKey"`
+	EndpointID    uint            `gorm:"not null;index;uniqueIndex:idx_webhook_deliveries_event,priority:1"`
+	OutboxEventID *int64          `gorm:"uniqueIndex:idx_webhook_deliveries_event,priority:2"`
+	EventType     string          `gorm:"not null"`
+	Payload       string          `gorm:"type:text;not null"`
+	Status        string          `gorm:"not null;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts      int             `gorm:"not null;default:0"`
+	NextAttemptAt time.Time       `gorm:"not null;index:idx_webhook_deliveries_due,priority:2"`
+	LastError     string          `gorm:"type:text"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime"`
+	DeliveredAt   *time.Time      `gorm:"column:delivered_at"`
+	Endpoint      WebhookEndpoint `gorm:"foreignKey:EndpointID"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// OutboxEvent is a domain event, written in the transaction of the change it
+// describes and published by the outbox relay.
+type OutboxEvent struct {
+	ID          uint       `gorm:"primaryKey;index:idx_outbox_unpublished,where:published_at IS NULL;index:idx_outbox_user_unpublished,priority:2"`
+	UserID      uint       `gorm:"not null;index:idx_outbox_user_unpublished,priority:1,where:published_at IS NULL"`
+	EventType   string     `gorm:"not null"`
+	Payload     string     `gorm:"type:text;not null"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	PublishedAt *time.Time `gorm:"column:published_at;index:idx_outbox_published,where:published_at IS NOT NULL"`
+	// TxHorizon is the first transaction id not assigned when the event got
+	// its id; see OutboxRepository.Append. Older events have none.
+	TxHorizon *int64 `gorm:"column:tx_horizon"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// PointLot tracks the points of one accrual until they are withdrawn or
+// expire. Withdrawals consume lots oldest first.
+type PointLot struct {
+	ID          uint       `gorm:"primaryKey"`
+	UserID      uint       `gorm:"not null;index:idx_point_lots_user_created,priority:1"`
+	OrderNumber string     `gorm:"not null;default:''"`
+	Amount      float64    `gorm:"not null"`
+	Remaining   float64    `gorm:"not null"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime;index:idx_point_lots_user_created,priority:2"`
+	ExpiresAt   *time.Time `gorm:"column:expires_at;index:idx_point_lots_expiring,where:remaining > 0"`
+	User        User       `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
+}
+
+func (PointLot) TableName() string {
+	return "point_lots"
+}
+
+// LedgerEntry is one signed balance movement. The entries of a user add up to
+// their current balance.
+type LedgerEntry struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"not null;index:idx_balance_ledger_user_created,priority:1"`
+	Kind        string    `gorm:"not null"`
+	Amount      float64   `gorm:"not null"`
+	OrderNumber string    `gorm:"not null;default:''"`
+	CampaignID  *uint     `gorm:"column:campaign_id;index"`
+	TransferID  *uint     `gorm:"column:transfer_id;index"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index:idx_balance_ledger_user_created,priority:2"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
+	Campaign    *Campaign `gorm:"foreignKey:CampaignID"`
+	Transfer    *Transfer `gorm:"foreignKey:TransferID"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "balance_ledger"
+}
+
+// UserTier is the loyalty tier of a user as of the last recalculation, with
+// the rolling statistics it was based on.
+type UserTier struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;uniqueIndex"`
+	Tier      string    `gorm:"not null"`
+	Points    float64   `gorm:"not null;default:0"`
+	Orders    int       `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
+}
+
+func (UserTier) TableName() string {
+	return "user_tiers"
+}
+
+// Campaign is a time-boxed bonus rule. Deleted campaigns stay for the ledger
+// lines that reference them.
+type Campaign struct {
+	ID         uint      `gorm:"primaryKey"`
+	Name       string    `gorm:"not null"`
+	StartsAt   time.Time `gorm:"not null;index:idx_campaigns_window,priority:1"`
+	EndsAt     time.Time `gorm:"not null;index:idx_campaigns_window,priority:2"`
+	FirstOrder bool      `gorm:"not null;default:false"`
+	MinAccrual float64   `gorm:"not null;default:0"`
+	Tiers      string    `gorm:"not null;default:''"`
+	Multiplier float64   `gorm:"not null;default:0"`
+	FlatBonus  float64   `gorm:"not null;default:0"`
+	Active     bool      `gorm:"not null;default:true"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (Campaign) TableName() string {
+	return "campaigns"
+}
+
+// Referral links an invited user to the user whose code they registered with.
+// Both bonuses are paid once, when the invitee's first order is processed.
+type Referral struct {
+	ID            uint       `gorm:"primaryKey"`
+	ReferrerID    uint       `gorm:"not null;index"`
+	RefereeID     uint       `gorm:"not null;uniqueIndex"`
+	Status        string     `gorm:"not null;default:PENDING"`
+	ReferrerBonus float64    `gorm:"not null;default:0"`
+	RefereeBonus  float64    `gorm:"not null;default:0"`
+	OrderNumber   string     `gorm:"not null;default:''"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime"`
+	RewardedAt    *time.Time `gorm:"column:rewarded_at"`
+	Referrer      User       `gorm:"foreignKey:ReferrerID;constraint:OnDelete:RESTRICT"`
+	Referee       User       `gorm:"foreignKey:RefereeID;constraint:OnDelete:RESTRICT"`
+}
+
+func (Referral) TableName() string {
+	return "referrals"
+}
+
+// Transfer moves points from one user to another. The idempotency key is
+// unique per sender.
+type Transfer struct {
+	ID             uint      `gorm:"primaryKey"`
+	SenderID       uint      `gorm:"not null;uniqueIndex:idx_transfers_sender_key,priority:1;index:idx_transfers_sender_created,priority:1"`
+	RecipientID    uint      `gorm:"not null;index"`
+	Amount         float64   `gorm:"not null"`
+	IdempotencyKey string    `gorm:"not null;uniqueIndex:idx_transfers_sender_key,priority:2"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;index:idx_transfers_sender_created,priority:2"`
+	Sender         User      `gorm:"foreignKey:SenderID;constraint:OnDelete:RESTRICT"`
+	Recipient      User      `gorm:"foreignKey:RecipientID;constraint:OnDelete:RESTRICT"`
+}
+
+func (Transfer) TableName() string {
+	return "transfers"
+}
+
+// WithdrawalEvent is the audit trail of a withdrawal: its creation,
+// confirmation and every refund, with who made them.
+type WithdrawalEvent struct {
+	ID           uint       `gorm:"primaryKey"`
+	WithdrawalID uint       `gorm:"not null;index"`
+	Action       string     `gorm:"not null"`
+	Amount       float64    `gorm:"not null;default:0"`
+	Actor        string     `gorm:"not null"`
+	Reason       string     `gorm:"not null;default:''"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	Withdrawal   Withdrawal `gorm:"foreignKey:WithdrawalID"`
+}
+
+func (WithdrawalEvent) TableName() string {
+	return "withdrawal_events"
+}
+
+// Reservation holds points of a user for an order until it is captured as a
+// withdrawal, released or expires. There is one reservation per order.
+type Reservation struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"not null;index:idx_reservations_user_status,priority:1;uniqueIndex:idx_reservations_user_order,priority:1"`
+	OrderNumber string    `gorm:"not null;uniqueIndex:idx_reservations_user_order,priority:2"`
+	Amount      float64   `gorm:"not null"`
+	Captured    float64   `gorm:"not null;default:0"`
+	Status      string    `gorm:"not null;default:HELD;index:idx_reservations_user_status,priority:2;index:idx_reservations_status_expires,priority:1"`
+	ExpiresAt   time.Time `gorm:"not null;index:idx_reservations_status_expires,priority:2"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
+}
+
+func (Reservation) TableName() string {
+	return "reservations"
+}
+
+// AccrualRevision records a change of the accrual of an order after it was
+// credited. Delta is what the balance was adjusted by; Debt is the part of a
+// reduction that could not be debited and was recorded as debt instead.
+type AccrualRevision struct {
+	ID              uint      `gorm:"primaryKey"`
+	UserID          uint      `gorm:"not null;index:idx_accrual_revisions_user_created,priority:1"`
+	OrderNumber     string    `gorm:"not null;index"`
+	PreviousStatus  string    `gorm:"not null"`
+	Status          string    `gorm:"not null"`
+	PreviousAccrual float64   `gorm:"not null"`
+	Accrual         float64   `gorm:"not null"`
+	Delta           float64   `gorm:"not null"`
+	BonusDelta      float64   `gorm:"not null;default:0"`
+	Debt            float64   `gorm:"not null;default:0"`
+	CreatedAt       time.Time `gorm:"autoCreateTime;index:idx_accrual_revisions_user_created,priority:2"`
+	User            User      `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT"`
+}
+
+func (AccrualRevision) TableName() string {
+	return "accrual_revisions"
+}
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS point_lots (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    order_number VARCHAR(255) NOT NULL DEFAULT '',
+    amount DECIMAL(10, 2) NOT NULL,
+    remaining DECIMAL(10, 2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_point_lots_user_created ON point_lots(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_point_lots_expiring ON point_lots(expires_at) WHERE remaining > 0;
+
+CREATE TABLE IF NOT EXISTS balance_ledger (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    kind VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    order_number VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_ledger_user_created ON balance_ledger(user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS balance_ledger;
+DROP TABLE IF EXISTS point_lots;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    first_order BOOLEAN NOT NULL DEFAULT FALSE,
+    min_accrual DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    tiers TEXT NOT NULL DEFAULT '',
+    multiplier DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    flat_bonus DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_window ON campaigns(starts_at, ends_at);
+
+ALTER TABLE balance_ledger ADD COLUMN IF NOT EXISTS campaign_id INT REFERENCES campaigns(id);
+CREATE INDEX IF NOT EXISTS idx_balance_ledger_campaign_id ON balance_ledger(campaign_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE balance_ledger DROP COLUMN IF EXISTS campaign_id;
+DROP TABLE IF EXISTS campaigns;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code VARCHAR(16);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_referral_code ON users(referral_code);
+
+CREATE TABLE IF NOT EXISTS referrals (
+    id SERIAL PRIMARY KEY,
+    referrer_id INT NOT NULL REFERENCES users(id),
+    referee_id INT NOT NULL REFERENCES users(id),
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+    referrer_bonus DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    referee_bonus DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    order_number VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rewarded_at TIMESTAMP,
+    CHECK (referrer_id <> referee_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referrals_referee_id ON referrals(referee_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals(referrer_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS referrals;
+DROP INDEX IF EXISTS idx_users_referral_code;
+ALTER TABLE users DROP COLUMN IF EXISTS referral_code;
+-- +goose StatementEnd
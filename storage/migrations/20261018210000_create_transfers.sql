@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS transfers (
+    id SERIAL PRIMARY KEY,
+    sender_id INT NOT NULL REFERENCES users(id),
+    recipient_id INT NOT NULL REFERENCES users(id),
+    amount DECIMAL(10, 2) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (sender_id <> recipient_id),
+    CHECK (amount > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_sender_key ON transfers(sender_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_sender_created ON transfers(sender_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transfers_recipient_id ON transfers(recipient_id);
+
+ALTER TABLE balance_ledger ADD COLUMN IF NOT EXISTS transfer_id INT REFERENCES transfers(id);
+CREATE INDEX IF NOT EXISTS idx_balance_ledger_transfer_id ON balance_ledger(transfer_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE balance_ledger DROP COLUMN IF EXISTS transfer_id;
+DROP TABLE IF EXISTS transfers;
+-- +goose StatementEnd
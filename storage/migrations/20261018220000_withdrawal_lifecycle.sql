@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Withdrawals made before the lifecycle existed are final.
+ALTER TABLE withdrawal ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'CONFIRMED';
+ALTER TABLE withdrawal ADD COLUMN IF NOT EXISTS refunded DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE withdrawal ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_withdrawal_order_number ON withdrawal(order_number);
+
+CREATE TABLE IF NOT EXISTS withdrawal_events (
+    id SERIAL PRIMARY KEY,
+    withdrawal_id INT NOT NULL REFERENCES withdrawal(id),
+    action VARCHAR(16) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_withdrawal_events_withdrawal_id ON withdrawal_events(withdrawal_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS withdrawal_events;
+DROP INDEX IF EXISTS idx_withdrawal_order_number;
+ALTER TABLE withdrawal DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE withdrawal DROP COLUMN IF EXISTS refunded;
+ALTER TABLE withdrawal DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS reservations (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    order_number VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    captured DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'HELD',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (amount > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_order_number ON reservations(order_number);
+CREATE INDEX IF NOT EXISTS idx_reservations_user_status ON reservations(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_reservations_status_expires ON reservations(status, expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reservations;
+-- +goose StatementEnd
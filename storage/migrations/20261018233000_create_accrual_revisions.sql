@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_balance ADD COLUMN IF NOT EXISTS debt DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS accrual_revisions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    order_number VARCHAR(255) NOT NULL,
+    previous_status VARCHAR(255) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    previous_accrual DECIMAL(10, 2) NOT NULL,
+    accrual DECIMAL(10, 2) NOT NULL,
+    delta DECIMAL(10, 2) NOT NULL,
+    debt DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_accrual_revisions_order_number ON accrual_revisions(order_number);
+CREATE INDEX IF NOT EXISTS idx_accrual_revisions_user_created ON accrual_revisions(user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS accrual_revisions;
+ALTER TABLE user_balance DROP COLUMN IF EXISTS debt;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Order numbers are chosen by users, so a hold is unique per user only; the
+-- shop addresses holds by id.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_user_order ON reservations(user_id, order_number);
+DROP INDEX IF EXISTS idx_reservations_order_number;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_order_number ON reservations(order_number);
+DROP INDEX IF EXISTS idx_reservations_user_order;
+-- +goose StatementEnd
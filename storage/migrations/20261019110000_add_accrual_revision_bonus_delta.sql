@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The change of the order's bonuses, which revisions scale with the accrual.
+ALTER TABLE accrual_revisions ADD COLUMN IF NOT EXISTS bonus_delta DECIMAL(10, 2) NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE accrual_revisions DROP COLUMN IF EXISTS bonus_delta;
+-- +goose StatementEnd
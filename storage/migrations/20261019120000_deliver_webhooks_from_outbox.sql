@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Webhook deliveries are enqueued by the outbox relay, which may publish an
+-- event more than once; the outbox event id makes that idempotent.
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS outbox_event_id BIGINT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries (endpoint_id, outbox_event_id);
+-- Published events are pruned by age.
+CREATE INDEX IF NOT EXISTS idx_outbox_published ON outbox (published_at) WHERE published_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_outbox_published;
+DROP INDEX IF EXISTS idx_webhook_deliveries_event;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS outbox_event_id;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Order numbers are not unique across users: the shop API addresses
+-- withdrawals by id instead.
+DROP INDEX IF EXISTS idx_withdrawal_order_number_unique;
+CREATE INDEX IF NOT EXISTS idx_withdrawal_order_number ON withdrawal(order_number);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The plain index predates this migration and stays.
+SELECT 1;
+-- +goose StatementEnd
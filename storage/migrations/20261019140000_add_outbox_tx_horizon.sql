@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events wait for every transaction that may still commit a lower id of the
+-- same user.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS tx_horizon BIGINT;
+CREATE INDEX IF NOT EXISTS idx_outbox_user_unpublished ON outbox(user_id, id) WHERE published_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_outbox_user_unpublished;
+ALTER TABLE outbox DROP COLUMN IF EXISTS tx_horizon;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Refunded points keep the earliest expiry of the lots the withdrawal took
+-- them from. Earlier withdrawals have none and refund as a new credit.
+ALTER TABLE withdrawal ADD COLUMN IF NOT EXISTS lots_expire_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE withdrawal DROP COLUMN IF EXISTS lots_expire_at;
+-- +goose StatementEnd
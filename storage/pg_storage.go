@@ -3,21 +3,61 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"log"
+	"gophermart/internal/logger"
+	"gophermart/internal/tracing"
+	"log/slog"
+	"time"
 )
 
 type PgStorage struct {
 	Conn        *pgxpool.Pool
 	Ctx         context.Context
+	Pool        PoolSettings
 	transaction pgx.Tx
 }
 
+type PoolSettings struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+}
+
 func (pgs *PgStorage) Init(connectionString string) error {
 	pgs.Ctx = context.Background()
-	var err error
-	pgs.Conn, err = pgxpool.Connect(pgs.Ctx, connectionString)
+	poolConfig, err := pgxpool.ParseConfig(connectionString)
+
+	if err != nil {
+		return err
+	}
+
+	if pgs.Pool.MaxConns > 0 {
+		poolConfig.MaxConns = pgs.Pool.MaxConns
+	}
+
+	if pgs.Pool.MinConns > 0 {
+		poolConfig.MinConns = pgs.Pool.MinConns
+	}
+
+	if pgs.Pool.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = pgs.Pool.MaxConnLifetime
+	}
+
+	if pgs.Pool.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = pgs.Pool.MaxConnIdleTime
+	}
+
+	if pgs.Pool.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = pgs.Pool.HealthCheckPeriod
+	}
+
+	poolConfig.ConnConfig.Logger = &tracing.PgxTracer{Next: &logger.PgxLogger{}}
+	poolConfig.ConnConfig.LogLevel = pgx.LogLevelInfo
+	pgs.Conn, err = pgxpool.ConnectConfig(pgs.Ctx, poolConfig)
 
 	if err != nil {
 		return err
@@ -33,7 +73,7 @@ func (pgs *PgStorage) Exec(query string, args ...interface{}) (sql.Result, error
 func (pgs *PgStorage) Select(query string, args ...interface{}) (pgx.Rows, error) {
 	rows, err := pgs.Conn.Query(pgs.Ctx, query, args...)
 	if err != nil {
-		log.Printf("Query execution error: %v\n", err)
+		slog.Error("Query execution error", "error", err)
 		return nil, err
 	}
 	return rows, nil
@@ -62,3 +102,24 @@ func (pgs *PgStorage) Commit() error {
 func (pgs *PgStorage) QueryRow(query string, args ...interface{}) pgx.Row {
 	return pgs.transaction.QueryRow(pgs.Ctx, query, args...)
 }
+
+func (pgs *PgStorage) Ping(ctx context.Context) error {
+	return pgs.Conn.Ping(ctx)
+}
+
+func (pgs *PgStorage) CheckMigrations(ctx context.Context) error {
+	for _, table := range MigratedTables() {
+		var exists bool
+		query := "SELECT to_regclass($1) IS NOT NULL"
+
+		if err := pgs.Conn.QueryRow(ctx, query, table).Scan(&exists); err != nil {
+			return err
+		}
+
+		if !exists {
+			return fmt.Errorf("table %s is missing", table)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package storagetest provides a migrated, empty database for tests of code
+// that runs SQL. Set TEST_DATABASE_URI to a database the tests may wipe;
+// without it those tests are skipped.
+package storagetest
+
+import (
+	"context"
+	"gophermart/storage"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"strings"
+	"testing"
+)
+
+const EnvDatabaseURI = "TEST_DATABASE_URI"
+
+// Open migrates the test database, empties every table and returns a storage
+// connected to it, closed when the test ends.
+func Open(t testing.TB) *storage.PgStorage {
+	t.Helper()
+
+	dsn := os.Getenv(EnvDatabaseURI)
+
+	if dsn == "" {
+		t.Skipf("%s is not set", EnvDatabaseURI)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	pgs := &storage.PgStorage{}
+
+	if err := pgs.Init(dsn); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(pgs.Close)
+
+	query := "TRUNCATE " + strings.Join(storage.MigratedTables(), ", ") + " RESTART IDENTITY CASCADE"
+
+	if _, err := pgs.Conn.Exec(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+
+	return pgs
+}
+
+// CreateUser inserts a user with an empty balance and returns their ID.
+func CreateUser(t testing.TB, pgs *storage.PgStorage, username string) int {
+	t.Helper()
+
+	var id int
+	ctx := context.Background()
+
+	err := pgs.Conn.QueryRow(ctx, "INSERT INTO users (username, password, created_at, updated_at) VALUES ($1, '', now(), now()) RETURNING id",
+		username).Scan(&id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pgs.Conn.Exec(ctx, "INSERT INTO user_balance (user_id, current, withdrawn) VALUES ($1, 0, 0)", id); err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
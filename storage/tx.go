@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Querier is implemented by both the pool and a transaction, so repository
+// code does not need to know whether it runs inside WithTx.
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type txKey struct{}
+
+// txState is the transaction of a context together with what is to run once
+// it commits.
+type txState struct {
+	tx          pgx.Tx
+	afterCommit []func()
+}
+
+// Querier returns the transaction started by WithTx for this context, or the
+// pool when there is none.
+func (pgs *PgStorage) Querier(ctx context.Context) Querier {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+
+	return pgs.Conn
+}
+
+// AfterCommit runs fn once the transaction of ctx has committed, and never if
+// it rolls back. Without a transaction fn runs right away.
+func (pgs *PgStorage) AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+
+	fn()
+}
+
+// WithTx runs fn in a transaction carried by ctx. Nested calls join the outer
+// transaction.
+func (pgs *PgStorage) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+
+	tx, err := pgs.Conn.BeginTx(ctx, pgx.TxOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback(ctx)
+			panic(r)
+		}
+	}()
+
+	state := &txState{tx: tx}
+
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, fn := range state.afterCommit {
+		fn()
+	}
+
+	return nil
+}